@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Duration accepts either a number of seconds or a duration string (e.g. `90s`).
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(bytes []byte) error {
+	var seconds float64
+
+	err := json.Unmarshal(bytes, &seconds)
+	if err == nil {
+		d.Duration = time.Duration(seconds * float64(time.Second))
+
+		return nil
+	}
+
+	var s string
+
+	err = json.Unmarshal(bytes, &s)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.Wrap(err, "parsing duration")
+	}
+
+	d.Duration = parsed
+
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Duration.String())
+}
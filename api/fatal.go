@@ -6,7 +6,7 @@ import (
 )
 
 func Fatal(msg string, err error) {
-	fmt.Fprintln(os.Stderr, fmt.Sprintf("%s: %s", msg, err))
+	fmt.Fprintln(os.Stderr, Redact(fmt.Sprintf("%s: %s", msg, err)))
 
 	os.Exit(1)
 }
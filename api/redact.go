@@ -0,0 +1,154 @@
+package api
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// minSecretLength avoids redacting short values (e.g. booleans) which would
+// obscure unrelated output.
+const minSecretLength = 4
+
+// secretOptions are option names whose values are secret for any repository or
+// handler type.
+var secretOptions = map[string]bool{
+	"access_key":    true,
+	"access_token":  true,
+	"password":      true,
+	"private_key":   true,
+	"secret_key":    true,
+	"session_token": true,
+	"signing_key":   true,
+	"token":         true,
+}
+
+// credentialQueryParams are query parameters of URLs which carry credentials.
+const credentialQueryParams = `x-amz-signature|x-amz-credential|x-amz-security-token|access_token|token|signature|sig|password`
+
+var (
+	urlUserinfoRegexp        = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)([^/?#@\s:]*)(:[^/?#@\s]*)?@`)
+	urlQueryRegexp           = regexp.MustCompile(`(?i)([?&](?:` + credentialQueryParams + `)=)[^&#\s"'<>]+`)
+	credentialQueryKeyRegexp = regexp.MustCompile(`(?i)^(?:` + credentialQueryParams + `)$`)
+)
+
+var secrets = struct {
+	sync.Mutex
+	values []string
+}{}
+
+// AddSecret registers a value which Redact removes from output. Each line of
+// multi-line values (e.g. keys) is also registered since output may include
+// only part of them.
+func AddSecret(value string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+
+	candidates := []string{value}
+
+	if strings.Contains(value, "\n") {
+		candidates = append(candidates, strings.Split(value, "\n")...)
+	}
+
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if len(candidate) < minSecretLength || strings.HasPrefix(candidate, "-----") {
+			// armor lines of keys are not secret
+			continue
+		}
+
+		secrets.values = append(secrets.values, candidate)
+	}
+
+	// longest first so that values containing others are fully redacted
+	sort.Slice(secrets.values, func(i, j int) bool {
+		return len(secrets.values[i]) > len(secrets.values[j])
+	})
+}
+
+// AddSourceSecrets registers the values of secret options of a source and its
+// URL handlers, including nested options (e.g. pull_request.token).
+func AddSourceSecrets(source Source) {
+	addOptionSecrets(source.Options)
+
+	for _, handler := range source.URLHandlers {
+		addOptionSecrets(handler.Options)
+	}
+
+	for _, mirror := range source.MirrorFiles {
+		// legacy upload credentials (see MigrateSource)
+		for k, v := range mirror.Env {
+			if k == "AWS_ACCESS_KEY_ID" || k == "AWS_SECRET_ACCESS_KEY" {
+				AddSecret(v)
+			}
+		}
+	}
+}
+
+func addOptionSecrets(options map[string]interface{}) {
+	for key, value := range options {
+		switch typed := value.(type) {
+		case string:
+			if secretOptions[key] {
+				AddSecret(typed)
+			}
+		case map[string]interface{}:
+			addOptionSecrets(typed)
+		}
+	}
+}
+
+// Redact replaces registered secrets in a string, along with credentials of
+// URLs (i.e. passwords, tokens used as usernames, and signed query parameters).
+func Redact(value string) string {
+	secrets.Lock()
+
+	for _, secret := range secrets.values {
+		value = strings.Replace(value, secret, "[redacted]", -1)
+	}
+
+	secrets.Unlock()
+
+	value = urlUserinfoRegexp.ReplaceAllStringFunc(value, func(match string) string {
+		submatch := urlUserinfoRegexp.FindStringSubmatch(match)
+
+		if submatch[3] != "" {
+			return submatch[1] + submatch[2] + ":[redacted]@"
+		} else if strings.HasPrefix(strings.ToLower(submatch[1]), "http") {
+			// usernames of ssh URLs (e.g. git@) are not secret
+			return submatch[1] + "[redacted]@"
+		}
+
+		return match
+	})
+
+	return urlQueryRegexp.ReplaceAllString(value, "${1}[redacted]")
+}
+
+// PublicURL removes the user info and credential query parameters of a URL,
+// rather than redacting them, for publishing it (e.g. as an identifier). Any
+// other secrets are still redacted.
+func PublicURL(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return Redact(uri)
+	}
+
+	parsed.User = nil
+
+	if parsed.RawQuery != "" {
+		query := parsed.Query()
+
+		for key := range query {
+			if credentialQueryKeyRegexp.MatchString(key) {
+				query.Del(key)
+			}
+		}
+
+		parsed.RawQuery = query.Encode()
+	}
+
+	return Redact(parsed.String())
+}
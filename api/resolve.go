@@ -0,0 +1,184 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var interpolationRegexp = regexp.MustCompile(`\(\((env|file):([^()]+)\)\)`)
+
+// ResolveSource replaces ((env:NAME)) and ((file:PATH)) references in the
+// strings of a source, then the *_env and *_file variants of secret options
+// (e.g. secret_key_file) with the values they reference. Resolved values and
+// those of secret options are registered for Redact.
+func ResolveSource(source *Source) error {
+	var err error
+
+	source.URI, err = interpolateString(source.URI)
+	if err != nil {
+		return errors.Wrap(err, "uri")
+	}
+
+	source.SignatureTrustStore, err = interpolateString(source.SignatureTrustStore)
+	if err != nil {
+		return errors.Wrap(err, "signature_trust_store")
+	}
+
+	err = resolveOptions(source.Options)
+	if err != nil {
+		return errors.Wrap(err, "options")
+	}
+
+	for handlerIdx, handler := range source.URLHandlers {
+		err = resolveOptions(handler.Options)
+		if err != nil {
+			return errors.Wrapf(err, "url_handlers[%d]: options", handlerIdx)
+		}
+	}
+
+	for mirrorIdx := range source.MirrorFiles {
+		mirror := &source.MirrorFiles[mirrorIdx]
+
+		mirror.Destination, err = interpolateString(mirror.Destination)
+		if err != nil {
+			return errors.Wrapf(err, "mirror_files[%d]: destination", mirrorIdx)
+		}
+
+		for k, v := range mirror.Env {
+			mirror.Env[k], err = interpolateString(v)
+			if err != nil {
+				return errors.Wrapf(err, "mirror_files[%d]: env: %s", mirrorIdx, k)
+			}
+		}
+	}
+
+	AddSourceSecrets(*source)
+
+	return nil
+}
+
+func resolveOptions(options map[string]interface{}) error {
+	for key, value := range options {
+		resolved, err := interpolateValue(value)
+		if err != nil {
+			return errors.Wrap(err, key)
+		}
+
+		options[key] = resolved
+	}
+
+	// sorted for consistent errors
+	var keys []string
+
+	for key := range options {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var name, resolved string
+		var err error
+
+		// other options may legitimately end with a suffix (e.g. ca_cert_file)
+		if name = strings.TrimSuffix(key, "_env"); name != key && secretOptions[name] {
+			resolved, err = resolveReference("env", options[key])
+		} else if name = strings.TrimSuffix(key, "_file"); name != key && secretOptions[name] {
+			resolved, err = resolveReference("file", options[key])
+		} else {
+			continue
+		}
+
+		if err != nil {
+			return errors.Wrap(err, key)
+		} else if _, found := options[name]; found {
+			return fmt.Errorf("%s: cannot be combined with %s", key, name)
+		}
+
+		options[name] = resolved
+		delete(options, key)
+	}
+
+	return nil
+}
+
+func interpolateValue(value interface{}) (interface{}, error) {
+	switch typed := value.(type) {
+	case string:
+		return interpolateString(typed)
+	case map[string]interface{}:
+		return typed, resolveOptions(typed)
+	case []interface{}:
+		for idx, item := range typed {
+			resolved, err := interpolateValue(item)
+			if err != nil {
+				return nil, errors.Wrapf(err, "[%d]", idx)
+			}
+
+			typed[idx] = resolved
+		}
+	}
+
+	return value, nil
+}
+
+func interpolateString(value string) (string, error) {
+	var err error
+
+	resolved := interpolationRegexp.ReplaceAllStringFunc(value, func(match string) string {
+		if err != nil {
+			return match
+		}
+
+		submatch := interpolationRegexp.FindStringSubmatch(match)
+
+		var replacement string
+
+		replacement, err = resolveReference(submatch[1], submatch[2])
+
+		return replacement
+	})
+
+	return resolved, err
+}
+
+func resolveReference(kind string, reference interface{}) (string, error) {
+	referenceStr, ok := reference.(string)
+	if !ok || referenceStr == "" {
+		return "", fmt.Errorf("expected %s reference string", kind)
+	}
+
+	var resolved string
+
+	switch kind {
+	case "env":
+		value, found := os.LookupEnv(referenceStr)
+		if !found {
+			return "", fmt.Errorf("environment variable is not set: %s", referenceStr)
+		}
+
+		resolved = value
+	case "file":
+		valueBytes, err := ioutil.ReadFile(referenceStr)
+		if err != nil {
+			return "", errors.Wrap(err, "reading file")
+		}
+
+		resolved = string(valueBytes)
+
+		if trimmed := strings.TrimSuffix(resolved, "\n"); !strings.Contains(trimmed, "\n") {
+			// single-line values (e.g. tokens) rarely expect the newline of the file
+			resolved = trimmed
+		}
+	}
+
+	AddSecret(resolved)
+
+	return resolved, nil
+}
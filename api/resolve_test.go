@@ -0,0 +1,104 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/dpb587/metalink-repository-resource/api"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ResolveSource", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+
+		tmpDir, err = ioutil.TempDir("", "metalink-repository-resource-api")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.Setenv("METALINK_TEST_SECRET_KEY", "fake-secret-key")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Unsetenv("METALINK_TEST_SECRET_KEY")).To(Succeed())
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("resolves the _env and _file suffixes of secret options", func() {
+		tokenFile := filepath.Join(tmpDir, "token")
+		Expect(ioutil.WriteFile(tokenFile, []byte("fake-token\n"), 0600)).To(Succeed())
+
+		source := api.Source{
+			Options: map[string]interface{}{
+				"token_file": tokenFile,
+				"pull_request": map[string]interface{}{
+					"token_file": tokenFile,
+				},
+			},
+			URLHandlers: []api.HandlerSource{
+				{
+					Type: "s3",
+					Options: map[string]interface{}{
+						"secret_key_env": "METALINK_TEST_SECRET_KEY",
+					},
+				},
+			},
+		}
+
+		Expect(api.ResolveSource(&source)).To(Succeed())
+		Expect(source.Options).To(Equal(map[string]interface{}{
+			"token": "fake-token",
+			"pull_request": map[string]interface{}{
+				"token": "fake-token",
+			},
+		}))
+		Expect(source.URLHandlers[0].Options).To(Equal(map[string]interface{}{
+			"secret_key": "fake-secret-key",
+		}))
+	})
+
+	It("keeps multi-line files intact", func() {
+		keyFile := filepath.Join(tmpDir, "key")
+		Expect(ioutil.WriteFile(keyFile, []byte("line one\nline two\n"), 0600)).To(Succeed())
+
+		source := api.Source{Options: map[string]interface{}{"private_key_file": keyFile}}
+
+		Expect(api.ResolveSource(&source)).To(Succeed())
+		Expect(source.Options).To(Equal(map[string]interface{}{"private_key": "line one\nline two\n"}))
+	})
+
+	It("leaves the suffixes of other options unchanged", func() {
+		source := api.Source{
+			Options: map[string]interface{}{
+				"ca_cert_file":  "/etc/ssl/ca.pem",
+				"region_env":    "METALINK_TEST_SECRET_KEY",
+				"username_file": "/missing",
+			},
+		}
+
+		Expect(api.ResolveSource(&source)).To(Succeed())
+		Expect(source.Options).To(Equal(map[string]interface{}{
+			"ca_cert_file":  "/etc/ssl/ca.pem",
+			"region_env":    "METALINK_TEST_SECRET_KEY",
+			"username_file": "/missing",
+		}))
+	})
+
+	It("fails for unset environment variables", func() {
+		source := api.Source{Options: map[string]interface{}{"password_env": "METALINK_TEST_MISSING"}}
+
+		Expect(api.ResolveSource(&source)).To(MatchError("options: password_env: environment variable is not set: METALINK_TEST_MISSING"))
+	})
+
+	It("fails when combined with the option itself", func() {
+		source := api.Source{Options: map[string]interface{}{
+			"secret_key":     "inline",
+			"secret_key_env": "METALINK_TEST_SECRET_KEY",
+		}}
+
+		Expect(api.ResolveSource(&source)).To(MatchError("options: secret_key_env: cannot be combined with secret_key"))
+	})
+})
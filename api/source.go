@@ -22,6 +22,13 @@ type Source struct {
 	IncludeFiles []string `json:"include_files,omitempty"`
 	ExcludeFiles []string `json:"exclude_files,omitempty"`
 
+	DownloadCache *DownloadCacheParams `json:"download_cache,omitempty"`
+
+	Transfer TransferParams `json:"transfer,omitempty"`
+
+	PreferredLocations   []string        `json:"preferred_locations,omitempty"`
+	URLPriorityOverrides map[string]uint `json:"url_priority_overrides,omitempty"`
+
 	Version string              `json:"version,omitempty"`
 	Filters []map[string]string `json:"filters,omitempty"`
 }
@@ -31,6 +38,17 @@ type MirrorFileParams struct {
 	Location    string            `json:"location,omitempty"`
 	Priority    *uint             `json:"priority,omitempty"`
 	Env         map[string]string `json:"env,omitempty"`
+	Presign     *PresignParams    `json:"presign,omitempty"`
+}
+
+type PresignParams struct {
+	Expiry  *Duration `json:"expiry,omitempty"`
+	Replace bool      `json:"replace,omitempty"`
+}
+
+type DownloadCacheParams struct {
+	Path string `json:"path"`
+	Copy bool   `json:"copy,omitempty"`
 }
 
 type HandlerSource struct {
@@ -0,0 +1,24 @@
+package api
+
+type TransferParams struct {
+	MaxBytesPerSecond uint64    `json:"max_bytes_per_second,omitempty"`
+	Timeout           *Duration `json:"timeout,omitempty"`
+	StallTimeout      *Duration `json:"stall_timeout,omitempty"`
+}
+
+// Merge returns a copy with any settings from override taking precedence.
+func (t TransferParams) Merge(override TransferParams) TransferParams {
+	if override.MaxBytesPerSecond > 0 {
+		t.MaxBytesPerSecond = override.MaxBytesPerSecond
+	}
+
+	if override.Timeout != nil {
+		t.Timeout = override.Timeout
+	}
+
+	if override.StallTimeout != nil {
+		t.StallTimeout = override.StallTimeout
+	}
+
+	return t
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/dpb587/metalink-repository-resource/internal/archive"
+)
+
+type UnpackParams struct {
+	Enabled         bool     `json:"-"`
+	Files           []string `json:"files,omitempty"`
+	StripComponents int      `json:"strip_components,omitempty"`
+	KeepArchive     bool     `json:"keep_archive,omitempty"`
+}
+
+func (p *UnpackParams) UnmarshalJSON(bytes []byte) error {
+	// support the simple `unpack: true` form
+	if err := json.Unmarshal(bytes, &p.Enabled); err == nil {
+		return nil
+	}
+
+	type unpackParams UnpackParams
+
+	err := json.Unmarshal(bytes, (*unpackParams)(p))
+	if err != nil {
+		return err
+	}
+
+	p.Enabled = true
+
+	return nil
+}
+
+func (p UnpackParams) Matches(name string) bool {
+	if !p.Enabled {
+		return false
+	} else if len(p.Files) == 0 {
+		return archive.IsArchive(name)
+	}
+
+	for _, pattern := range p.Files {
+		if match, _ := filepath.Match(pattern, name); match {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dpb587/metalink"
+	"github.com/pkg/errors"
+)
+
+const defaultURLPriority = uint(999999)
+
+type urlCandidate struct {
+	locationRank int
+	priority     uint
+	url          *metalink.URL
+	metaURL      *metalink.MetaURL
+}
+
+// FileCandidates returns copies of file which each have a single URL or meta
+// URL, ordered by preferred location, priority overrides and then the metalink
+// priorities. Meta URLs have no location, so they rank with URLs outside of the
+// preferred locations, and are ordered before those of the same priority.
+func (s Source) FileCandidates(file metalink.File) ([]metalink.File, error) {
+	overrides := map[*regexp.Regexp]uint{}
+
+	for pattern, priority := range s.URLPriorityOverrides {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing url priority override: %s", pattern)
+		}
+
+		overrides[re] = priority
+	}
+
+	priorityOf := func(url string, priority *uint) uint {
+		resolved := defaultURLPriority
+		if priority != nil {
+			resolved = *priority
+		}
+
+		var overridden bool
+
+		for re, overridePriority := range overrides {
+			if !re.MatchString(url) {
+				continue
+			}
+
+			// the most preferred override wins when several match
+			if !overridden || overridePriority < resolved {
+				resolved = overridePriority
+				overridden = true
+			}
+		}
+
+		return resolved
+	}
+
+	locationRankOf := func(location string) int {
+		for idx, preferred := range s.PreferredLocations {
+			if location != "" && strings.EqualFold(location, preferred) {
+				return idx
+			}
+		}
+
+		return len(s.PreferredLocations)
+	}
+
+	var candidates []urlCandidate
+
+	for idx := range file.MetaURLs {
+		candidates = append(candidates, urlCandidate{
+			locationRank: locationRankOf(""),
+			priority:     priorityOf(file.MetaURLs[idx].URL, file.MetaURLs[idx].Priority),
+			metaURL:      &file.MetaURLs[idx],
+		})
+	}
+
+	for idx := range file.URLs {
+		candidates = append(candidates, urlCandidate{
+			locationRank: locationRankOf(file.URLs[idx].Location),
+			priority:     priorityOf(file.URLs[idx].URL, file.URLs[idx].Priority),
+			url:          &file.URLs[idx],
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].locationRank != candidates[j].locationRank {
+			return candidates[i].locationRank < candidates[j].locationRank
+		}
+
+		return candidates[i].priority < candidates[j].priority
+	})
+
+	var files []metalink.File
+
+	for _, candidate := range candidates {
+		candidateFile := file
+		candidateFile.URLs = nil
+		candidateFile.MetaURLs = nil
+
+		if candidate.url != nil {
+			candidateFile.URLs = []metalink.URL{*candidate.url}
+		} else {
+			candidateFile.MetaURLs = []metalink.MetaURL{*candidate.metaURL}
+		}
+
+		files = append(files, candidateFile)
+	}
+
+	return files, nil
+}
@@ -19,6 +19,11 @@ func main() {
 		api.Fatal("check: bad stdin: parse error", err)
 	}
 
+	err = api.ResolveSource(&request.Source)
+	if err != nil {
+		api.Fatal("check: bad stdin: source", err)
+	}
+
 	andFilter := filter_and.NewFilter()
 
 	err = request.ApplyFilter(&andFilter)
@@ -26,7 +31,7 @@ func main() {
 		api.Fatal("check: bad stdin: filter error", err)
 	}
 
-	repository, err := factory.GetSource(request.Source.URI, request.Source.Options)
+	repository, err := factory.GetRepository(request.Source)
 	if err != nil {
 		api.Fatal("check: bad stdin: source: uri", err)
 	}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink-repository-resource/factory"
+	"github.com/dpb587/metalink/repository/source"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+type keyValueFlag []string
+
+func (f *keyValueFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *keyValueFlag) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("expected KEY=VALUE: %s", value)
+	}
+
+	*f = append(*f, value)
+
+	return nil
+}
+
+func (f keyValueFlag) Each(cb func(key, value string)) {
+	for _, kv := range f {
+		split := strings.SplitN(kv, "=", 2)
+		cb(split[0], split[1])
+	}
+}
+
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+
+	return nil
+}
+
+// sourceFlags configure the repository the same way as the `source` of the
+// resource configuration.
+type sourceFlags struct {
+	config     string
+	uri        string
+	constraint string
+	options    keyValueFlag
+	filters    keyValueFlag
+	json       bool
+}
+
+func (f *sourceFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.config, "config", "", "path to a YAML or JSON file with the source configuration")
+	fs.StringVar(&f.uri, "uri", "", "location of the repository (overrides config)")
+	fs.StringVar(&f.constraint, "constraint", "", "version constraint (overrides config `version`)")
+	fs.Var(&f.options, "option", "repository option as KEY=VALUE (repeatable)")
+	fs.Var(&f.filters, "filter", "filter as TYPE=VALUE (repeatable)")
+	fs.BoolVar(&f.json, "json", false, "print machine-readable JSON")
+}
+
+func (f sourceFlags) Source() (api.Source, error) {
+	var source api.Source
+
+	if f.config != "" {
+		configBytes, err := ioutil.ReadFile(f.config)
+		if err != nil {
+			return source, errors.Wrap(err, "reading config")
+		}
+
+		// YAML is decoded generically and re-encoded so the JSON field names and
+		// unmarshalers of the resource configuration apply
+		var config interface{}
+
+		err = yaml.Unmarshal(configBytes, &config)
+		if err != nil {
+			return source, errors.Wrap(err, "parsing config")
+		}
+
+		// support using a full resource configuration (i.e. with `source` key)
+		if configMap, ok := config.(map[string]interface{}); ok {
+			if nested, ok := configMap["source"]; ok {
+				config = nested
+			}
+		}
+
+		configJSON, err := json.Marshal(config)
+		if err != nil {
+			return source, errors.Wrap(err, "converting config")
+		}
+
+		err = json.Unmarshal(configJSON, &source)
+		if err != nil {
+			return source, errors.Wrap(err, "parsing config")
+		}
+	}
+
+	if f.uri != "" {
+		source.URI = f.uri
+	}
+
+	if f.constraint != "" {
+		source.Version = f.constraint
+	}
+
+	if len(f.options) > 0 && source.Options == nil {
+		source.Options = map[string]interface{}{}
+	}
+
+	f.options.Each(func(key, value string) {
+		source.Options[key] = value
+	})
+
+	f.filters.Each(func(key, value string) {
+		source.Filters = append(source.Filters, map[string]string{key: value})
+	})
+
+	err := api.ResolveSource(&source)
+	if err != nil {
+		return source, errors.Wrap(err, "resolving source")
+	}
+
+	if source.URI == "" {
+		return source, errors.New("missing repository uri (use --uri or --config)")
+	}
+
+	api.MigrateSource(&source)
+
+	return source, nil
+}
+
+func parseFlags(fs *flag.FlagSet, args []string, argNames ...string) ([]string, error) {
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [flags] %s\n\nFlags:\n", os.Args[0], fs.Name(), strings.Join(argNames, " "))
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var required int
+
+	for _, argName := range argNames {
+		if !strings.HasPrefix(argName, "[") {
+			required++
+		}
+	}
+
+	if fs.NArg() < required || fs.NArg() > len(argNames) {
+		fs.Usage()
+
+		return nil, fmt.Errorf("expected %d argument(s)", len(argNames))
+	}
+
+	return fs.Args(), nil
+}
+
+func loadRepository(s api.Source) (source.Source, error) {
+	repo, err := factory.GetRepository(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating source")
+	}
+
+	err = repo.Load()
+	if err != nil {
+		return nil, errors.Wrap(err, "loading repository")
+	}
+
+	return repo, nil
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink-repository-resource/internal/fetch"
+	"github.com/pkg/errors"
+)
+
+func runFetch(args []string) error {
+	var flags sourceFlags
+	var include stringsFlag
+	var options fetch.Options
+	var unpackFiles stringsFlag
+
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	flags.register(fs)
+	fs.Var(&include, "include", "only download files matching this glob (repeatable)")
+	fs.BoolVar(&options.SkipDownload, "skip-download", false, "only resolve the version; do not download files")
+	fs.StringVar(&options.Path, "path", "", "template for the local path of each file")
+	fs.StringVar(&options.Symlink, "symlink", "", "template for a symlink pointing to each file")
+	fs.BoolVar(&options.Unpack.Enabled, "unpack", false, "unpack downloaded archives")
+	fs.Var(&unpackFiles, "unpack-file", "only unpack files matching this glob (repeatable)")
+	fs.IntVar(&options.Unpack.StripComponents, "strip-components", 0, "leading path components to strip when unpacking")
+	fs.BoolVar(&options.Unpack.KeepArchive, "keep-archive", false, "keep archives after unpacking")
+
+	args, err := parseFlags(fs, args, "VERSION", "DIR")
+	if err != nil {
+		return err
+	}
+
+	options.IncludeFiles = include
+	options.Unpack.Files = unpackFiles
+
+	source, err := flags.Source()
+	if err != nil {
+		return err
+	}
+
+	destination, err := filepath.Abs(args[1])
+	if err != nil {
+		return errors.Wrap(err, "expanding destination")
+	}
+
+	err = os.MkdirAll(destination, 0755)
+	if err != nil {
+		return errors.Wrap(err, "creating destination")
+	}
+
+	meta4, err := fetch.FindMetalink(source, args[0])
+	if err != nil {
+		return err
+	}
+
+	result, err := fetch.Fetch(source, meta4.Metalink, destination, options)
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return printJSON(struct {
+			Version  api.Version    `json:"version"`
+			Metadata []api.Metadata `json:"metadata"`
+		}{
+			Version:  api.Version{Version: meta4.Metalink.Files[0].Version},
+			Metadata: result.Metadata(),
+		})
+	}
+
+	fmt.Printf("fetched %s: %d file(s), %d byte(s)\n", meta4.Metalink.Files[0].Version, result.Files, result.Bytes)
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/dpb587/metalink-repository-resource/factory"
+	"github.com/dpb587/metalink-repository-resource/internal/gc"
+	"github.com/dpb587/metalink-repository-resource/internal/storage"
+	filter_and "github.com/dpb587/metalink/repository/filter/and"
+	"github.com/pkg/errors"
+)
+
+func runGC(args []string) error {
+	var flags sourceFlags
+	var options gc.Options
+
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	flags.register(fs)
+	fs.BoolVar(&options.Delete, "delete", false, "delete unreferenced objects (default is a dry run)")
+	fs.DurationVar(&options.MinAge, "min-age", 24*time.Hour, "ignore objects modified more recently than this")
+	fs.BoolVar(&options.AllowRoot, "allow-root", false, "allow destinations whose static prefix is the root of a bucket")
+
+	_, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	source, err := flags.Source()
+	if err != nil {
+		return err
+	}
+
+	if len(source.MirrorFiles) == 0 {
+		return errors.New("no mirror_files configured")
+	}
+
+	// every metalink references blobs, so filters must not be applied here
+	source.Filters = nil
+	options.RepositoryURI = source.URI
+
+	repo, err := loadRepository(source)
+	if err != nil {
+		return err
+	}
+
+	metalinks, err := repo.Filter(filter_and.NewFilter())
+	if err != nil {
+		return errors.Wrap(err, "filtering metalinks")
+	}
+
+	report, err := gc.Collect(metalinks, source.MirrorFiles, func(uri string) (storage.Storage, error) {
+		return factory.GetStorage(source.URLHandlers, uri)
+	}, options)
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return printJSON(report)
+	}
+
+	var bytes uint64
+
+	for _, object := range report.Unreferenced {
+		bytes += object.Size
+
+		if object.Deleted {
+			fmt.Printf("deleted %s\n", object.URI)
+		} else {
+			fmt.Printf("unreferenced %s\n", object.URI)
+		}
+	}
+
+	fmt.Printf("found %d unreferenced of %d object(s) (%d bytes)\n", len(report.Unreferenced), report.Objects, bytes)
+
+	return nil
+}
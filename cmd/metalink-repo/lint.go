@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/dpb587/metalink-repository-resource/factory"
+	"github.com/dpb587/metalink-repository-resource/internal/lint"
+	filter_and "github.com/dpb587/metalink/repository/filter/and"
+	"github.com/pkg/errors"
+)
+
+func runLint(args []string) error {
+	var flags sourceFlags
+	var options lint.Options
+
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.register(fs)
+	fs.BoolVar(&options.CheckURLs, "check-urls", false, "check every URL exists and has the expected size")
+	fs.BoolVar(&options.Verify, "verify", false, "download every URL and verify hashes (implies --check-urls)")
+
+	_, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	source, err := flags.Source()
+	if err != nil {
+		return err
+	}
+
+	repo, err := factory.GetRepository(source)
+	if err != nil {
+		return errors.Wrap(err, "creating source")
+	}
+
+	andFilter := filter_and.NewFilter()
+
+	err = source.ApplyFilter(&andFilter)
+	if err != nil {
+		return errors.Wrap(err, "creating filter")
+	}
+
+	urlLoader, err := factory.GetURLLoader(source.URLHandlers, source.Transfer)
+	if err != nil {
+		return errors.Wrap(err, "loading url handlers")
+	}
+
+	report, err := lint.Lint(repo, andFilter, urlLoader, options)
+	if err != nil {
+		return errors.Wrap(err, "linting repository")
+	}
+
+	if flags.json {
+		err = printJSON(report)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, problem := range report.Problems {
+			fmt.Printf("%s: %s", problem.Type, problem.Message)
+
+			for _, detail := range [][2]string{{"path", problem.Path}, {"version", problem.Version}, {"file", problem.File}, {"url", problem.URL}} {
+				if detail[1] != "" {
+					fmt.Printf(" %s=%s", detail[0], detail[1])
+				}
+			}
+
+			fmt.Println()
+		}
+
+		fmt.Printf("checked %d metalink(s), %d file(s), %d url(s)\n", report.Metalinks, report.Files, report.URLs)
+	}
+
+	if len(report.Problems) > 0 {
+		return fmt.Errorf("found %d problem(s)", len(report.Problems))
+	}
+
+	return nil
+}
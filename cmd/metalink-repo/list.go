@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/dpb587/metalink-repository-resource/api"
+	filter_and "github.com/dpb587/metalink/repository/filter/and"
+	"github.com/dpb587/metalink/repository/sorter"
+	sorter_fileversion "github.com/dpb587/metalink/repository/sorter/fileversion"
+	"github.com/pkg/errors"
+)
+
+func runList(args []string) error {
+	var flags sourceFlags
+
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	flags.register(fs)
+
+	_, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	source, err := flags.Source()
+	if err != nil {
+		return err
+	}
+
+	repo, err := loadRepository(source)
+	if err != nil {
+		return err
+	}
+
+	andFilter := filter_and.NewFilter()
+
+	err = source.ApplyFilter(&andFilter)
+	if err != nil {
+		return errors.Wrap(err, "creating filter")
+	}
+
+	metalinks, err := repo.Filter(andFilter)
+	if err != nil {
+		return errors.Wrap(err, "filtering metalinks")
+	}
+
+	sorter.Sort(metalinks, sorter_fileversion.Sorter{})
+
+	versions := []api.Version{}
+
+	for _, meta4 := range metalinks {
+		versions = append(versions, api.Version{Version: meta4.Metalink.Files[0].Version})
+	}
+
+	if flags.json {
+		return printJSON(versions)
+	}
+
+	for _, version := range versions {
+		fmt.Println(version.Version)
+	}
+
+	return nil
+}
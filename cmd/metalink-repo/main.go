@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dpb587/metalink-repository-resource/api"
+)
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{
+		name:        "list",
+		usage:       "list [flags]",
+		description: "list versions in the repository (newest first)",
+		run:         runList,
+	},
+	{
+		name:        "show",
+		usage:       "show [flags] VERSION",
+		description: "show the metalink of a version",
+		run:         runShow,
+	},
+	{
+		name:        "fetch",
+		usage:       "fetch [flags] VERSION DIR",
+		description: "download and verify the files of a version",
+		run:         runFetch,
+	},
+	{
+		name:        "publish",
+		usage:       "publish [flags] [METALINK]",
+		description: "publish a metalink (or local files) to the repository",
+		run:         runPublish,
+	},
+	{
+		name:        "lint",
+		usage:       "lint [flags]",
+		description: "report problems with metalinks and (optionally) their URLs",
+		run:         runLint,
+	},
+	{
+		name:        "gc",
+		usage:       "gc [flags]",
+		description: "find (or delete) mirrored blobs no longer referenced",
+		run:         runGC,
+	},
+	{
+		name:        "remirror",
+		usage:       "remirror [flags]",
+		description: "upload files of existing versions to all mirror destinations",
+		run:         runRemirror,
+	},
+	{
+		name:        "site",
+		usage:       "site [flags] OUTPUT",
+		description: "render a static site (HTML, index.json, Atom feed) to a directory or URL",
+		run:         runSite,
+	},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s COMMAND [flags] [args]\n\nCommands:\n", os.Args[0])
+
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-30s %s\n", cmd.usage, cmd.description)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nRun `%s COMMAND -h` for command flags.\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+
+		err := cmd.run(os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, api.Redact(fmt.Sprintf("%s: %s", cmd.name, err)))
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if os.Args[1] != "-h" && os.Args[1] != "--help" && os.Args[1] != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", os.Args[1])
+	}
+
+	usage()
+	os.Exit(1)
+}
@@ -0,0 +1,885 @@
+package main_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/internal/oci"
+	pkgtesting "github.com/dpb587/metalink-repository-resource/internal/testing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("Main", func() {
+	runCLI := func(args ...string) (string, int) {
+		command := exec.Command(cli, args...)
+
+		stdout := &bytes.Buffer{}
+
+		session, err := gexec.Start(command, stdout, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		session.Wait(time.Minute)
+
+		return stdout.String(), session.ExitCode()
+	}
+
+	var tmpdir string
+
+	BeforeEach(func() {
+		var err error
+
+		tmpdir, err = ioutil.TempDir("", "metalink-repository-resource-cli")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if tmpdir != "" {
+			Expect(os.RemoveAll(tmpdir)).NotTo(HaveOccurred())
+		}
+	})
+
+	Describe("list", func() {
+		It("lists versions newest first", func() {
+			stdout, exitCode := runCLI("list", "--uri", fmt.Sprintf("file://%s/component", repositorydir))
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("2.0.0\n1.1.0\n1.0.0\n"))
+		})
+
+		It("applies constraints and prints json", func() {
+			stdout, exitCode := runCLI("list", "--uri", fmt.Sprintf("file://%s/component", repositorydir), "--constraint", "1.x", "--json")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(MatchJSON(`[{"version":"1.1.0"},{"version":"1.0.0"}]`))
+		})
+
+		It("reads the source from a YAML config", func() {
+			config := filepath.Join(tmpdir, "config.yml")
+			Expect(ioutil.WriteFile(config, []byte(fmt.Sprintf(`
+source:
+  uri: file://%s/component
+  filters:
+  - repositorypath: "v1.1.*"
+`, repositorydir)), 0644)).To(Succeed())
+
+			stdout, exitCode := runCLI("list", "--config", config)
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("1.1.0\n"))
+		})
+
+		It("fails without a repository", func() {
+			_, exitCode := runCLI("list")
+			Expect(exitCode).NotTo(Equal(0))
+		})
+	})
+
+	Describe("show", func() {
+		It("shows a version", func() {
+			stdout, exitCode := runCLI("show", "--uri", fmt.Sprintf("file://%s/component", repositorydir), "1.1.0")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(ContainSubstring("version: 1.1.0\n"))
+			Expect(stdout).To(ContainSubstring("path: v1.1.0.meta4\n"))
+			Expect(stdout).To(ContainSubstring("  test\n"))
+		})
+
+		It("prints json", func() {
+			stdout, exitCode := runCLI("show", "--uri", fmt.Sprintf("file://%s/component", repositorydir), "--json", "2.0.0")
+			Expect(exitCode).To(Equal(0))
+
+			var result map[string]interface{}
+			Expect(json.Unmarshal([]byte(stdout), &result)).To(Succeed())
+			Expect(result["files"].([]interface{})[0].(map[string]interface{})["version"]).To(Equal("2.0.0"))
+			Expect(result["repository_reference"].(map[string]interface{})["path"]).To(Equal("v2.0.0.meta4"))
+		})
+
+		It("fails for unknown versions", func() {
+			_, exitCode := runCLI("show", "--uri", fmt.Sprintf("file://%s/component", repositorydir), "9.9.9")
+			Expect(exitCode).NotTo(Equal(0))
+		})
+	})
+
+	Describe("publish and fetch", func() {
+		It("publishes local files and fetches them again", func() {
+			storagedir := filepath.Join(tmpdir, "storage")
+			Expect(os.MkdirAll(storagedir, 0755)).To(Succeed())
+
+			artifact := filepath.Join(tmpdir, "artifact.txt")
+			Expect(ioutil.WriteFile(artifact, []byte("artifact content"), 0644)).To(Succeed())
+
+			config := filepath.Join(tmpdir, "config.yml")
+			Expect(ioutil.WriteFile(config, []byte(fmt.Sprintf(`
+uri: file://%s/component
+mirror_files:
+- destination: file://%s/{{.Name}}
+`, repositorydir, storagedir)), 0644)).To(Succeed())
+
+			stdout, exitCode := runCLI("publish", "--config", config, "--file", artifact, "--file-version", "3.0.0")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("published 3.0.0 as v3.0.0.meta4\n"))
+
+			By("storing the metalink", func() {
+				meta4Bytes, err := ioutil.ReadFile(filepath.Join(repositorydir, "component", "v3.0.0.meta4"))
+				Expect(err).NotTo(HaveOccurred())
+
+				var meta4 metalink.Metalink
+				Expect(metalink.Unmarshal(meta4Bytes, &meta4)).To(Succeed())
+				Expect(meta4.Files).To(HaveLen(1))
+				Expect(meta4.Files[0].URLs).To(HaveLen(1))
+				Expect(meta4.Files[0].URLs[0].URL).To(Equal(fmt.Sprintf("file://%s/artifact.txt", storagedir)))
+			})
+
+			downloaddir := filepath.Join(tmpdir, "download")
+
+			stdout, exitCode = runCLI("fetch", "--config", config, "--json", "3.0.0", downloaddir)
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(MatchJSON(fmt.Sprintf(`{"version":{"version":"3.0.0"},"metadata":[{"name":"files","value":"1"},{"name":"bytes","value":"16"},{"name":"mirror","value":"file://%s/artifact.txt"}]}`, storagedir)))
+
+			content, err := ioutil.ReadFile(filepath.Join(downloaddir, "artifact.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("artifact content"))
+		})
+
+		It("publishes an existing metalink with a custom name", func() {
+			meta4file := filepath.Join(tmpdir, "input.meta4")
+			Expect(ioutil.WriteFile(meta4file, []byte(`{"files":[{"name":"fake","version":"4.0.0"}]}`), 0644)).To(Succeed())
+
+			_, exitCode := runCLI("publish", "--uri", fmt.Sprintf("file://%s/component", repositorydir), "--rename", "release-{{.Version}}.meta4", meta4file)
+			Expect(exitCode).To(Equal(0))
+			Expect(filepath.Join(repositorydir, "component", "release-4.0.0.meta4")).To(BeARegularFile())
+		})
+
+		It("fails to fetch files failing verification", func() {
+			storagedir := filepath.Join(tmpdir, "storage")
+			Expect(os.MkdirAll(storagedir, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(storagedir, "bad.txt"), []byte("tampered"), 0644)).To(Succeed())
+
+			hash := sha512.Sum512([]byte("original"))
+			Expect(ioutil.WriteFile(filepath.Join(repositorydir, "component", "v5.0.0.meta4"), []byte(fmt.Sprintf(`{"files":[{"name":"bad.txt","version":"5.0.0","size":8,"hashes":[{"type":"sha-512","hash":"%x"}],"urls":[{"url":"file://%s/bad.txt"}]}]}`, hash, storagedir)), 0644)).To(Succeed())
+
+			_, exitCode := runCLI("fetch", "--uri", fmt.Sprintf("file://%s/component", repositorydir), "5.0.0", filepath.Join(tmpdir, "download"))
+			Expect(exitCode).NotTo(Equal(0))
+		})
+	})
+
+	Describe("lint", func() {
+		var lintdir, storagedir string
+
+		writeMetalink := func(name, content string) {
+			Expect(ioutil.WriteFile(filepath.Join(lintdir, name), []byte(content), 0644)).To(Succeed())
+		}
+
+		validMetalink := func(version, name string, content []byte) string {
+			Expect(ioutil.WriteFile(filepath.Join(storagedir, name), content, 0644)).To(Succeed())
+
+			return fmt.Sprintf(`{"files":[{"name":"%s","version":"%s","size":%d,"hashes":[{"type":"sha-512","hash":"%x"}],"urls":[{"url":"file://%s/%s"}]}]}`, name, version, len(content), sha512.Sum512(content), storagedir, name)
+		}
+
+		lintReport := func(args ...string) (map[string]interface{}, int) {
+			stdout, exitCode := runCLI(append([]string{"lint", "--uri", fmt.Sprintf("file://%s", lintdir), "--json"}, args...)...)
+
+			var report map[string]interface{}
+			Expect(json.Unmarshal([]byte(stdout), &report)).To(Succeed())
+
+			return report, exitCode
+		}
+
+		problemTypes := func(report map[string]interface{}) []string {
+			var types []string
+
+			for _, problem := range report["problems"].([]interface{}) {
+				problemMap := problem.(map[string]interface{})
+				if path, ok := problemMap["path"]; ok {
+					types = append(types, fmt.Sprintf("%s %s", problemMap["type"], path))
+				} else {
+					types = append(types, problemMap["type"].(string))
+				}
+			}
+
+			return types
+		}
+
+		BeforeEach(func() {
+			lintdir = filepath.Join(tmpdir, "repository")
+			Expect(os.MkdirAll(lintdir, 0755)).To(Succeed())
+
+			storagedir = filepath.Join(tmpdir, "storage")
+			Expect(os.MkdirAll(storagedir, 0755)).To(Succeed())
+		})
+
+		It("succeeds for a healthy repository", func() {
+			writeMetalink("v1.0.0.meta4", validMetalink("1.0.0", "one.txt", []byte("one")))
+			writeMetalink("v2.0.0.meta4", validMetalink("2.0.0", "two.txt", []byte("two")))
+
+			report, exitCode := lintReport("--verify")
+			Expect(exitCode).To(Equal(0))
+			Expect(report["metalinks"]).To(BeEquivalentTo(2))
+			Expect(report["urls"]).To(BeEquivalentTo(2))
+			Expect(report["problems"]).To(BeEmpty())
+		})
+
+		It("reports structural problems", func() {
+			writeMetalink("v1.0.0.meta4", validMetalink("1.0.0", "one.txt", []byte("one")))
+			writeMetalink("release-1.0.0.meta4", validMetalink("1.0.0", "one.txt", []byte("one")))
+			writeMetalink("empty.meta4", `{"files":[]}`)
+			writeMetalink("noversion.meta4", `{"files":[{"name":"a","urls":[{"url":"file:///a"}]}]}`)
+			writeMetalink("nourls.meta4", `{"files":[{"name":"a","version":"3.0.0"}]}`)
+
+			report, exitCode := lintReport()
+			Expect(exitCode).To(Equal(1))
+			Expect(problemTypes(report)).To(ConsistOf(
+				"duplicate-version release-1.0.0.meta4",
+				"duplicate-version v1.0.0.meta4",
+				"empty-files empty.meta4",
+				"missing-version noversion.meta4",
+				"missing-urls nourls.meta4",
+			))
+		})
+
+		It("reports dead urls and mismatched sizes", func() {
+			writeMetalink("v1.0.0.meta4", validMetalink("1.0.0", "one.txt", []byte("one")))
+			writeMetalink("v2.0.0.meta4", validMetalink("2.0.0", "two.txt", []byte("two")))
+
+			Expect(os.Remove(filepath.Join(storagedir, "one.txt"))).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(storagedir, "two.txt"), []byte("too long"), 0644)).To(Succeed())
+
+			report, exitCode := lintReport()
+			Expect(exitCode).To(Equal(0))
+
+			report, exitCode = lintReport("--check-urls")
+			Expect(exitCode).To(Equal(1))
+			Expect(problemTypes(report)).To(ConsistOf(
+				"dead-url v1.0.0.meta4",
+				"size-mismatch v2.0.0.meta4",
+			))
+		})
+
+		It("reports mismatched hashes", func() {
+			writeMetalink("v1.0.0.meta4", validMetalink("1.0.0", "one.txt", []byte("one")))
+
+			Expect(ioutil.WriteFile(filepath.Join(storagedir, "one.txt"), []byte("eno"), 0644)).To(Succeed())
+
+			_, exitCode := lintReport("--check-urls")
+			Expect(exitCode).To(Equal(0))
+
+			report, exitCode := lintReport("--verify")
+			Expect(exitCode).To(Equal(1))
+			Expect(problemTypes(report)).To(ConsistOf("hash-mismatch v1.0.0.meta4"))
+		})
+
+		It("reports unparsable metalinks", func() {
+			writeMetalink("v1.0.0.meta4", "<metalink")
+
+			report, exitCode := lintReport()
+			Expect(exitCode).To(Equal(1))
+			Expect(problemTypes(report)).To(ConsistOf("unparsable"))
+		})
+	})
+
+	Describe("gc", func() {
+		var storagedir, config string
+
+		BeforeEach(func() {
+			storagedir = filepath.Join(tmpdir, "storage")
+			Expect(os.MkdirAll(filepath.Join(storagedir, "blobs"), 0755)).To(Succeed())
+
+			config = filepath.Join(tmpdir, "config.yml")
+			Expect(ioutil.WriteFile(config, []byte(fmt.Sprintf(`
+uri: file://%s/component
+mirror_files:
+- destination: file://%s/blobs/{{.Name}}
+`, repositorydir, storagedir)), 0644)).To(Succeed())
+
+			for _, name := range []string{"kept.txt", "retracted.txt"} {
+				artifact := filepath.Join(tmpdir, name)
+				Expect(ioutil.WriteFile(artifact, []byte(name), 0644)).To(Succeed())
+
+				_, exitCode := runCLI("publish", "--config", config, "--file", artifact, "--file-version", fmt.Sprintf("9.0.0-%s", name[0:4]))
+				Expect(exitCode).To(Equal(0))
+			}
+
+			Expect(os.Remove(filepath.Join(repositorydir, "component", "v9.0.0-retr.meta4"))).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(storagedir, "outside.txt"), []byte("unrelated"), 0644)).To(Succeed())
+		})
+
+		It("reports unreferenced objects without deleting them", func() {
+			stdout, exitCode := runCLI("gc", "--config", config, "--min-age", "0s")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal(fmt.Sprintf("unreferenced file://%s/blobs/retracted.txt\nfound 1 unreferenced of 2 object(s) (13 bytes)\n", storagedir)))
+			Expect(filepath.Join(storagedir, "blobs", "retracted.txt")).To(BeARegularFile())
+		})
+
+		It("deletes unreferenced objects", func() {
+			stdout, exitCode := runCLI("gc", "--config", config, "--min-age", "0s", "--delete", "--json")
+			Expect(exitCode).To(Equal(0))
+
+			var report map[string]interface{}
+			Expect(json.Unmarshal([]byte(stdout), &report)).To(Succeed())
+			Expect(report["objects"]).To(BeEquivalentTo(2))
+			Expect(report["unreferenced"]).To(HaveLen(1))
+			Expect(report["unreferenced"].([]interface{})[0].(map[string]interface{})["deleted"]).To(BeTrue())
+
+			Expect(filepath.Join(storagedir, "blobs", "retracted.txt")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(storagedir, "blobs", "kept.txt")).To(BeARegularFile())
+			Expect(filepath.Join(storagedir, "outside.txt")).To(BeARegularFile())
+		})
+
+		It("ignores recently modified objects", func() {
+			stdout, exitCode := runCLI("gc", "--config", config, "--delete")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("found 0 unreferenced of 2 object(s) (0 bytes)\n"))
+			Expect(filepath.Join(storagedir, "blobs", "retracted.txt")).To(BeARegularFile())
+		})
+	})
+
+	Describe("remirror", func() {
+		var storagedir, config string
+
+		writeConfig := func(mirrors ...string) {
+			content := fmt.Sprintf("uri: file://%s/component\nmirror_files:\n", repositorydir)
+
+			for _, mirror := range mirrors {
+				Expect(os.MkdirAll(filepath.Join(storagedir, mirror), 0755)).To(Succeed())
+
+				content += fmt.Sprintf("- destination: file://%s/%s/{{.Name}}\n", storagedir, mirror)
+			}
+
+			Expect(ioutil.WriteFile(config, []byte(content), 0644)).To(Succeed())
+		}
+
+		readURLs := func(version string) []string {
+			meta4Bytes, err := ioutil.ReadFile(filepath.Join(repositorydir, "component", fmt.Sprintf("v%s.meta4", version)))
+			Expect(err).NotTo(HaveOccurred())
+
+			var meta4 metalink.Metalink
+			Expect(metalink.Unmarshal(meta4Bytes, &meta4)).To(Succeed())
+
+			var urls []string
+
+			for _, url := range meta4.Files[0].URLs {
+				urls = append(urls, url.URL)
+			}
+
+			return urls
+		}
+
+		BeforeEach(func() {
+			storagedir = filepath.Join(tmpdir, "storage")
+			config = filepath.Join(tmpdir, "config.yml")
+
+			writeConfig("a")
+
+			for _, version := range []string{"8.0.0", "8.1.0"} {
+				artifact := filepath.Join(tmpdir, fmt.Sprintf("artifact-%s.txt", version))
+				Expect(ioutil.WriteFile(artifact, []byte(version), 0644)).To(Succeed())
+
+				_, exitCode := runCLI("publish", "--config", config, "--file", artifact, "--file-version", version)
+				Expect(exitCode).To(Equal(0))
+			}
+
+			writeConfig("a", "b")
+		})
+
+		It("mirrors existing versions to new destinations", func() {
+			stdout, exitCode := runCLI("remirror", "--config", config, "--constraint", "8.x")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("v8.1.0.meta4: 1 url(s) added\nv8.0.0.meta4: 1 url(s) added\n"))
+
+			Expect(readURLs("8.0.0")).To(Equal([]string{
+				fmt.Sprintf("file://%s/a/artifact-8.0.0.txt", storagedir),
+				fmt.Sprintf("file://%s/b/artifact-8.0.0.txt", storagedir),
+			}))
+
+			content, err := ioutil.ReadFile(filepath.Join(storagedir, "b", "artifact-8.1.0.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("8.1.0"))
+
+			By("skipping destinations which are already mirrored", func() {
+				stdout, exitCode := runCLI("remirror", "--config", config, "--constraint", "8.x", "--json")
+				Expect(exitCode).To(Equal(0))
+				Expect(stdout).To(MatchJSON(`[{"path":"v8.1.0.meta4","version":"8.1.0","added":0},{"path":"v8.0.0.meta4","version":"8.0.0","added":0}]`))
+			})
+		})
+
+		It("only mirrors matching versions", func() {
+			_, exitCode := runCLI("remirror", "--config", config, "--constraint", "8.1.x")
+			Expect(exitCode).To(Equal(0))
+
+			Expect(readURLs("8.0.0")).To(HaveLen(1))
+			Expect(readURLs("8.1.0")).To(HaveLen(2))
+		})
+	})
+
+	Describe("site", func() {
+		It("renders a static site", func() {
+			Expect(ioutil.WriteFile(filepath.Join(repositorydir, "component", "v2.0.0.meta4"), []byte(`{"published":"2020-01-02T03:04:05Z","files":[{"name":"test","version":"2.0.0","size":4,"hashes":[{"type":"sha-256","hash":"abc123"}],"urls":[{"url":"https://example.com/test"},{"url":"s3://s3.amazonaws.com/bucket/test"}]}]}`), 0644)).To(Succeed())
+
+			outputdir := filepath.Join(tmpdir, "site")
+
+			stdout, exitCode := runCLI("site", "--uri", fmt.Sprintf("file://%s/component", repositorydir), "--title", "Component <Releases>", "--base-url", "https://example.com/releases/", outputdir)
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("wrote feed.atom\nwrote index.html\nwrote index.json\nwrote versions/1.0.0.html\nwrote versions/1.1.0.html\nwrote versions/2.0.0.html\n"))
+
+			By("writing index.json", func() {
+				indexBytes, err := ioutil.ReadFile(filepath.Join(outputdir, "index.json"))
+				Expect(err).NotTo(HaveOccurred())
+
+				var index map[string]interface{}
+				Expect(json.Unmarshal(indexBytes, &index)).To(Succeed())
+
+				versions := index["versions"].([]interface{})
+				Expect(versions).To(HaveLen(3))
+				Expect(versions[0]).To(Equal(map[string]interface{}{
+					"version":   "2.0.0",
+					"path":      "v2.0.0.meta4",
+					"page":      "versions/2.0.0.html",
+					"published": "2020-01-02T03:04:05Z",
+					"files": []interface{}{
+						map[string]interface{}{
+							"name":   "test",
+							"size":   float64(4),
+							"hashes": map[string]interface{}{"sha-256": "abc123"},
+							"urls":   []interface{}{"https://example.com/test", "s3://s3.amazonaws.com/bucket/test"},
+						},
+					},
+				}))
+				Expect(versions[1].(map[string]interface{})["version"]).To(Equal("1.1.0"))
+			})
+
+			By("writing html pages", func() {
+				indexBytes, err := ioutil.ReadFile(filepath.Join(outputdir, "index.html"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(indexBytes)).To(ContainSubstring("<h1>Component &lt;Releases&gt;</h1>"))
+				Expect(string(indexBytes)).To(ContainSubstring(`<li><a href="versions/2.0.0.html">2.0.0</a> (2020-01-02)</li>`))
+
+				versionBytes, err := ioutil.ReadFile(filepath.Join(outputdir, "versions", "2.0.0.html"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(versionBytes)).To(ContainSubstring("<dt>sha-256</dt>\n<dd><code>abc123</code></dd>"))
+				Expect(string(versionBytes)).To(ContainSubstring(`<li><a href="https://example.com/test">https://example.com/test</a></li>`))
+				Expect(string(versionBytes)).To(ContainSubstring(`<li><code>s3://s3.amazonaws.com/bucket/test</code></li>`))
+			})
+
+			By("writing an atom feed", func() {
+				feedBytes, err := ioutil.ReadFile(filepath.Join(outputdir, "feed.atom"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(feedBytes)).To(ContainSubstring(`<feed xmlns="http://www.w3.org/2005/Atom">`))
+				Expect(string(feedBytes)).To(ContainSubstring(`<link rel="self" href="https://example.com/releases/feed.atom"></link>`))
+				Expect(string(feedBytes)).To(MatchRegexp(`(?s)<entry>\s*<title>2.0.0</title>\s*<id>https://example.com/releases#2.0.0</id>\s*<updated>2020-01-02T03:04:05Z</updated>\s*<link href="https://example.com/releases/versions/2.0.0.html"></link>.*<title>1.1.0</title>.*<title>1.0.0</title>`))
+			})
+		})
+
+		It("never publishes credentials of the repository", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("token") != "fake-index-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+
+					return
+				}
+
+				w.Write([]byte(`{"releases":[{"tag":"1.0.0","assets":[{"name":"test","href":"https://example.com/test"}]}]}`))
+			}))
+			defer server.Close()
+
+			uri := fmt.Sprintf("jsonindex+http://fake-user:fake-password@%s/index.json?channel=stable&token=fake-index-token", server.Listener.Addr())
+			outputdir := filepath.Join(tmpdir, "site")
+
+			_, exitCode := runCLI("site", "--uri", uri, "--option", "releases=$.releases[*]", "--option", "version=$.tag", "--option", "files=$.assets[*]", "--option", "file_name=$.name", "--option", "file_url=$.href", outputdir)
+			Expect(exitCode).To(Equal(0))
+
+			for _, path := range []string{"index.json", "index.html", "feed.atom"} {
+				content, err := ioutil.ReadFile(filepath.Join(outputdir, path))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(fmt.Sprintf("http://%s/index.json?channel=stable", server.Listener.Addr())), path)
+				Expect(string(content)).NotTo(ContainSubstring("fake-password"), path)
+				Expect(string(content)).NotTo(ContainSubstring("fake-index-token"), path)
+			}
+		})
+	})
+
+	Describe("oci repositories", func() {
+		var registry *pkgtesting.Registry
+
+		BeforeEach(func() {
+			registry = pkgtesting.NewRegistry()
+			registry.Username = "fake-user"
+			registry.Password = "fake-password"
+			registry.PageSize = 2
+		})
+
+		AfterEach(func() {
+			registry.Close()
+		})
+
+		It("publishes and loads metalinks as tagged artifacts", func() {
+			repository := fmt.Sprintf("oci://%s/team/metalinks", registry.Host())
+			auth := []string{"--option", "username=fake-user", "--option", "password=fake-password", "--option", "plain_http=true"}
+
+			for _, version := range []string{"1.0.0", "1.1.0+build.5", "2.0.0"} {
+				meta4file := filepath.Join(tmpdir, "input.meta4")
+				Expect(ioutil.WriteFile(meta4file, []byte(fmt.Sprintf(`{"files":[{"name":"fake","version":"%s"}]}`, version)), 0644)).To(Succeed())
+
+				_, exitCode := runCLI(append(append([]string{"publish", "--uri", repository}, auth...), meta4file)...)
+				Expect(exitCode).To(Equal(0))
+			}
+
+			stdout, exitCode := runCLI(append([]string{"list", "--uri", repository}, auth...)...)
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("2.0.0\n1.1.0+build.5\n1.0.0\n"))
+
+			stdout, exitCode = runCLI(append(append([]string{"show", "--uri", repository}, auth...), "1.1.0+build.5")...)
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(ContainSubstring("path: v1.1.0+build.5.meta4\n"))
+
+			By("tagging each version", func() {
+				client := oci.NewClient(http.DefaultClient, registry.Host(), oci.Options{Username: "fake-user", Password: "fake-password", PlainHTTP: true})
+
+				tags, err := client.ListTags("team/metalinks")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tags).To(Equal([]string{"v1.0.0", "v1.1.0_build.5", "v2.0.0"}))
+
+				manifest, err := client.GetManifest("team/metalinks", "v2.0.0")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest.ArtifactType).To(Equal("application/vnd.dpb587.metalink.v1"))
+				Expect(manifest.Layers).To(HaveLen(1))
+				Expect(manifest.Layers[0].MediaType).To(Equal("application/metalink4+xml"))
+				Expect(manifest.Layers[0].Annotations).To(HaveKeyWithValue("org.opencontainers.image.title", "v2.0.0.meta4"))
+			})
+		})
+
+		It("loads empty repositories", func() {
+			stdout, exitCode := runCLI("list", "--uri", fmt.Sprintf("oci://%s/empty", registry.Host()), "--option", "username=fake-user", "--option", "password=fake-password", "--option", "plain_http=true")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(BeEmpty())
+		})
+
+		It("fails with invalid credentials", func() {
+			_, exitCode := runCLI("list", "--uri", fmt.Sprintf("oci://%s/team/metalinks", registry.Host()), "--option", "username=fake-user", "--option", "password=wrong", "--option", "plain_http=true")
+			Expect(exitCode).NotTo(Equal(0))
+		})
+	})
+
+	Describe("jsonindex repositories", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[
+  {"version": "0.9.0", "url": "https://vendor.example.com/0.9.0/tool.tgz"},
+  {"version": "1.0.0", "url": "https://vendor.example.com/1.0.0/tool.tgz", "size": 1234}
+]`))
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("lists versions of the index", func() {
+			stdout, exitCode := runCLI("list", "--uri", fmt.Sprintf("jsonindex+%s/index.json", server.URL), "--option", "version=$.version", "--option", "file_url=$.url")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("1.0.0\n0.9.0\n"))
+
+			stdout, exitCode = runCLI("show", "--uri", fmt.Sprintf("jsonindex+%s/index.json", server.URL), "--option", "version=$.version", "--option", "file_url=$.url", "1.0.0")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(ContainSubstring("tool.tgz"))
+			Expect(stdout).To(ContainSubstring("https://vendor.example.com/1.0.0/tool.tgz"))
+		})
+
+		It("refuses to publish", func() {
+			metalinkPath := filepath.Join(tmpdir, "v1.1.0.meta4")
+			Expect(ioutil.WriteFile(metalinkPath, []byte(`<metalink xmlns="urn:ietf:params:xml:ns:metalink"><file name="tool.tgz"><version>1.1.0</version></file></metalink>`), 0644)).To(Succeed())
+
+			_, exitCode := runCLI("publish", "--uri", fmt.Sprintf("jsonindex+%s/index.json", server.URL), "--option", "version=$.version", "--option", "file_url=$.url", metalinkPath)
+			Expect(exitCode).NotTo(Equal(0))
+		})
+	})
+
+	Describe("github repositories", func() {
+		var github *pkgtesting.GitHub
+
+		BeforeEach(func() {
+			github = pkgtesting.NewGitHub()
+			github.PageSize = 2
+
+			for _, release := range []pkgtesting.GitHubRelease{
+				{TagName: "v1.0.0", Assets: map[string][]byte{"tool.tgz": []byte("1.0.0")}},
+				{TagName: "v1.1.0", Digests: true, Assets: map[string][]byte{"tool.tgz": []byte("1.1.0"), "SHA512SUMS": []byte(fmt.Sprintf("%x *tool.tgz\n", sha512.Sum512([]byte("1.1.0"))))}},
+				{TagName: "v1.2.0-rc.1", Prerelease: true, Assets: map[string][]byte{"tool.tgz": []byte("1.2.0-rc.1")}},
+				{TagName: "v2.0.0", Draft: true, Assets: map[string][]byte{"tool.tgz": []byte("2.0.0")}},
+				{TagName: "v3.0.0"},
+			} {
+				release.PublishedAt = time.Now()
+				github.AddRelease("vendor", "tool", release)
+			}
+		})
+
+		AfterEach(func() {
+			github.Close()
+		})
+
+		It("lists published releases with assets", func() {
+			stdout, exitCode := runCLI("list", "--uri", "github://vendor/tool", "--option", "api_url="+github.APIURL())
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("1.1.0\n1.0.0\n"))
+		})
+
+		It("optionally lists prereleases", func() {
+			stdout, exitCode := runCLI("list", "--uri", "github://vendor/tool", "--option", "api_url="+github.APIURL(), "--option", "prereleases=true")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("1.2.0-rc.1\n1.1.0\n1.0.0\n"))
+		})
+
+		It("uses digests and checksum assets for hashes", func() {
+			stdout, exitCode := runCLI("show", "--uri", "github://vendor/tool", "--option", "api_url="+github.APIURL(), "--json", "1.1.0")
+			Expect(exitCode).To(Equal(0))
+
+			var meta4 metalink.Metalink
+			Expect(json.Unmarshal([]byte(stdout), &meta4)).To(Succeed())
+			Expect(meta4.Files).To(HaveLen(1))
+			Expect(meta4.Files[0].Name).To(Equal("tool.tgz"))
+			Expect(meta4.Files[0].Size).To(Equal(uint64(5)))
+			Expect(meta4.Files[0].URLs[0].URL).To(Equal(fmt.Sprintf("%s/vendor/tool/releases/download/v1.1.0/tool.tgz", github.URL)))
+			Expect(meta4.Files[0].Hashes).To(ConsistOf(
+				metalink.Hash{Type: metalink.HashTypeSHA256, Hash: fmt.Sprintf("%x", sha256.Sum256([]byte("1.1.0")))},
+				metalink.Hash{Type: metalink.HashTypeSHA512, Hash: fmt.Sprintf("%x", sha512.Sum512([]byte("1.1.0")))},
+			))
+		})
+
+		It("authenticates with tokens", func() {
+			github.Token = "fake-token"
+
+			_, exitCode := runCLI("list", "--uri", "github://vendor/tool", "--option", "api_url="+github.APIURL())
+			Expect(exitCode).NotTo(Equal(0))
+
+			stdout, exitCode := runCLI("list", "--uri", "github://vendor/tool", "--option", "api_url="+github.APIURL(), "--option", "token=fake-token")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("1.1.0\n1.0.0\n"))
+		})
+	})
+
+	Describe("git repositories", func() {
+		var remote *pkgtesting.GitRemote
+		var cacheDir string
+
+		metalinkXML := func(version string) string {
+			return fmt.Sprintf(`<metalink xmlns="urn:ietf:params:xml:ns:metalink"><file name="tool.tgz"><version>%s</version><size>1</size><url>https://example.com/tool.tgz</url></file></metalink>`, version)
+		}
+
+		cloneDir := func() string {
+			matches, err := filepath.Glob(filepath.Join(cacheDir, "metalink-git-source-*-1"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matches).To(HaveLen(1))
+
+			return matches[0]
+		}
+
+		BeforeEach(func() {
+			var err error
+
+			remote, err = pkgtesting.NewGitRemote("master")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remote.Commit(map[string]string{
+				"docs/index.md":             "metalinks",
+				"metalinks/v1/v1.0.0.meta4": metalinkXML("1.0.0"),
+				"metalinks/v2/v2.0.0.meta4": metalinkXML("2.0.0"),
+			}, "add versions")).To(Succeed())
+			Expect(remote.Commit(map[string]string{
+				"metalinks/v1/v1.1.0.meta4": metalinkXML("1.1.0"),
+			}, "add 1.1.0")).To(Succeed())
+
+			cacheDir = filepath.Join(tmpdir, "cache")
+			Expect(os.MkdirAll(cacheDir, 0700)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(remote.Close()).To(Succeed())
+		})
+
+		It("clones shallowly", func() {
+			stdout, exitCode := runCLI("list", "--uri", remote.URI("metalinks"), "--option", "cache_dir="+cacheDir, "--option", "depth=1")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("2.0.0\n1.1.0\n1.0.0\n"))
+
+			commits, err := exec.Command("git", "-C", cloneDir(), "rev-list", "--count", "HEAD").Output()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(commits)).To(Equal("1\n"))
+		})
+
+		It("checks out the source path only", func() {
+			stdout, exitCode := runCLI("list", "--uri", remote.URI("metalinks"), "--option", "cache_dir="+cacheDir, "--option", "sparse_checkout=true")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("2.0.0\n1.1.0\n1.0.0\n"))
+
+			Expect(filepath.Join(cloneDir(), "metalinks", "v2", "v2.0.0.meta4")).To(BeAnExistingFile())
+			Expect(filepath.Join(cloneDir(), "docs")).NotTo(BeAnExistingFile())
+		})
+
+		It("checks out the repositorypath filter prefix only", func() {
+			stdout, exitCode := runCLI("list", "--uri", remote.URI("metalinks"), "--option", "cache_dir="+cacheDir, "--option", "sparse_checkout=true", "--filter", "repositorypath=v1/*")
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("1.1.0\n1.0.0\n"))
+
+			Expect(filepath.Join(cloneDir(), "metalinks", "v1", "v1.0.0.meta4")).To(BeAnExistingFile())
+			Expect(filepath.Join(cloneDir(), "metalinks", "v2")).NotTo(BeAnExistingFile())
+
+			By("checking out everything once sparse checkouts are disabled", func() {
+				stdout, exitCode := runCLI("list", "--uri", remote.URI("metalinks"), "--option", "cache_dir="+cacheDir)
+				Expect(exitCode).To(Equal(0))
+				Expect(stdout).To(Equal("2.0.0\n1.1.0\n1.0.0\n"))
+
+				Expect(filepath.Join(cloneDir(), "docs", "index.md")).To(BeAnExistingFile())
+			})
+		})
+
+		It("fetches cached clones incrementally", func() {
+			stdout, exitCode := runCLI("list", "--uri", remote.URI("metalinks"), "--option", "cache_dir="+cacheDir)
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("2.0.0\n1.1.0\n1.0.0\n"))
+
+			marker := filepath.Join(cloneDir(), ".git", "cached")
+			Expect(ioutil.WriteFile(marker, []byte("cached"), 0644)).To(Succeed())
+
+			Expect(remote.Commit(map[string]string{
+				"metalinks/v2/v2.1.0.meta4": metalinkXML("2.1.0"),
+			}, "add 2.1.0")).To(Succeed())
+
+			stdout, exitCode = runCLI("list", "--uri", remote.URI("metalinks"), "--option", "cache_dir="+cacheDir)
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("2.1.0\n2.0.0\n1.1.0\n1.0.0\n"))
+			Expect(marker).To(BeAnExistingFile())
+		})
+
+		It("publishes from sparse, shallow clones", func() {
+			metalinkPath := filepath.Join(tmpdir, "v3.0.0.meta4")
+			Expect(ioutil.WriteFile(metalinkPath, []byte(metalinkXML("3.0.0")), 0644)).To(Succeed())
+
+			stdout, exitCode := runCLI("publish", "--uri", remote.URI("metalinks"), "--option", "cache_dir="+cacheDir, "--option", "sparse_checkout=true", "--option", "depth=1", "--rename", "v3/v{{.Version}}.meta4", metalinkPath)
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal("published 3.0.0 as v3/v3.0.0.meta4\n"))
+
+			content, err := remote.Git("show", "master:metalinks/v3/v3.0.0.meta4")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(content).To(ContainSubstring("<version>3.0.0</version>"))
+
+			_, err = remote.Git("show", "master:metalinks/v2/v2.0.0.meta4")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Describe("over https", func() {
+			var server *httptest.Server
+			var uri string
+
+			writeConfig := func(options string) string {
+				config := filepath.Join(tmpdir, "https.yml")
+				Expect(ioutil.WriteFile(config, []byte(fmt.Sprintf(`
+uri: %s
+options:
+  cache_dir: %s
+%s`, uri, cacheDir, options)), 0644)).To(Succeed())
+
+				return config
+			}
+
+			BeforeEach(func() {
+				var err error
+
+				server, err = remote.ServeHTTPS("releaser", "fake-token")
+				Expect(err).NotTo(HaveOccurred())
+
+				uri = fmt.Sprintf("git+%s/remote.git//metalinks#master", server.URL)
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("authenticates with tokens and verifies a custom CA", func() {
+				_, exitCode := runCLI("list", "--uri", uri, "--option", "cache_dir="+cacheDir, "--option", "username=releaser", "--option", "token=fake-token")
+				Expect(exitCode).NotTo(Equal(0))
+
+				caCert, err := json.Marshal(pkgtesting.CACertificate(server))
+				Expect(err).NotTo(HaveOccurred())
+
+				_, exitCode = runCLI("list", "--config", writeConfig(fmt.Sprintf("  ca_cert: %s\n  username: releaser\n  token: wrong-token\n", caCert)))
+				Expect(exitCode).NotTo(Equal(0))
+
+				stdout, exitCode := runCLI("list", "--config", writeConfig(fmt.Sprintf("  ca_cert: %s\n  username: releaser\n  token: fake-token\n", caCert)))
+				Expect(exitCode).To(Equal(0))
+				Expect(stdout).To(Equal("2.0.0\n1.1.0\n1.0.0\n"))
+
+				By("pushing with the same credentials", func() {
+					metalinkPath := filepath.Join(tmpdir, "v3.0.0.meta4")
+					Expect(ioutil.WriteFile(metalinkPath, []byte(metalinkXML("3.0.0")), 0644)).To(Succeed())
+
+					_, exitCode := runCLI("publish", "--config", writeConfig(fmt.Sprintf("  ca_cert: %s\n  username: releaser\n  token: fake-token\n", caCert)), metalinkPath)
+					Expect(exitCode).To(Equal(0))
+
+					_, err := remote.Git("show", "master:metalinks/v3.0.0.meta4")
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				By("keeping credentials out of the cache", func() {
+					entries, err := ioutil.ReadDir(cacheDir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(entries).To(HaveLen(1))
+					Expect(filepath.Join(cacheDir, entries[0].Name())).To(Equal(cloneDir()))
+				})
+			})
+
+			It("optionally skips certificate verification", func() {
+				stdout, exitCode := runCLI("list", "--uri", uri, "--option", "cache_dir="+cacheDir, "--option", "username=releaser", "--option", "token=fake-token", "--option", "insecure_skip_verify=true")
+				Expect(exitCode).To(Equal(0))
+				Expect(stdout).To(Equal("2.0.0\n1.1.0\n1.0.0\n"))
+			})
+
+			It("uses credential helpers", func() {
+				credentials := filepath.Join(tmpdir, "credentials")
+				Expect(ioutil.WriteFile(credentials, []byte(strings.Replace(server.URL, "https://", "https://releaser:fake-token@", 1)+"\n"), 0600)).To(Succeed())
+
+				stdout, exitCode := runCLI("list", "--uri", uri, "--option", "cache_dir="+cacheDir, "--option", "credential_helper=store --file "+credentials, "--option", "insecure_skip_verify=true")
+				Expect(exitCode).To(Equal(0))
+				Expect(stdout).To(Equal("2.0.0\n1.1.0\n1.0.0\n"))
+			})
+		})
+
+		It("publishes through pull requests", func() {
+			forge := pkgtesting.NewForge()
+			defer forge.Close()
+
+			metalinkPath := filepath.Join(tmpdir, "v3.0.0.meta4")
+			Expect(ioutil.WriteFile(metalinkPath, []byte(metalinkXML("3.0.0")), 0644)).To(Succeed())
+
+			config := filepath.Join(tmpdir, "config.yml")
+			Expect(ioutil.WriteFile(config, []byte(fmt.Sprintf(`
+uri: %s
+options:
+  cache_dir: %s
+  pull_request:
+    forge: gitea
+    api_url: %s/api/v1
+    repository: releases/metalinks
+    branch: release-{{.Version}}
+`, remote.URI("metalinks"), cacheDir, forge.URL)), 0644)).To(Succeed())
+
+			stdout, exitCode := runCLI("publish", "--config", config, metalinkPath)
+			Expect(exitCode).To(Equal(0))
+			Expect(stdout).To(Equal(fmt.Sprintf("published 3.0.0 as v3.0.0.meta4\nopened pull request %s/releases/metalinks/pulls/1\n", forge.URL)))
+
+			_, err := remote.Git("show", "release-3.0.0:metalinks/v3.0.0.meta4")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink-repository-resource/factory"
+	"github.com/dpb587/metalink-repository-resource/internal/publish"
+	"github.com/pkg/errors"
+)
+
+func runPublish(args []string) error {
+	var flags sourceFlags
+	var files stringsFlag
+	var version, rename string
+
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	flags.register(fs)
+	fs.Var(&files, "file", "create the metalink from local files matching this glob (repeatable)")
+	fs.StringVar(&version, "file-version", "", "version of the metalink created with --file")
+	fs.StringVar(&rename, "rename", publish.DefaultMetalinkName, "template for the metalink name in the repository")
+
+	args, err := parseFlags(fs, args, "[METALINK]")
+	if err != nil {
+		return err
+	}
+
+	source, err := flags.Source()
+	if err != nil {
+		return err
+	}
+
+	urlLoader, err := factory.GetURLLoader(source.URLHandlers, source.Transfer)
+	if err != nil {
+		return errors.Wrap(err, "loading url handlers")
+	}
+
+	var meta4 metalink.Metalink
+	var localCache = map[string]string{}
+
+	if len(files) > 0 {
+		if len(args) > 0 {
+			return errors.New("METALINK and --file are mutually exclusive")
+		} else if version == "" {
+			return errors.New("--file-version is required with --file")
+		}
+
+		meta4, localCache, err = publish.CreateMetalink(urlLoader, version, files)
+		if err != nil {
+			return errors.Wrap(err, "creating metalink")
+		}
+	} else if len(args) == 1 {
+		meta4Bytes, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return errors.Wrap(err, "reading metalink")
+		}
+
+		err = metalink.Unmarshal(meta4Bytes, &meta4)
+		if err != nil {
+			return errors.Wrap(err, "parsing metalink")
+		}
+	} else {
+		return errors.New("expected METALINK or --file")
+	}
+
+	if len(meta4.Files) == 0 {
+		return errors.New("bad metalink: missing file node")
+	} else if meta4.Files[0].Version == "" {
+		return errors.New("bad metalink: missing file version node")
+	}
+
+	if len(source.MirrorFiles) > 0 {
+		meta4, err = publish.MirrorMetalink(urlLoader, source.MirrorFiles, meta4, localCache)
+		if err != nil {
+			return errors.Wrap(err, "mirroring")
+		}
+	}
+
+	meta4Bytes, err := metalink.MarshalXML(meta4)
+	if err != nil {
+		return errors.Wrap(err, "marshaling metalink")
+	}
+
+	metalinkName, err := publish.RenderMetalinkName(rename, meta4.Files[0].Version)
+	if err != nil {
+		return errors.Wrap(err, "executing metalink name template")
+	}
+
+	repo, err := factory.GetSource(source.URI, source.Options)
+	if err != nil {
+		return errors.Wrap(err, "creating source")
+	}
+
+	err = repo.Put(metalinkName, bytes.NewReader(meta4Bytes))
+	if err != nil {
+		return errors.Wrap(err, "storing metalink")
+	}
+
+	pullRequestURL := publish.PullRequestURL(repo)
+
+	if flags.json {
+		return printJSON(struct {
+			api.Version
+			PullRequest string `json:"pull_request,omitempty"`
+		}{
+			Version:     api.Version{Version: meta4.Files[0].Version},
+			PullRequest: pullRequestURL,
+		})
+	}
+
+	fmt.Printf("published %s as %s\n", meta4.Files[0].Version, metalinkName)
+
+	if pullRequestURL != "" {
+		fmt.Printf("opened pull request %s\n", pullRequestURL)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/factory"
+	"github.com/dpb587/metalink-repository-resource/internal/publish"
+	filter_and "github.com/dpb587/metalink/repository/filter/and"
+	"github.com/dpb587/metalink/repository/sorter"
+	sorter_fileversion "github.com/dpb587/metalink/repository/sorter/fileversion"
+	"github.com/pkg/errors"
+)
+
+type remirrorResult struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Added   int    `json:"added"`
+}
+
+func runRemirror(args []string) error {
+	var flags sourceFlags
+
+	fs := flag.NewFlagSet("remirror", flag.ExitOnError)
+	flags.register(fs)
+
+	_, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	source, err := flags.Source()
+	if err != nil {
+		return err
+	}
+
+	if len(source.MirrorFiles) == 0 {
+		return errors.New("no mirror_files configured")
+	}
+
+	repo, err := loadRepository(source)
+	if err != nil {
+		return err
+	}
+
+	andFilter := filter_and.NewFilter()
+
+	err = source.ApplyFilter(&andFilter)
+	if err != nil {
+		return errors.Wrap(err, "creating filter")
+	}
+
+	metalinks, err := repo.Filter(andFilter)
+	if err != nil {
+		return errors.Wrap(err, "filtering metalinks")
+	}
+
+	sorter.Sort(metalinks, sorter_fileversion.Sorter{})
+
+	urlLoader, err := factory.GetURLLoader(source.URLHandlers, source.Transfer)
+	if err != nil {
+		return errors.Wrap(err, "loading url handlers")
+	}
+	results := []remirrorResult{}
+
+	// each metalink is stored as soon as it is mirrored so an interrupted run
+	// only needs to repeat the uploads of a single metalink
+	for _, meta4 := range metalinks {
+		result := remirrorResult{
+			Path: meta4.Reference.Path,
+		}
+
+		if len(meta4.Metalink.Files) > 0 {
+			result.Version = meta4.Metalink.Files[0].Version
+		}
+
+		before := countURLs(meta4.Metalink)
+
+		mirrored, err := publish.MirrorMetalink(urlLoader, source.MirrorFiles, meta4.Metalink, map[string]string{})
+		if err != nil {
+			return errors.Wrapf(err, "mirroring %s", meta4.Reference.Path)
+		}
+
+		result.Added = countURLs(mirrored) - before
+
+		if result.Added > 0 {
+			meta4Bytes, err := metalink.MarshalXML(mirrored)
+			if err != nil {
+				return errors.Wrap(err, "marshaling metalink")
+			}
+
+			err = repo.Put(meta4.Reference.Path, bytes.NewReader(meta4Bytes))
+			if err != nil {
+				return errors.Wrapf(err, "storing %s", meta4.Reference.Path)
+			}
+		}
+
+		if !flags.json {
+			fmt.Printf("%s: %d url(s) added\n", result.Path, result.Added)
+		}
+
+		results = append(results, result)
+	}
+
+	if flags.json {
+		return printJSON(results)
+	}
+
+	return nil
+}
+
+func countURLs(meta4 metalink.Metalink) int {
+	var count int
+
+	for _, file := range meta4.Files {
+		count += len(file.URLs)
+	}
+
+	return count
+}
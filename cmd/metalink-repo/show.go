@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dpb587/metalink-repository-resource/internal/fetch"
+)
+
+func runShow(args []string) error {
+	var flags sourceFlags
+
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	flags.register(fs)
+
+	args, err := parseFlags(fs, args, "VERSION")
+	if err != nil {
+		return err
+	}
+
+	source, err := flags.Source()
+	if err != nil {
+		return err
+	}
+
+	meta4, err := fetch.FindMetalink(source, args[0])
+	if err != nil {
+		return err
+	}
+
+	if flags.json {
+		return printJSON(meta4)
+	}
+
+	fmt.Printf("version: %s\n", meta4.Metalink.Files[0].Version)
+	fmt.Printf("path: %s\n", meta4.Reference.Path)
+
+	if meta4.Metalink.Published != nil {
+		fmt.Printf("published: %s\n", meta4.Metalink.Published.Format(time.RFC3339))
+	}
+
+	fmt.Println("files:")
+
+	for _, file := range meta4.Metalink.Files {
+		fmt.Printf("  %s\n", file.Name)
+		fmt.Printf("    size: %d\n", file.Size)
+
+		for _, hash := range file.Hashes {
+			fmt.Printf("    %s: %s\n", hash.Type, hash.Hash)
+		}
+
+		urls := file.URLs
+		sort.SliceStable(urls, func(i, j int) bool {
+			return urls[i].Priority != nil && (urls[j].Priority == nil || *urls[i].Priority < *urls[j].Priority)
+		})
+
+		for _, url := range urls {
+			fmt.Printf("    url: %s\n", url.URL)
+		}
+
+		for _, metaURL := range file.MetaURLs {
+			fmt.Printf("    metaurl: %s (%s)\n", metaURL.URL, metaURL.MediaType)
+		}
+	}
+
+	return nil
+}
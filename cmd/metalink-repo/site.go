@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cheggaaa/pb"
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink-repository-resource/factory"
+	"github.com/dpb587/metalink-repository-resource/internal/site"
+	"github.com/dpb587/metalink/file/url"
+	fileurl "github.com/dpb587/metalink/file/url/file"
+	filter_and "github.com/dpb587/metalink/repository/filter/and"
+	"github.com/dpb587/metalink/repository/sorter"
+	sorter_fileversion "github.com/dpb587/metalink/repository/sorter/fileversion"
+	"github.com/pkg/errors"
+)
+
+func runSite(args []string) error {
+	var flags sourceFlags
+	var options site.Options
+
+	fs := flag.NewFlagSet("site", flag.ExitOnError)
+	flags.register(fs)
+	fs.StringVar(&options.Title, "title", "", "title of the site (default is the repository uri)")
+	fs.StringVar(&options.BaseURL, "base-url", "", "public URL of the site (used for feed links)")
+
+	args, err := parseFlags(fs, args, "OUTPUT")
+	if err != nil {
+		return err
+	}
+
+	source, err := flags.Source()
+	if err != nil {
+		return err
+	}
+
+	repo, err := loadRepository(source)
+	if err != nil {
+		return err
+	}
+
+	andFilter := filter_and.NewFilter()
+
+	err = source.ApplyFilter(&andFilter)
+	if err != nil {
+		return errors.Wrap(err, "creating filter")
+	}
+
+	metalinks, err := repo.Filter(andFilter)
+	if err != nil {
+		return errors.Wrap(err, "filtering metalinks")
+	}
+
+	sorter.Sort(metalinks, sorter_fileversion.Sorter{})
+
+	// the site is public, unlike the credentials of the repository
+	options.RepositoryURI = api.PublicURL(repo.URI())
+	options.Now = time.Now()
+
+	if options.Title == "" {
+		options.Title = options.RepositoryURI
+	}
+
+	files, err := site.Render(metalinks, options)
+	if err != nil {
+		return errors.Wrap(err, "rendering site")
+	}
+
+	var paths []string
+
+	for path := range files {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	output := args[0]
+	urlLoader, err := factory.GetURLLoader(source.URLHandlers, source.Transfer)
+	if err != nil {
+		return errors.Wrap(err, "loading url handlers")
+	}
+
+	for _, path := range paths {
+		if strings.Contains(output, "://") && !strings.HasPrefix(output, "file://") {
+			err = uploadSiteFile(urlLoader, fmt.Sprintf("%s/%s", strings.TrimSuffix(output, "/"), path), files[path])
+		} else {
+			err = writeSiteFile(output, path, files[path])
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "writing %s", path)
+		}
+
+		if !flags.json {
+			fmt.Printf("wrote %s\n", path)
+		}
+	}
+
+	if flags.json {
+		return printJSON(paths)
+	}
+
+	return nil
+}
+
+func writeSiteFile(output, path string, content []byte) error {
+	if strings.HasPrefix(output, "file://") {
+		parsed, err := neturl.Parse(output)
+		if err != nil {
+			return errors.Wrap(err, "parsing output")
+		}
+
+		output = parsed.Path
+	}
+
+	path = filepath.Join(output, filepath.FromSlash(path))
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return errors.Wrap(err, "creating directory")
+	}
+
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+func uploadSiteFile(urlLoader url.Loader, uri string, content []byte) error {
+	tmpfile, err := ioutil.TempFile("", "metalink-repository-site")
+	if err != nil {
+		return errors.Wrap(err, "creating temp file")
+	}
+
+	defer os.Remove(tmpfile.Name())
+
+	_, err = tmpfile.Write(content)
+	tmpfile.Close()
+	if err != nil {
+		return errors.Wrap(err, "writing temp file")
+	}
+
+	remote, err := urlLoader.LoadURL(metalink.URL{URL: uri})
+	if err != nil {
+		return errors.Wrap(err, "loading destination")
+	}
+
+	progress := pb.New64(int64(len(content))).Set(pb.Bytes, true)
+
+	return remote.WriteFrom(fileurl.NewReference(tmpfile.Name()), progress)
+}
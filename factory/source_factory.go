@@ -1,12 +1,19 @@
 package factory
 
 import (
+	"net/http"
+
+	"github.com/dpb587/metalink-repository-resource/api"
+
+	source_git "github.com/dpb587/metalink-repository-resource/internal/source/git"
+	source_github "github.com/dpb587/metalink-repository-resource/internal/source/github"
+	source_jsonindex "github.com/dpb587/metalink-repository-resource/internal/source/jsonindex"
+	source_oci "github.com/dpb587/metalink-repository-resource/internal/source/oci"
+	source_s3 "github.com/dpb587/metalink-repository-resource/internal/source/s3"
 	"github.com/dpb587/metalink/repository/source"
 	source_factory "github.com/dpb587/metalink/repository/source/factory"
 	source_fs "github.com/dpb587/metalink/repository/source/fs"
-	source_git "github.com/dpb587/metalink/repository/source/git"
 	source_http "github.com/dpb587/metalink/repository/source/http"
-	source_s3 "github.com/dpb587/metalink/repository/source/s3"
 
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -20,8 +27,11 @@ func getSourceFactory() source.Factory {
 	sourceFactory := source_factory.NewFactory()
 	sourceFactory.Add(source_fs.NewFactory(fs))
 	sourceFactory.Add(source_http.NewFactory())
-	sourceFactory.Add(source_git.NewFactory(fs, cmdRunner))
+	sourceFactory.Add(source_git.NewFactory(fs, cmdRunner, http.DefaultClient))
 	sourceFactory.Add(source_s3.NewFactory())
+	sourceFactory.Add(source_oci.NewFactory(http.DefaultClient))
+	sourceFactory.Add(source_jsonindex.NewFactory(http.DefaultClient))
+	sourceFactory.Add(source_github.NewFactory(http.DefaultClient))
 
 	return sourceFactory
 }
@@ -29,3 +39,32 @@ func getSourceFactory() source.Factory {
 func GetSource(uri string, options map[string]interface{}) (source.Source, error) {
 	return getSourceFactory().Create(uri, options)
 }
+
+// pathLimiter is implemented by sources which may avoid loading metalinks
+// that cannot match repositorypath filters.
+type pathLimiter interface {
+	LimitPaths(globs []string)
+}
+
+// GetRepository creates the source of a configuration, limited to the paths of
+// its repositorypath filters when supported by the source.
+func GetRepository(s api.Source) (source.Source, error) {
+	repo, err := GetSource(s.URI, s.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	if limiter, ok := repo.(pathLimiter); ok {
+		var globs []string
+
+		for _, filterMap := range s.Filters {
+			if glob, found := filterMap["repositorypath"]; found {
+				globs = append(globs, glob)
+			}
+		}
+
+		limiter.LimitPaths(globs)
+	}
+
+	return repo, nil
+}
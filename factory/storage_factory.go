@@ -0,0 +1,62 @@
+package factory
+
+import (
+	"fmt"
+	neturl "net/url"
+
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink-repository-resource/internal/s3"
+	"github.com/dpb587/metalink-repository-resource/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// GetStorage returns a storage for listing and deleting blobs under uri using
+// the first matching URL handler for credentials.
+func GetStorage(handlers []api.HandlerSource, uri string) (storage.Storage, error) {
+	parsed, err := neturl.Parse(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing uri")
+	}
+
+	switch parsed.Scheme {
+	case "file":
+		return storage.NewFileStorage(), nil
+	case "s3":
+		for _, handlerSource := range handlers {
+			if handlerSource.Type != "s3" || !handlerMatches(handlerSource, uri) {
+				continue
+			}
+
+			opts, err := s3.ParseOptions(handlerSource.Options)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing s3 handler options")
+			}
+
+			return storage.NewS3Storage(opts), nil
+		}
+
+		return storage.NewS3Storage(s3.Options{}), nil
+	}
+
+	return nil, fmt.Errorf("unsupported storage: %s", parsed.Scheme)
+}
+
+func handlerMatches(handlerSource api.HandlerSource, uri string) bool {
+	for _, exclude := range handlerSource.Exclude {
+		if exclude.MatchString(uri) {
+			return false
+		}
+	}
+
+	if len(handlerSource.Include) == 0 {
+		return true
+	}
+
+	for _, include := range handlerSource.Include {
+		if include.MatchString(uri) {
+			return true
+		}
+	}
+
+	return false
+}
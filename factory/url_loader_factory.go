@@ -2,17 +2,23 @@ package factory
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink-repository-resource/internal/oci"
+	"github.com/dpb587/metalink-repository-resource/internal/resumable"
+	"github.com/dpb587/metalink-repository-resource/internal/s3"
+	"github.com/dpb587/metalink-repository-resource/internal/throttle"
+	ociurl "github.com/dpb587/metalink-repository-resource/internal/url/oci"
+	s3url "github.com/dpb587/metalink-repository-resource/internal/url/s3"
 	"github.com/dpb587/metalink/file/url"
 	fileurl "github.com/dpb587/metalink/file/url/file"
 	ftpurl "github.com/dpb587/metalink/file/url/ftp"
-	httpurl "github.com/dpb587/metalink/file/url/http"
-	s3url "github.com/dpb587/metalink/file/url/s3"
 	"github.com/dpb587/metalink/file/url/urlutil"
+	"github.com/pkg/errors"
 )
 
-func GetURLLoader(handlers []api.HandlerSource) url.Loader {
+func GetURLLoader(handlers []api.HandlerSource, transfer api.TransferParams) (url.Loader, error) {
 	loader := url.NewMultiLoader()
 
 	for _, handlerSource := range handlers {
@@ -20,38 +26,21 @@ func GetURLLoader(handlers []api.HandlerSource) url.Loader {
 
 		switch handlerSource.Type {
 		case "s3":
-			opts := s3url.Options{}
-
-			if val, ok := handlerSource.Options["access_key"]; ok {
-				valStr, ok := val.(string)
-				if !ok {
-					panic("unsupported handler option: s3: access_key: expected string")
-				}
-
-				opts.AccessKey = valStr
-			}
-
-			if val, ok := handlerSource.Options["secret_key"]; ok {
-				valStr, ok := val.(string)
-				if !ok {
-					panic("unsupported handler option: s3: secret_key: expected string")
-				}
-
-				opts.SecretKey = valStr
+			opts, err := s3.ParseOptions(handlerSource.Options)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing s3 handler options")
 			}
 
-			if val, ok := handlerSource.Options["role_arn"]; ok {
-				valStr, ok := val.(string)
-				if !ok {
-					panic("unsupported handler option: s3: role_arn: expected string")
-				}
-
-				opts.RoleARN = valStr
+			handlerLoader = s3url.NewLoader(opts)
+		case "oci":
+			opts, err := oci.ParseOptions(handlerSource.Options)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing oci handler options")
 			}
 
-			handlerLoader = s3url.NewLoader(opts)
+			handlerLoader = ociurl.NewLoader(http.DefaultClient, opts)
 		default:
-			panic(fmt.Errorf("unsupported handler: %s", handlerSource.Type))
+			return nil, fmt.Errorf("unsupported handler: %s", handlerSource.Type)
 		}
 
 		if len(handlerSource.Include) > 0 || len(handlerSource.Exclude) > 0 {
@@ -69,9 +58,26 @@ func GetURLLoader(handlers []api.HandlerSource) url.Loader {
 	file := fileurl.NewLoader()
 	loader.Add(file)
 	loader.Add(ftpurl.Loader{})
-	loader.Add(httpurl.Loader{})
-	loader.Add(s3url.NewLoader(s3url.Options{}))
+	loader.Add(resumable.NewHTTPLoader(http.DefaultClient))
+	loader.Add(s3url.NewLoader(s3.Options{}))
+	loader.Add(ociurl.NewLoader(http.DefaultClient, oci.Options{}))
 	loader.Add(urlutil.NewEmptySchemeLoader(file))
 
-	return loader
+	return throttle.NewLoader(loader, getThrottleOptions(transfer)), nil
+}
+
+func getThrottleOptions(transfer api.TransferParams) throttle.Options {
+	opts := throttle.Options{
+		BytesPerSecond: transfer.MaxBytesPerSecond,
+	}
+
+	if transfer.Timeout != nil {
+		opts.Timeout = transfer.Timeout.Duration
+	}
+
+	if transfer.StallTimeout != nil {
+		opts.StallTimeout = transfer.StallTimeout.Duration
+	}
+
+	return opts
 }
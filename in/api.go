@@ -31,8 +31,15 @@ func (r Request) ApplyFilter(filter *and.Filter) error {
 }
 
 type Params struct {
-	SkipDownload bool     `json:"skip_download"`
-	IncludeFiles []string `json:"include_files,omitempty"`
+	SkipDownload bool             `json:"skip_download"`
+	IncludeFiles []string         `json:"include_files,omitempty"`
+	Unpack       api.UnpackParams `json:"unpack"`
+	Path         string           `json:"path,omitempty"`
+	Symlink      string           `json:"symlink,omitempty"`
+
+	RefreshPresignedURLs bool `json:"refresh_presigned_urls,omitempty"`
+
+	Transfer api.TransferParams `json:"transfer,omitempty"`
 }
 
 type Response struct {
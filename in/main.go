@@ -9,13 +9,12 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/cheggaaa/pb"
 	"github.com/dpb587/metalink"
 	"github.com/dpb587/metalink-repository-resource/api"
 	"github.com/dpb587/metalink-repository-resource/factory"
+	"github.com/dpb587/metalink-repository-resource/internal/fetch"
+	"github.com/dpb587/metalink-repository-resource/internal/presign"
 	filter_and "github.com/dpb587/metalink/repository/filter/and"
-	"github.com/dpb587/metalink/transfer"
-	"github.com/dpb587/metalink/verification"
 )
 
 func main() {
@@ -40,6 +39,11 @@ func main() {
 		api.Fatal("in: bad stdin: parse error", err)
 	}
 
+	err = api.ResolveSource(&request.Source)
+	if err != nil {
+		api.Fatal("in: bad stdin: source", err)
+	}
+
 	andFilter := filter_and.NewFilter()
 
 	err = request.ApplyFilter(&andFilter)
@@ -47,7 +51,7 @@ func main() {
 		api.Fatal("in: bad stdin: filter error", err)
 	}
 
-	repository, err := factory.GetSource(request.Source.URI, request.Source.Options)
+	repository, err := factory.GetRepository(request.Source)
 	if err != nil {
 		api.Fatal("in: bad stdin: source: uri", err)
 	}
@@ -68,82 +72,28 @@ func main() {
 		api.Fatal("in: too much to do", errors.New("multiple matches found"))
 	}
 
-	urlLoader := factory.GetURLLoader(request.Source.URLHandlers)
-
-	var fileCount int
-	var byteCount uint64
-
-	for _, file := range metalinks[0].Metalink.Files {
-		var matched = true
-
-		if len(request.Source.IncludeFiles) > 0 {
-			matched = false
-
-			for _, pattern := range request.Source.IncludeFiles {
-				if match, _ := filepath.Match(pattern, file.Name); match {
-					matched = true
-
-					break
-				}
-			}
-		}
-
-		if matched && len(request.Params.IncludeFiles) > 0 {
-			matched = false
-
-			for _, pattern := range request.Params.IncludeFiles {
-				if match, _ := filepath.Match(pattern, file.Name); match {
-					matched = true
-
-					break
-				}
-			}
-		}
-
-		if matched && len(request.Source.ExcludeFiles) > 0 {
-			for _, pattern := range request.Source.ExcludeFiles {
-				if match, _ := filepath.Match(pattern, file.Name); match {
-					matched = false
-
-					break
-				}
-			}
+	if request.Params.RefreshPresignedURLs {
+		urlLoader, err := factory.GetURLLoader(request.Source.URLHandlers, request.Source.Transfer.Merge(request.Params.Transfer))
+		if err != nil {
+			api.Fatal("in: loading url handlers", err)
 		}
 
-		if !matched {
-			continue
-		}
-
-		if !request.Params.SkipDownload {
-			if fileCount > 0 {
-				fmt.Fprintln(os.Stderr, "")
-			}
-
-			fmt.Fprintln(os.Stderr, file.Name)
-
-			local, err := urlLoader.LoadURL(metalink.URL{URL: filepath.Join(destination, file.Name)})
-			if err != nil {
-				api.Fatal(fmt.Sprintf("in: bad file: %s", file.Name), err)
-			}
-
-			progress := pb.New64(int64(file.Size)).Set(pb.Bytes, true).SetRefreshRate(time.Second).SetWidth(80)
-			progress.SetWriter(os.Stderr)
-
-			verifier, err := factory.DynamicVerification.GetVerifier(file, request.Source.SkipHashVerification, request.Source.SkipSignatureVerification, request.Source.SignatureTrustStore)
-			if err != nil {
-				api.Fatal(fmt.Sprintf("in: bad file verifier: %s", file.Name), err)
-			}
-
-			downloader := transfer.NewVerifiedTransfer(factory.GetMetaURLLoaderFactory(), urlLoader, verifier)
-
-			err = downloader.TransferFile(file, local, progress, verification.NewSimpleVerificationResultReporter(os.Stderr))
-			if err != nil {
-				api.Fatal(fmt.Sprintf("in: bad file transfer: %s", file.Name), err)
-			}
+		metalinks[0].Metalink, err = presign.Refresh(urlLoader, metalinks[0].Metalink, time.Now())
+		if err != nil {
+			api.Fatal("in: refreshing presigned urls", err)
 		}
+	}
 
-		byteCount = byteCount + file.Size
-		fileCount = fileCount + 1
+	result, err := fetch.Fetch(request.Source, metalinks[0].Metalink, destination, fetch.Options{
+		IncludeFiles: request.Params.IncludeFiles,
+		SkipDownload: request.Params.SkipDownload,
+		Unpack:       request.Params.Unpack,
+		Path:         request.Params.Path,
+		Symlink:      request.Params.Symlink,
+		Transfer:     request.Params.Transfer,
+	})
+	if err != nil {
+		api.Fatal("in: fetching files", err)
 	}
 
 	err = os.MkdirAll(filepath.Join(destination, ".resource"), 0700)
@@ -167,17 +117,8 @@ func main() {
 	}
 
 	err = json.NewEncoder(os.Stdout).Encode(Response{
-		Version: request.Version,
-		Metadata: []api.Metadata{
-			{
-				Name:  "files",
-				Value: fmt.Sprintf("%d", fileCount),
-			},
-			{
-				Name:  "bytes",
-				Value: fmt.Sprintf("%d", byteCount),
-			},
-		},
+		Version:  request.Version,
+		Metadata: result.Metadata(),
 	})
 	if err != nil {
 		api.Fatal("in: bad stdout: json", err)
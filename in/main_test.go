@@ -1,24 +1,37 @@
 package main_test
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/dpb587/metalink-repository-resource/internal/oci"
+	pkgtesting "github.com/dpb587/metalink-repository-resource/internal/testing"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/gexec"
 )
 
 var _ = Describe("Main", func() {
-	var tmpDir, repositoryDir, storageDir, inDir string
+	var tmpDir, repositoryDir, storageDir, storageURL, inDir string
 
 	runCLI := func(stdin string) map[string]interface{} {
 		command := exec.Command(cli, inDir)
@@ -39,6 +52,45 @@ var _ = Describe("Main", func() {
 		return result
 	}
 
+	runCLIFailure := func(stdin string) *gexec.Session {
+		command := exec.Command(cli, inDir)
+		command.Stdin = bytes.NewBufferString(stdin)
+
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		session.Wait(time.Minute)
+		Expect(session.ExitCode()).NotTo(Equal(0))
+
+		return session
+	}
+
+	writeStorageMetalink := func(version string, files map[string][]byte) {
+		var fileNodes string
+
+		for name, data := range files {
+			err := ioutil.WriteFile(filepath.Join(storageDir, name), data, 0700)
+			Expect(err).NotTo(HaveOccurred())
+
+			fileNodes = fileNodes + fmt.Sprintf(`
+  <file name="%s">
+    <hash type="sha-512">%x</hash>
+    <hash type="sha-256">%x</hash>
+    <size>%d</size>
+    <url>%s/%s</url>
+    <version>%s</version>
+  </file>`, name, sha512.Sum512(data), sha256.Sum256(data), len(data), storageURL, name, version)
+		}
+
+		err := ioutil.WriteFile(
+			filepath.Join(repositoryDir, fmt.Sprintf("v%s.meta4", version)),
+			[]byte(fmt.Sprintf(`<metalink xmlns="urn:ietf:params:xml:ns:metalink">%s
+</metalink>`, fileNodes)),
+			0700,
+		)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
 	BeforeEach(func() {
 		var err error
 
@@ -53,6 +105,8 @@ var _ = Describe("Main", func() {
 		err = os.MkdirAll(storageDir, 0700)
 		Expect(err).NotTo(HaveOccurred())
 
+		storageURL = fmt.Sprintf("file://%s", storageDir)
+
 		inDir = path.Join(tmpDir, "in")
 		err = os.MkdirAll(inDir, 0700)
 		Expect(err).NotTo(HaveOccurred())
@@ -199,4 +253,980 @@ var _ = Describe("Main", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(storageBytes).To(Equal([]byte("a second file")))
 	})
+
+	Describe("params.unpack", func() {
+		createTgz := func(entries map[string]string) []byte {
+			buf := &bytes.Buffer{}
+			gzipWriter := gzip.NewWriter(buf)
+			tarWriter := tar.NewWriter(gzipWriter)
+
+			for name, data := range entries {
+				Expect(tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))})).To(Succeed())
+
+				_, err := tarWriter.Write([]byte(data))
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			Expect(tarWriter.Close()).To(Succeed())
+			Expect(gzipWriter.Close()).To(Succeed())
+
+			return buf.Bytes()
+		}
+
+		createZip := func(entries map[string]string) []byte {
+			buf := &bytes.Buffer{}
+			zipWriter := zip.NewWriter(buf)
+
+			for name, data := range entries {
+				writer, err := zipWriter.Create(name)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = writer.Write([]byte(data))
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			Expect(zipWriter.Close()).To(Succeed())
+
+			return buf.Bytes()
+		}
+
+		It("extracts archives and removes them", func() {
+			writeStorageMetalink("0.2.0", map[string][]byte{
+				"bundle.tgz": createTgz(map[string]string{
+					"bundle-0.2.0/bin/tool":  "a tool",
+					"bundle-0.2.0/README.md": "a readme",
+				}),
+				"notes.txt": []byte("some notes"),
+			})
+
+			result := runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s"
+	},
+	"params": {
+		"unpack": {
+			"strip_components": 1
+		}
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir))
+			Expect(result["version"].(map[string]interface{})["version"]).To(Equal("0.2.0"))
+
+			knownFiles, err := filepath.Glob(filepath.Join(inDir, "*"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(knownFiles).To(ConsistOf(
+				filepath.Join(inDir, ".resource"),
+				filepath.Join(inDir, "bin"),
+				filepath.Join(inDir, "README.md"),
+				filepath.Join(inDir, "notes.txt"),
+			))
+
+			toolBytes, err := ioutil.ReadFile(filepath.Join(inDir, "bin", "tool"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(toolBytes).To(Equal([]byte("a tool")))
+		})
+
+		It("respects files and keep_archive", func() {
+			writeStorageMetalink("0.2.0", map[string][]byte{
+				"first.zip":  createZip(map[string]string{"first.txt": "a first file"}),
+				"second.zip": createZip(map[string]string{"second.txt": "a second file"}),
+			})
+
+			runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s"
+	},
+	"params": {
+		"unpack": {
+			"files": [ "first.*" ],
+			"keep_archive": true
+		}
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir))
+
+			knownFiles, err := filepath.Glob(filepath.Join(inDir, "*"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(knownFiles).To(ConsistOf(
+				filepath.Join(inDir, ".resource"),
+				filepath.Join(inDir, "first.zip"),
+				filepath.Join(inDir, "first.txt"),
+				filepath.Join(inDir, "second.zip"),
+			))
+		})
+
+		It("refuses to extract outside of the destination", func() {
+			writeStorageMetalink("0.2.0", map[string][]byte{
+				"bundle.tgz": createTgz(map[string]string{"../escaped.txt": "escaped"}),
+			})
+
+			session := runCLIFailure(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s"
+	},
+	"params": {
+		"unpack": true
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir))
+			Expect(session.Err).To(gbytes.Say("illegal path in archive"))
+
+			_, err := os.Stat(filepath.Join(tmpDir, "escaped.txt"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	Describe("params.path", func() {
+		It("lays out files with symlinks", func() {
+			result := runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s",
+		"include_files": [
+			"a-first.txt"
+		]
+	},
+	"params": {
+		"path": "{{.Version}}/{{.Name}}",
+		"symlink": "latest-{{.Name}}"
+	},
+	"version": {
+		"version": "0.1.0"
+	}
+}`, repositoryDir))
+			Expect(result["version"].(map[string]interface{})["version"]).To(Equal("0.1.0"))
+
+			storageBytes, err := ioutil.ReadFile(filepath.Join(inDir, "0.1.0", "a-first.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(storageBytes).To(Equal([]byte("a first file")))
+
+			linkTarget, err := os.Readlink(filepath.Join(inDir, "latest-a-first.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(linkTarget).To(Equal(filepath.Join("0.1.0", "a-first.txt")))
+		})
+
+		It("refuses paths outside of the destination", func() {
+			session := runCLIFailure(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s"
+	},
+	"params": {
+		"path": "../{{.Name}}"
+	},
+	"version": {
+		"version": "0.1.0"
+	}
+}`, repositoryDir))
+			Expect(session.Err).To(gbytes.Say("path outside of destination"))
+		})
+
+		It("refuses file names with path separators", func() {
+			err := ioutil.WriteFile(filepath.Join(repositoryDir, "v0.3.0.meta4"), []byte(fmt.Sprintf(`<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="../a-first.txt">
+    <size>12</size>
+    <url>file://%s/a-first.txt</url>
+    <version>0.3.0</version>
+  </file>
+</metalink>`, storageDir)), 0700)
+			Expect(err).NotTo(HaveOccurred())
+
+			session := runCLIFailure(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s",
+		"skip_hash_verification": true
+	},
+	"version": {
+		"version": "0.3.0"
+	}
+}`, repositoryDir))
+			Expect(session.Err).To(gbytes.Say("invalid file name"))
+
+			_, err = os.Stat(filepath.Join(tmpDir, "a-first.txt"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("allows file names with path separators when skipping downloads", func() {
+			err := ioutil.WriteFile(filepath.Join(repositoryDir, "v0.3.0.meta4"), []byte(fmt.Sprintf(`<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="nested/a-first.txt">
+    <size>12</size>
+    <url>file://%s/a-first.txt</url>
+    <version>0.3.0</version>
+  </file>
+</metalink>`, storageDir)), 0700)
+			Expect(err).NotTo(HaveOccurred())
+
+			result := runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s"
+	},
+	"params": {
+		"skip_download": true
+	},
+	"version": {
+		"version": "0.3.0"
+	}
+}`, repositoryDir))
+			Expect(result["version"]).To(Equal(map[string]interface{}{"version": "0.3.0"}))
+		})
+	})
+
+	Describe("source.download_cache", func() {
+		var cacheDir string
+		var fileData = []byte("a file which is worth caching")
+
+		BeforeEach(func() {
+			cacheDir = filepath.Join(tmpDir, "cache")
+		})
+
+		It("reuses verified files from the cache", func() {
+			writeStorageMetalink("0.2.0", map[string][]byte{"cached.txt": fileData})
+
+			stdin := fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s",
+		"download_cache": {
+			"path": "%s"
+		}
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir, cacheDir)
+
+			runCLI(stdin)
+
+			cacheBytes, err := ioutil.ReadFile(filepath.Join(cacheDir, "sha256", fmt.Sprintf("%x", sha256.Sum256(fileData))))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cacheBytes).To(Equal(fileData))
+
+			Expect(os.Remove(filepath.Join(storageDir, "cached.txt"))).To(Succeed())
+			Expect(os.RemoveAll(inDir)).To(Succeed())
+
+			runCLI(stdin)
+
+			inBytes, err := ioutil.ReadFile(filepath.Join(inDir, "cached.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inBytes).To(Equal(fileData))
+		})
+
+		It("resumes partial downloads", func() {
+			var requestedRanges []string
+			var requestedRangesMutex sync.Mutex
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestedRangesMutex.Lock()
+				requestedRanges = append(requestedRanges, r.Header.Get("Range"))
+				requestedRangesMutex.Unlock()
+
+				http.ServeContent(w, r, "resumed.txt", time.Now(), bytes.NewReader(fileData))
+			}))
+			defer server.Close()
+
+			storageURL = server.URL
+			writeStorageMetalink("0.2.0", map[string][]byte{"resumed.txt": fileData})
+
+			partialPath := filepath.Join(cacheDir, "sha256", fmt.Sprintf("%x.partial", sha256.Sum256(fileData)))
+			Expect(os.MkdirAll(filepath.Dir(partialPath), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(partialPath, fileData[0:10], 0600)).To(Succeed())
+
+			runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s",
+		"download_cache": {
+			"path": "%s",
+			"copy": true
+		}
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir, cacheDir))
+
+			requestedRangesMutex.Lock()
+			Expect(requestedRanges).To(Equal([]string{"bytes=10-"}))
+			requestedRangesMutex.Unlock()
+
+			inBytes, err := ioutil.ReadFile(filepath.Join(inDir, "resumed.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inBytes).To(Equal(fileData))
+
+			_, err = os.Stat(partialPath)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("overwrites existing files of the destination without a cache", func() {
+			var requestedRanges []string
+			var requestedRangesMutex sync.Mutex
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestedRangesMutex.Lock()
+				requestedRanges = append(requestedRanges, r.Header.Get("Range"))
+				requestedRangesMutex.Unlock()
+
+				http.ServeContent(w, r, "stale.txt", time.Now(), bytes.NewReader(fileData))
+			}))
+			defer server.Close()
+
+			storageURL = server.URL
+			writeStorageMetalink("0.2.0", map[string][]byte{"stale.txt": fileData})
+
+			Expect(ioutil.WriteFile(filepath.Join(inDir, "stale.txt"), []byte("stale"), 0600)).To(Succeed())
+
+			runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s"
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir))
+
+			requestedRangesMutex.Lock()
+			Expect(requestedRanges).To(Equal([]string{""}))
+			requestedRangesMutex.Unlock()
+
+			inBytes, err := ioutil.ReadFile(filepath.Join(inDir, "stale.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inBytes).To(Equal(fileData))
+		})
+
+		It("does not write partial downloads locked by other runs", func() {
+			var requestedRanges []string
+			var requestedRangesMutex sync.Mutex
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestedRangesMutex.Lock()
+				requestedRanges = append(requestedRanges, r.Header.Get("Range"))
+				requestedRangesMutex.Unlock()
+
+				http.ServeContent(w, r, "locked.txt", time.Now(), bytes.NewReader(fileData))
+			}))
+			defer server.Close()
+
+			storageURL = server.URL
+			writeStorageMetalink("0.2.0", map[string][]byte{"locked.txt": fileData})
+
+			partialPath := filepath.Join(cacheDir, "sha256", fmt.Sprintf("%x.partial", sha256.Sum256(fileData)))
+			Expect(os.MkdirAll(filepath.Dir(partialPath), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(partialPath, fileData[0:10], 0600)).To(Succeed())
+
+			lock, err := os.OpenFile(partialPath+".lock", os.O_CREATE|os.O_RDWR, 0600)
+			Expect(err).NotTo(HaveOccurred())
+			defer lock.Close()
+
+			Expect(syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)).To(Succeed())
+
+			runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s",
+		"download_cache": {
+			"path": "%s"
+		}
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir, cacheDir))
+
+			requestedRangesMutex.Lock()
+			Expect(requestedRanges).To(Equal([]string{""}))
+			requestedRangesMutex.Unlock()
+
+			inBytes, err := ioutil.ReadFile(filepath.Join(inDir, "locked.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inBytes).To(Equal(fileData))
+
+			partialBytes, err := ioutil.ReadFile(partialPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(partialBytes).To(Equal(fileData[0:10]))
+
+			leftovers, err := filepath.Glob(partialPath + ".*")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leftovers).To(ConsistOf(partialPath + ".lock"))
+		})
+	})
+
+	Describe("source.transfer", func() {
+		var fileData = []byte("a file which is slowly served")
+
+		It("limits the transfer rate", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.ServeContent(w, r, "limited.txt", time.Now(), bytes.NewReader(fileData))
+			}))
+			defer server.Close()
+
+			storageURL = server.URL
+			writeStorageMetalink("0.2.0", map[string][]byte{"limited.txt": fileData})
+
+			started := time.Now()
+
+			runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s",
+		"transfer": {
+			"max_bytes_per_second": 10
+		}
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir))
+
+			Expect(time.Since(started)).To(BeNumerically(">=", 2*time.Second))
+
+			inBytes, err := ioutil.ReadFile(filepath.Join(inDir, "limited.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inBytes).To(Equal(fileData))
+		})
+
+		It("aborts stalled transfers", func() {
+			release := make(chan struct{})
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", fmt.Sprintf("%d", len(fileData)))
+				w.Write(fileData[0:5])
+				w.(http.Flusher).Flush()
+
+				select {
+				case <-release:
+				case <-r.Context().Done():
+				}
+			}))
+			defer server.Close()
+			defer close(release)
+
+			storageURL = server.URL
+			writeStorageMetalink("0.2.0", map[string][]byte{"stalled.txt": fileData})
+
+			session := runCLIFailure(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s"
+	},
+	"params": {
+		"transfer": {
+			"stall_timeout": "1s"
+		}
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir))
+			Expect(session.Err).To(gbytes.Say("Transfer stalled"))
+		})
+	})
+
+	Describe("source.preferred_locations", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(tmpDir, "storage-de"), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(tmpDir, "storage-de", "a-first.txt"), []byte("a first file"), 0700)).To(Succeed())
+
+			err := ioutil.WriteFile(filepath.Join(repositoryDir, "v0.4.0.meta4"), []byte(fmt.Sprintf(`<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="a-first.txt">
+    <hash type="sha-512">b97213406d0d6848f87d20770cffa2405cb85468939efea99b5f2e7154b15381add67cc62fa2d2871c352ce4ef381c75424cd2ff1e27d4a02fc7910ad29e5b00</hash>
+    <size>12</size>
+    <url location="us" priority="1">file://%s/storage/a-first.txt</url>
+    <url location="de" priority="2">file://%s/storage-de/a-first.txt</url>
+    <version>0.4.0</version>
+  </file>
+</metalink>`, tmpDir, tmpDir)), 0700)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reports the failures of every mirror", func() {
+			Expect(os.Remove(filepath.Join(storageDir, "a-first.txt"))).To(Succeed())
+			Expect(os.Remove(filepath.Join(tmpDir, "storage-de", "a-first.txt"))).To(Succeed())
+
+			session := runCLIFailure(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s"
+	},
+	"version": {
+		"version": "0.4.0"
+	}
+}`, repositoryDir))
+			Expect(session.Err).To(gbytes.Say("all 2 mirrors failed: file://%s/storage/a-first.txt: .+; file://%s/storage-de/a-first.txt: ", tmpDir, tmpDir))
+		})
+
+		It("prefers mirrors by location", func() {
+			result := runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s",
+		"preferred_locations": [ "de" ]
+	},
+	"version": {
+		"version": "0.4.0"
+	}
+}`, repositoryDir))
+			Expect(result["metadata"].([]interface{})).To(ContainElement(And(
+				HaveKeyWithValue("name", "mirror"),
+				HaveKeyWithValue("value", fmt.Sprintf("file://%s/storage-de/a-first.txt", tmpDir)),
+			)))
+		})
+
+		It("respects url_priority_overrides", func() {
+			result := runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s",
+		"url_priority_overrides": {
+			"/storage-de/": 0
+		}
+	},
+	"version": {
+		"version": "0.4.0"
+	}
+}`, repositoryDir))
+			Expect(result["metadata"].([]interface{})).To(ContainElement(And(
+				HaveKeyWithValue("name", "mirror"),
+				HaveKeyWithValue("value", fmt.Sprintf("file://%s/storage-de/a-first.txt", tmpDir)),
+			)))
+		})
+
+		It("otherwise uses metalink priorities", func() {
+			result := runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s"
+	},
+	"version": {
+		"version": "0.4.0"
+	}
+}`, repositoryDir))
+			Expect(result["metadata"].([]interface{})).To(ContainElement(And(
+				HaveKeyWithValue("name", "mirror"),
+				HaveKeyWithValue("value", fmt.Sprintf("file://%s/storage/a-first.txt", tmpDir)),
+			)))
+		})
+	})
+
+	Describe("oci urls", func() {
+		var registry *pkgtesting.Registry
+
+		BeforeEach(func() {
+			registry = pkgtesting.NewRegistry()
+		})
+
+		AfterEach(func() {
+			registry.Close()
+		})
+
+		It("downloads files of tagged artifacts", func() {
+			fileData := []byte("an oci file")
+			digest := fmt.Sprintf("sha256:%x", sha256.Sum256(fileData))
+
+			client := oci.NewClient(http.DefaultClient, registry.Host(), oci.Options{PlainHTTP: true})
+			Expect(client.PushBlob("team/blobs", digest, int64(len(fileData)), bytes.NewReader(fileData))).To(Succeed())
+			Expect(client.PushBlob("team/blobs", oci.Digest(oci.EmptyJSON), int64(len(oci.EmptyJSON)), bytes.NewReader(oci.EmptyJSON))).To(Succeed())
+
+			_, err := client.PutManifest("team/blobs", "v0.2.0", oci.Manifest{
+				SchemaVersion: 2,
+				MediaType:     oci.MediaTypeImageManifest,
+				Config: oci.Descriptor{
+					MediaType: oci.MediaTypeEmptyJSON,
+					Digest:    oci.Digest(oci.EmptyJSON),
+					Size:      int64(len(oci.EmptyJSON)),
+				},
+				Layers: []oci.Descriptor{
+					{
+						MediaType:   "application/octet-stream",
+						Digest:      digest,
+						Size:        int64(len(fileData)),
+						Annotations: map[string]string{"org.opencontainers.image.title": "oci.txt"},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			storageURL = fmt.Sprintf("oci://%s/team/blobs:v0.2.0", registry.Host())
+			writeStorageMetalink("0.2.0", map[string][]byte{"oci.txt": fileData})
+
+			result := runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s",
+		"url_handlers": [
+			{
+				"type": "oci",
+				"options": {
+					"plain_http": true
+				}
+			}
+		]
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir))
+			Expect(result["metadata"].([]interface{})).To(ContainElement(map[string]interface{}{
+				"name":  "mirror",
+				"value": fmt.Sprintf("oci://%s/team/blobs:v0.2.0/oci.txt", registry.Host()),
+			}))
+
+			fileBytes, err := ioutil.ReadFile(filepath.Join(inDir, "oci.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fileBytes).To(Equal(fileData))
+		})
+	})
+
+	Describe("jsonindex sources", func() {
+		var server *httptest.Server
+		var fileData = []byte("a vendor file")
+
+		BeforeEach(func() {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/releases/index.json", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{
+  "releases": [
+    {"tag": "release-1.1.0", "assets": [{"name": "vendor-1.1.0.txt", "href": "../downloads/1.1.0.txt", "bytes": %d, "digest": "%x"}]},
+    {"tag": "release-1.0.0", "assets": [{"name": "vendor-1.0.0.txt", "href": "../downloads/1.0.0.txt", "bytes": "1", "digest": "unused"}]},
+    {"tag": "nightly", "assets": []}
+  ]
+}`, len(fileData), sha256.Sum256(fileData))
+			})
+			mux.HandleFunc("/downloads/1.1.0.txt", func(w http.ResponseWriter, r *http.Request) {
+				w.Write(fileData)
+			})
+
+			server = httptest.NewServer(mux)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("downloads files of mapped releases", func() {
+			result := runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "jsonindex+%s/releases/index.json",
+		"options": {
+			"releases": "$.releases[*]",
+			"version": "$.tag",
+			"version_regex": "^release-(.+)$",
+			"files": "$.assets[*]",
+			"file_name": "$.name",
+			"file_url": "$.href",
+			"file_size": "$.bytes",
+			"file_hashes": {
+				"sha-256": "$.digest"
+			}
+		}
+	},
+	"version": {
+		"version": "1.1.0"
+	}
+}`, server.URL))
+			Expect(result["version"]).To(Equal(map[string]interface{}{"version": "1.1.0"}))
+			Expect(result["metadata"].([]interface{})).To(ContainElement(map[string]interface{}{
+				"name":  "mirror",
+				"value": fmt.Sprintf("%s/downloads/1.1.0.txt", server.URL),
+			}))
+
+			fileBytes, err := ioutil.ReadFile(filepath.Join(inDir, "vendor-1.1.0.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fileBytes).To(Equal(fileData))
+		})
+
+		It("requires a version selector", func() {
+			session := runCLIFailure(fmt.Sprintf(`{
+	"source": {
+		"uri": "jsonindex+%s/releases/index.json",
+		"options": {
+			"file_url": "$.href"
+		}
+	},
+	"version": {
+		"version": "1.1.0"
+	}
+}`, server.URL))
+			Expect(session.Err).To(gbytes.Say("option version: required"))
+		})
+	})
+
+	Describe("secret references", func() {
+		It("redacts resolved secrets from errors", func() {
+			tokenFile := filepath.Join(tmpDir, "token")
+			Expect(ioutil.WriteFile(tokenFile, []byte("fake-index-token\n"), 0600)).To(Succeed())
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listener.Close()).To(Succeed())
+
+			session := runCLIFailure(fmt.Sprintf(`{
+	"source": {
+		"uri": "jsonindex+http://%s/releases/index.json?token=((file:%s))",
+		"options": {
+			"version": "$.tag",
+			"file_url": "$.href"
+		}
+	},
+	"version": {
+		"version": "1.1.0"
+	}
+}`, listener.Addr(), tokenFile))
+			Expect(session.Err).To(gbytes.Say(`token=\[redacted\]`))
+			Expect(string(session.Err.Contents())).NotTo(ContainSubstring("fake-index-token"))
+		})
+	})
+
+	Describe("redaction", func() {
+		It("masks credentials of mirror URLs", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listener.Close()).To(Succeed())
+
+			err = ioutil.WriteFile(filepath.Join(repositoryDir, "v0.2.0.meta4"), []byte(fmt.Sprintf(`<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="private.txt">
+    <size>12</size>
+    <url>http://fake-user:fake-password@%s/private.txt</url>
+    <url>http://%s/private.txt?X-Amz-Credential=fake-credential&amp;X-Amz-Signature=fake-signature</url>
+    <version>0.2.0</version>
+  </file>
+</metalink>`, listener.Addr(), listener.Addr())), 0700)
+			Expect(err).NotTo(HaveOccurred())
+
+			session := runCLIFailure(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s",
+		"skip_hash_verification": true
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir))
+
+			stderr := string(session.Err.Contents())
+			Expect(stderr).To(ContainSubstring("using mirror http://fake-user:[redacted]@"))
+			Expect(stderr).To(ContainSubstring("X-Amz-Signature=[redacted]"))
+			Expect(stderr).NotTo(ContainSubstring("fake-password"))
+			Expect(stderr).NotTo(ContainSubstring("fake-credential"))
+			Expect(stderr).NotTo(ContainSubstring("fake-signature"))
+		})
+
+		It("masks credentials of the mirror metadata", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("private data"))
+			}))
+			defer server.Close()
+
+			err := ioutil.WriteFile(filepath.Join(repositoryDir, "v0.2.0.meta4"), []byte(fmt.Sprintf(`<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="private.txt">
+    <size>12</size>
+    <url>http://fake-user:fake-password@%s/private.txt?X-Amz-Signature=fake-signature</url>
+    <version>0.2.0</version>
+  </file>
+</metalink>`, server.Listener.Addr())), 0700)
+			Expect(err).NotTo(HaveOccurred())
+
+			result := runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "file://%s",
+		"skip_hash_verification": true
+	},
+	"version": {
+		"version": "0.2.0"
+	}
+}`, repositoryDir))
+			Expect(result["metadata"].([]interface{})).To(ContainElement(map[string]interface{}{
+				"name":  "mirror",
+				"value": fmt.Sprintf("http://fake-user:[redacted]@%s/private.txt?X-Amz-Signature=[redacted]", server.Listener.Addr()),
+			}))
+		})
+
+		It("masks secret options", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listener.Close()).To(Succeed())
+
+			session := runCLIFailure(fmt.Sprintf(`{
+	"source": {
+		"uri": "github://owner/name",
+		"options": {
+			"api_url": "http://%s/fake-api-token/",
+			"token": "fake-api-token"
+		}
+	},
+	"version": {
+		"version": "1.0.0"
+	}
+}`, listener.Addr()))
+
+			stderr := string(session.Err.Contents())
+			Expect(stderr).To(ContainSubstring("[redacted]"))
+			Expect(stderr).NotTo(ContainSubstring("fake-api-token"))
+		})
+	})
+
+	Describe("github sources", func() {
+		var github *pkgtesting.GitHub
+		var fileData = []byte("a released binary")
+
+		BeforeEach(func() {
+			github = pkgtesting.NewGitHub()
+			github.Token = "fake-token"
+		})
+
+		AfterEach(func() {
+			github.Close()
+		})
+
+		It("downloads release assets verified by checksum assets", func() {
+			github.AddRelease("vendor", "tool", pkgtesting.GitHubRelease{
+				TagName:     "v1.2.0",
+				PublishedAt: time.Now(),
+				Assets: map[string][]byte{
+					"tool-linux-amd64": fileData,
+					"SHA256SUMS":       []byte(fmt.Sprintf("%x  tool-linux-amd64\n", sha256.Sum256(fileData))),
+				},
+			})
+
+			result := runCLI(fmt.Sprintf(`{
+	"source": {
+		"uri": "github://vendor/tool",
+		"options": {
+			"api_url": "%s",
+			"token": "fake-token"
+		}
+	},
+	"version": {
+		"version": "1.2.0"
+	}
+}`, github.APIURL()))
+			Expect(result["version"]).To(Equal(map[string]interface{}{"version": "1.2.0"}))
+
+			fileBytes, err := ioutil.ReadFile(filepath.Join(inDir, "tool-linux-amd64"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fileBytes).To(Equal(fileData))
+
+			_, err = os.Stat(filepath.Join(inDir, "SHA256SUMS"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("fails for assets not matching their checksums", func() {
+			github.AddRelease("vendor", "tool", pkgtesting.GitHubRelease{
+				TagName:     "v1.2.0",
+				PublishedAt: time.Now(),
+				Assets: map[string][]byte{
+					"tool-linux-amd64":        fileData,
+					"tool-linux-amd64.sha256": []byte(fmt.Sprintf("%x\n", sha256.Sum256([]byte("something else")))),
+				},
+			})
+
+			session := runCLIFailure(fmt.Sprintf(`{
+	"source": {
+		"uri": "github://vendor/tool",
+		"options": {
+			"api_url": "%s",
+			"token": "fake-token"
+		}
+	},
+	"version": {
+		"version": "1.2.0"
+	}
+}`, github.APIURL()))
+			Expect(session.Err).To(gbytes.Say("sha-256"))
+		})
+	})
+
+	Describe("git sources with trusted_signing_keys", func() {
+		var remote *pkgtesting.GitRemote
+		var keyDir string
+
+		metalinkFiles := func(version string) map[string]string {
+			return map[string]string{
+				fmt.Sprintf("metalinks/v%s.meta4", version): fmt.Sprintf(`<metalink xmlns="urn:ietf:params:xml:ns:metalink"><file name="fake-file"><version>%s</version><size>1</size><url>https://example.com/fake-file</url></file></metalink>`, version),
+			}
+		}
+
+		runGitIn := func(trustedKey string) *gexec.Session {
+			request, err := json.Marshal(map[string]interface{}{
+				"source": map[string]interface{}{
+					"uri": remote.URI("metalinks"),
+					"options": map[string]interface{}{
+						"trusted_signing_keys": []string{trustedKey},
+					},
+				},
+				"version": map[string]interface{}{
+					"version": "1.0.0",
+				},
+				"params": map[string]interface{}{
+					"skip_download": true,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			command := exec.Command(cli, inDir)
+			command.Stdin = bytes.NewBuffer(request)
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			return session.Wait(time.Minute)
+		}
+
+		BeforeEach(func() {
+			var err error
+
+			remote, err = pkgtesting.NewGitRemote("master")
+			Expect(err).NotTo(HaveOccurred())
+
+			keyDir, err = ioutil.TempDir("", "metalink-repository-resource-keys")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(remote.Close()).To(Succeed())
+			Expect(os.RemoveAll(keyDir)).To(Succeed())
+		})
+
+		It("accepts metalinks committed with trusted ssh keys", func() {
+			privateKeyPath, publicKey, err := pkgtesting.GenerateSSHKey(keyDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remote.Commit(metalinkFiles("1.0.0"), "add 1.0.0", "-c", "gpg.format=ssh", "-c", "user.signingkey="+privateKeyPath, "-c", "commit.gpgsign=true")).To(Succeed())
+
+			session := runGitIn(publicKey)
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(session.Out).To(gbytes.Say(`"version":"1.0.0"`))
+
+			By("rejecting unsigned changes", func() {
+				Expect(remote.Commit(map[string]string{"metalinks/v1.0.0.meta4": metalinkFiles("1.0.0")["metalinks/v1.0.0.meta4"] + "\n"}, "tamper with 1.0.0")).To(Succeed())
+
+				session := runGitIn(publicKey)
+				Expect(session.ExitCode()).NotTo(Equal(0))
+				Expect(session.Err).To(gbytes.Say("v1.0.0.meta4"))
+				Expect(session.Err).To(gbytes.Say("not signed by a trusted key"))
+			})
+		})
+
+		It("accepts metalinks committed with trusted openpgp keys", func() {
+			gnupghome := filepath.Join(keyDir, "gnupg")
+
+			_, publicKey, err := pkgtesting.GenerateOpenPGPKey(gnupghome)
+			Expect(err).NotTo(HaveOccurred())
+
+			remote.Env = []string{"GNUPGHOME=" + gnupghome}
+			Expect(remote.Commit(metalinkFiles("1.0.0"), "add 1.0.0", "-c", "user.signingkey=test@localhost", "-c", "commit.gpgsign=true")).To(Succeed())
+
+			session := runGitIn(publicKey)
+			Expect(session.ExitCode()).To(Equal(0))
+		})
+
+		It("rejects metalinks signed by other keys", func() {
+			privateKeyPath, _, err := pkgtesting.GenerateSSHKey(keyDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.Mkdir(filepath.Join(keyDir, "other"), 0700)).To(Succeed())
+
+			_, otherPublicKey, err := pkgtesting.GenerateSSHKey(filepath.Join(keyDir, "other"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remote.Commit(metalinkFiles("1.0.0"), "add 1.0.0", "-c", "gpg.format=ssh", "-c", "user.signingkey="+privateKeyPath, "-c", "commit.gpgsign=true")).To(Succeed())
+
+			session := runGitIn(otherPublicKey)
+			Expect(session.ExitCode()).NotTo(Equal(0))
+			Expect(session.Err).To(gbytes.Say("not signed by a trusted key"))
+		})
+	})
 })
@@ -0,0 +1,310 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var tarGzipExtensions = []string{".tgz", ".tar.gz"}
+var tarBzip2Extensions = []string{".tbz", ".tbz2", ".tar.bz2"}
+
+func IsArchive(name string) bool {
+	return format(name) != ""
+}
+
+func format(name string) string {
+	lower := strings.ToLower(name)
+
+	for _, ext := range tarGzipExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return "tgz"
+		}
+	}
+
+	for _, ext := range tarBzip2Extensions {
+		if strings.HasSuffix(lower, ext) {
+			return "tbz2"
+		}
+	}
+
+	if strings.HasSuffix(lower, ".tar") {
+		return "tar"
+	} else if strings.HasSuffix(lower, ".zip") {
+		return "zip"
+	}
+
+	return ""
+}
+
+// Extract unpacks the archive at path into destination. Entries which would be
+// written (or linked) outside of destination result in an error.
+func Extract(path, destination string, stripComponents int) error {
+	destination, err := filepath.Abs(destination)
+	if err != nil {
+		return errors.Wrap(err, "expanding destination")
+	}
+
+	e := extractor{
+		destination:     destination,
+		stripComponents: stripComponents,
+	}
+
+	switch format(path) {
+	case "zip":
+		return e.extractZip(path)
+	case "tar", "tgz", "tbz2":
+		return e.extractTar(path)
+	}
+
+	return fmt.Errorf("unsupported archive format: %s", filepath.Base(path))
+}
+
+type extractor struct {
+	destination     string
+	stripComponents int
+}
+
+func (e extractor) extractTar(path string) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "opening archive")
+	}
+
+	defer fh.Close()
+
+	var reader io.Reader = fh
+
+	switch format(path) {
+	case "tgz":
+		gzipReader, err := gzip.NewReader(fh)
+		if err != nil {
+			return errors.Wrap(err, "opening gzip")
+		}
+
+		defer gzipReader.Close()
+
+		reader = gzipReader
+	case "tbz2":
+		reader = bzip2.NewReader(fh)
+	}
+
+	tarReader := tar.NewReader(reader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "reading archive")
+		}
+
+		target, skip, err := e.resolve(header.Name)
+		if err != nil {
+			return err
+		} else if skip {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg, tar.TypeRegA:
+			err = e.writeFile(target, tarReader, os.FileMode(header.Mode))
+		case tar.TypeSymlink:
+			err = e.writeSymlink(target, header.Linkname)
+		case tar.TypeLink:
+			err = e.writeHardlink(target, header.Linkname)
+		default:
+			// devices, fifos and other special files are not extracted
+			continue
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "extracting %s", header.Name)
+		}
+	}
+
+	return nil
+}
+
+func (e extractor) extractZip(path string) error {
+	zipReader, err := zip.OpenReader(path)
+	if err != nil {
+		return errors.Wrap(err, "opening archive")
+	}
+
+	defer zipReader.Close()
+
+	for _, zipFile := range zipReader.File {
+		target, skip, err := e.resolve(zipFile.Name)
+		if err != nil {
+			return err
+		} else if skip {
+			continue
+		}
+
+		mode := zipFile.Mode()
+
+		switch {
+		case mode.IsDir():
+			err = os.MkdirAll(target, 0755)
+		case mode&os.ModeSymlink != 0:
+			err = e.extractZipSymlink(target, zipFile)
+		case mode.IsRegular():
+			err = e.extractZipFile(target, zipFile)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "extracting %s", zipFile.Name)
+		}
+	}
+
+	return nil
+}
+
+func (e extractor) extractZipFile(target string, zipFile *zip.File) error {
+	reader, err := zipFile.Open()
+	if err != nil {
+		return errors.Wrap(err, "opening file")
+	}
+
+	defer reader.Close()
+
+	return e.writeFile(target, reader, zipFile.Mode())
+}
+
+func (e extractor) extractZipSymlink(target string, zipFile *zip.File) error {
+	reader, err := zipFile.Open()
+	if err != nil {
+		return errors.Wrap(err, "opening file")
+	}
+
+	defer reader.Close()
+
+	linkname, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return errors.Wrap(err, "reading link")
+	}
+
+	return e.writeSymlink(target, string(linkname))
+}
+
+// resolve converts an archive entry name into a path within destination after
+// stripping leading components.
+func (e extractor) resolve(name string) (string, bool, error) {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "./")
+
+	components := strings.Split(strings.Trim(name, "/"), "/")
+	if len(components) <= e.stripComponents {
+		return "", true, nil
+	}
+
+	stripped := filepath.Join(components[e.stripComponents:]...)
+	if stripped == "" || stripped == "." {
+		return "", true, nil
+	}
+
+	target := filepath.Join(e.destination, stripped)
+
+	if !e.contains(target) {
+		return "", false, fmt.Errorf("illegal path in archive: %s", name)
+	}
+
+	// previously extracted symlinks must not be used to escape destination
+	parent := e.destination
+
+	for _, component := range strings.Split(filepath.Dir(stripped), string(filepath.Separator)) {
+		if component == "." {
+			break
+		}
+
+		parent = filepath.Join(parent, component)
+
+		stat, err := os.Lstat(parent)
+		if err != nil {
+			break
+		} else if stat.Mode()&os.ModeSymlink != 0 {
+			return "", false, fmt.Errorf("illegal path through symlink in archive: %s", name)
+		}
+	}
+
+	return target, false, nil
+}
+
+func (e extractor) contains(path string) bool {
+	rel, err := filepath.Rel(e.destination, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, fmt.Sprintf("..%c", filepath.Separator))
+}
+
+func (e extractor) writeFile(target string, reader io.Reader, mode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return errors.Wrap(err, "creating directory")
+	}
+
+	// avoid following an existing symlink outside of the destination
+	os.Remove(target)
+
+	writer, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return errors.Wrap(err, "opening file")
+	}
+
+	defer writer.Close()
+
+	_, err = io.Copy(writer, reader)
+	if err != nil {
+		return errors.Wrap(err, "writing file")
+	}
+
+	return nil
+}
+
+func (e extractor) writeSymlink(target, linkname string) error {
+	if filepath.IsAbs(linkname) || !e.contains(filepath.Join(filepath.Dir(target), linkname)) {
+		return fmt.Errorf("illegal link target in archive: %s", linkname)
+	}
+
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return errors.Wrap(err, "creating directory")
+	}
+
+	os.Remove(target)
+
+	return os.Symlink(linkname, target)
+}
+
+func (e extractor) writeHardlink(target, linkname string) error {
+	source, skip, err := e.resolve(linkname)
+	if err != nil {
+		return err
+	} else if skip {
+		return fmt.Errorf("illegal link target in archive: %s", linkname)
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return errors.Wrap(err, "creating directory")
+	}
+
+	os.Remove(target)
+
+	return os.Link(source, target)
+}
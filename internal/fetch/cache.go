@@ -0,0 +1,159 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/api"
+	fileurl "github.com/dpb587/metalink/file/url/file"
+	"github.com/dpb587/metalink/verification"
+	"github.com/pkg/errors"
+)
+
+var sha256Regexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// downloadCache stores verified files by their SHA-256 checksum so they can be
+// reused by later downloads on the same worker.
+type downloadCache struct {
+	params api.DownloadCacheParams
+}
+
+func newDownloadCache(params api.DownloadCacheParams) downloadCache {
+	return downloadCache{
+		params: params,
+	}
+}
+
+// Path returns the cache path for a file, or an empty string if the file has no
+// usable SHA-256 checksum.
+func (c downloadCache) Path(file metalink.File) string {
+	for _, hash := range file.Hashes {
+		if hash.Type != metalink.HashTypeSHA256 {
+			continue
+		}
+
+		value := strings.ToLower(strings.TrimSpace(hash.Hash))
+		if !sha256Regexp.MatchString(value) {
+			continue
+		}
+
+		return filepath.Join(c.params.Path, "sha256", value)
+	}
+
+	return ""
+}
+
+// Lookup checks whether a previously cached file is still valid. Invalid files
+// are removed from the cache.
+func (c downloadCache) Lookup(cachePath string, file metalink.File, verifier verification.Verifier, reporter verification.VerificationResultReporter) (bool, error) {
+	if _, err := os.Stat(cachePath); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "checking cache")
+	}
+
+	fmt.Fprintf(os.Stderr, "found in cache: %s\n", cachePath)
+
+	result := verifier.Verify(fileurl.NewReference(cachePath), file)
+	reporter.ReportVerificationResult(file, result)
+
+	if result.Error() == nil {
+		return true, nil
+	}
+
+	err := os.Remove(cachePath)
+	if err != nil {
+		return false, errors.Wrap(err, "removing invalid cache entry")
+	}
+
+	return false, nil
+}
+
+// Partial returns the path for downloading a cache entry along with a function
+// which must be called once the path is no longer written. The shared partial
+// file, which later runs may resume, is only used while holding its lock;
+// concurrent downloads use a new, unique partial file instead.
+func (c downloadCache) Partial(cachePath string) (string, func(), error) {
+	partialPath := fmt.Sprintf("%s.partial", cachePath)
+
+	err := os.MkdirAll(filepath.Dir(partialPath), 0755)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "creating cache directory")
+	}
+
+	unlock, locked, err := lockPartial(partialPath)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "locking partial download")
+	} else if locked {
+		return partialPath, unlock, nil
+	}
+
+	unique, err := ioutil.TempFile(filepath.Dir(partialPath), fmt.Sprintf("%s.*", filepath.Base(partialPath)))
+	if err != nil {
+		return "", nil, errors.Wrap(err, "creating partial download")
+	}
+
+	unique.Close()
+
+	return unique.Name(), func() {
+		// never resumed by other downloads
+		os.Remove(unique.Name())
+	}, nil
+}
+
+// Install places a cached file at localPath, preferring a hard link.
+func (c downloadCache) Install(cachePath, localPath string) error {
+	err := os.Remove(localPath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "removing existing file")
+	}
+
+	if !c.params.Copy {
+		err = os.Link(cachePath, localPath)
+		if err == nil {
+			return nil
+		}
+
+		// typically the cache is on a different device; fallback to copying
+	}
+
+	reader, err := os.Open(cachePath)
+	if err != nil {
+		return errors.Wrap(err, "opening cache entry")
+	}
+
+	defer reader.Close()
+
+	writer, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Wrap(err, "opening file for writing")
+	}
+
+	defer writer.Close()
+
+	_, err = io.Copy(writer, reader)
+	if err != nil {
+		return errors.Wrap(err, "copying cache entry")
+	}
+
+	return nil
+}
+
+// discardPartial removes a failed download unless it looks like it could still
+// be resumed.
+func discardPartial(path string, file metalink.File) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return
+	} else if file.Size > 0 && uint64(stat.Size()) < file.Size {
+		return
+	}
+
+	os.Remove(path)
+}
@@ -0,0 +1,262 @@
+package fetch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink-repository-resource/factory"
+	"github.com/dpb587/metalink-repository-resource/internal/archive"
+	"github.com/dpb587/metalink-repository-resource/internal/resumable"
+	"github.com/dpb587/metalink/transfer"
+	"github.com/dpb587/metalink/verification"
+	"github.com/pkg/errors"
+)
+
+type Options struct {
+	IncludeFiles []string
+	SkipDownload bool
+	Unpack       api.UnpackParams
+	Path         string
+	Symlink      string
+	Transfer     api.TransferParams
+}
+
+type Result struct {
+	Files   int
+	Bytes   uint64
+	Mirrors []string
+}
+
+func (r Result) Metadata() []api.Metadata {
+	metadata := []api.Metadata{
+		{
+			Name:  "files",
+			Value: fmt.Sprintf("%d", r.Files),
+		},
+		{
+			Name:  "bytes",
+			Value: fmt.Sprintf("%d", r.Bytes),
+		},
+	}
+
+	for _, mirror := range r.Mirrors {
+		metadata = append(metadata, api.Metadata{
+			Name: "mirror",
+			// metadata is shown and kept with the build history
+			Value: api.Redact(mirror),
+		})
+	}
+
+	return metadata
+}
+
+// Fetch downloads and verifies the files of a metalink into destination.
+func Fetch(source api.Source, meta4 metalink.Metalink, destination string, options Options) (Result, error) {
+	var result Result
+
+	urlLoader, err := factory.GetURLLoader(source.URLHandlers, source.Transfer.Merge(options.Transfer))
+	if err != nil {
+		return result, errors.Wrap(err, "loading url handlers")
+	}
+
+	localPathTmpl, err := newPathTemplate("path", "{{.Name}}")
+	if err != nil {
+		return result, errors.Wrap(err, "bad path template")
+	}
+
+	if options.Path != "" {
+		localPathTmpl, err = newPathTemplate("path", options.Path)
+		if err != nil {
+			return result, errors.Wrap(err, "bad path template")
+		}
+	}
+
+	var symlinkTmpl *pathTemplate
+	var symlinks = map[string]string{}
+
+	if options.Symlink != "" {
+		symlinkTmpl, err = newPathTemplate("symlink", options.Symlink)
+		if err != nil {
+			return result, errors.Wrap(err, "bad symlink template")
+		}
+	}
+
+	for _, file := range meta4.Files {
+		var matched = true
+
+		if len(source.IncludeFiles) > 0 {
+			matched = false
+
+			for _, pattern := range source.IncludeFiles {
+				if match, _ := filepath.Match(pattern, file.Name); match {
+					matched = true
+
+					break
+				}
+			}
+		}
+
+		if matched && len(options.IncludeFiles) > 0 {
+			matched = false
+
+			for _, pattern := range options.IncludeFiles {
+				if match, _ := filepath.Match(pattern, file.Name); match {
+					matched = true
+
+					break
+				}
+			}
+		}
+
+		if matched && len(source.ExcludeFiles) > 0 {
+			for _, pattern := range source.ExcludeFiles {
+				if match, _ := filepath.Match(pattern, file.Name); match {
+					matched = false
+
+					break
+				}
+			}
+		}
+
+		if !matched {
+			continue
+		}
+
+		if !options.SkipDownload {
+			err = validateFileName(file.Name)
+			if err != nil {
+				return result, errors.Wrap(err, "bad file")
+			}
+
+			if result.Files > 0 {
+				fmt.Fprintln(os.Stderr, "")
+			}
+
+			fmt.Fprintln(os.Stderr, file.Name)
+
+			localRelPath, err := localPathTmpl.Execute(file)
+			if err != nil {
+				return result, errors.Wrapf(err, "bad file path: %s", file.Name)
+			}
+
+			localPath, err := resolveLocalPath(destination, localRelPath)
+			if err != nil {
+				return result, errors.Wrapf(err, "bad file path: %s", file.Name)
+			}
+
+			err = os.MkdirAll(filepath.Dir(localPath), 0755)
+			if err != nil {
+				return result, errors.Wrapf(err, "bad file path: %s", file.Name)
+			}
+
+			verifier, err := factory.DynamicVerification.GetVerifier(file, source.SkipHashVerification, source.SkipSignatureVerification, source.SignatureTrustStore)
+			if err != nil {
+				return result, errors.Wrapf(err, "bad file verifier: %s", file.Name)
+			}
+
+			reporter := verification.NewSimpleVerificationResultReporter(os.Stderr)
+
+			var cache downloadCache
+			var cachePath string
+			var cached bool
+
+			if source.DownloadCache != nil {
+				cache = newDownloadCache(*source.DownloadCache)
+				cachePath = cache.Path(file)
+			}
+
+			if cachePath != "" {
+				cached, err = cache.Lookup(cachePath, file, verifier, reporter)
+				if err != nil {
+					return result, errors.Wrapf(err, "bad file cache: %s", file.Name)
+				}
+			}
+
+			if !cached {
+				downloadPath := localPath
+				downloadRef := resumable.NewFileReference(localPath)
+				unlock := func() {}
+
+				if cachePath != "" {
+					downloadPath, unlock, err = cache.Partial(cachePath)
+					if err != nil {
+						return result, errors.Wrapf(err, "bad file cache: %s", file.Name)
+					}
+
+					// only partial downloads of the cache are of the same file
+					downloadRef = resumable.NewResumableFileReference(downloadPath)
+				}
+
+				downloader := transfer.NewVerifiedTransfer(factory.GetMetaURLLoaderFactory(), urlLoader, verifier)
+
+				mirror, err := transferFile(source, downloader, file, downloadRef, reporter)
+				if err != nil {
+					discardPartial(downloadPath, file)
+					unlock()
+
+					return result, errors.Wrapf(err, "bad file transfer: %s", file.Name)
+				}
+
+				result.Mirrors = append(result.Mirrors, mirror)
+
+				if cachePath != "" {
+					err = os.Rename(downloadPath, cachePath)
+					unlock()
+
+					if err != nil {
+						return result, errors.Wrapf(err, "bad file cache: %s", file.Name)
+					}
+				}
+			}
+
+			if cachePath != "" {
+				err = cache.Install(cachePath, localPath)
+				if err != nil {
+					return result, errors.Wrapf(err, "bad file cache: %s", file.Name)
+				}
+			}
+
+			var localRemoved bool
+
+			if options.Unpack.Matches(file.Name) {
+				fmt.Fprintf(os.Stderr, "unpacking %s\n", file.Name)
+
+				err = archive.Extract(localPath, filepath.Dir(localPath), options.Unpack.StripComponents)
+				if err != nil {
+					return result, errors.Wrapf(err, "bad file unpack: %s", file.Name)
+				}
+
+				if !options.Unpack.KeepArchive {
+					err = os.Remove(localPath)
+					if err != nil {
+						return result, errors.Wrapf(err, "bad file unpack: %s", file.Name)
+					}
+
+					localRemoved = true
+				}
+			}
+
+			if symlinkTmpl != nil && !localRemoved {
+				symlinkRelPath, err := symlinkTmpl.Execute(file)
+				if err != nil {
+					return result, errors.Wrapf(err, "bad file symlink: %s", file.Name)
+				}
+
+				if symlinkRelPath != "" {
+					err = createSymlink(destination, symlinkRelPath, localPath, symlinks)
+					if err != nil {
+						return result, errors.Wrapf(err, "bad file symlink: %s", file.Name)
+					}
+				}
+			}
+		}
+
+		result.Bytes = result.Bytes + file.Size
+		result.Files = result.Files + 1
+	}
+
+	return result, nil
+}
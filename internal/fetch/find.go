@@ -0,0 +1,53 @@
+package fetch
+
+import (
+	"fmt"
+
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink-repository-resource/factory"
+	"github.com/dpb587/metalink/repository"
+	filter_and "github.com/dpb587/metalink/repository/filter/and"
+	"github.com/dpb587/metalink/repository/filter/fileversion"
+	"github.com/pkg/errors"
+)
+
+// FindMetalink loads the repository of a source and returns the single
+// metalink of version which matches the source filters.
+func FindMetalink(s api.Source, version string) (repository.RepositoryMetalink, error) {
+	repo, err := factory.GetRepository(s)
+	if err != nil {
+		return repository.RepositoryMetalink{}, errors.Wrap(err, "creating source")
+	}
+
+	err = repo.Load()
+	if err != nil {
+		return repository.RepositoryMetalink{}, errors.Wrap(err, "loading repository")
+	}
+
+	andFilter := filter_and.NewFilter()
+
+	err = s.ApplyFilter(&andFilter)
+	if err != nil {
+		return repository.RepositoryMetalink{}, errors.Wrap(err, "creating filter")
+	}
+
+	versionFilter, err := fileversion.CreateFilter(version)
+	if err != nil {
+		return repository.RepositoryMetalink{}, errors.Wrap(err, "creating version filter")
+	}
+
+	andFilter.Add(versionFilter)
+
+	metalinks, err := repo.Filter(andFilter)
+	if err != nil {
+		return repository.RepositoryMetalink{}, errors.Wrap(err, "filtering metalinks")
+	}
+
+	if len(metalinks) == 0 {
+		return repository.RepositoryMetalink{}, fmt.Errorf("version not found: %s", version)
+	} else if len(metalinks) > 1 {
+		return repository.RepositoryMetalink{}, fmt.Errorf("multiple matches found: %s", version)
+	}
+
+	return metalinks[0], nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package fetch
+
+// lockPartial never locks where file locks are unsupported, so downloads
+// always use a partial file of their own.
+func lockPartial(path string) (func(), bool, error) {
+	return nil, false, nil
+}
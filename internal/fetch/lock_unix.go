@@ -0,0 +1,36 @@
+//go:build unix
+
+package fetch
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// lockPartial takes an exclusive, non-blocking lock for writing a partial
+// download. The lock is released by the returned function, or by the kernel if
+// the process dies. When another process holds the lock, false is returned.
+func lockPartial(path string) (func(), bool, error) {
+	lockFile, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "opening lock")
+	}
+
+	err = syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		lockFile.Close()
+
+		return nil, false, nil
+	} else if err != nil {
+		lockFile.Close()
+
+		return nil, false, errors.Wrap(err, "locking")
+	}
+
+	return func() {
+		syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+		lockFile.Close()
+	}, true, nil
+}
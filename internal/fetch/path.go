@@ -0,0 +1,140 @@
+package fetch
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/dpb587/metalink"
+	"github.com/pkg/errors"
+)
+
+type pathTemplate struct {
+	tmpl *template.Template
+}
+
+type pathTemplateFile struct {
+	Name    string
+	Version string
+	OS      string
+	MD5     string
+	SHA1    string
+	SHA256  string
+	SHA512  string
+}
+
+func newPathTemplate(name, text string) (*pathTemplate, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pathTemplate{tmpl: tmpl}, nil
+}
+
+func (t pathTemplate) Execute(file metalink.File) (string, error) {
+	data := pathTemplateFile{
+		Name:    file.Name,
+		Version: file.Version,
+	}
+
+	if len(file.OS) > 0 {
+		data.OS = file.OS[0]
+	}
+
+	for _, hash := range file.Hashes {
+		switch hash.Type {
+		case metalink.HashTypeMD5:
+			data.MD5 = hash.Hash
+		case metalink.HashTypeSHA1:
+			data.SHA1 = hash.Hash
+		case metalink.HashTypeSHA256:
+			data.SHA256 = hash.Hash
+		case metalink.HashTypeSHA512:
+			data.SHA512 = hash.Hash
+		}
+	}
+
+	buf := &bytes.Buffer{}
+
+	err := t.tmpl.Execute(buf, data)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func validateFileName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("invalid file name: %q", name)
+	} else if strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid file name (contains path separator): %s", name)
+	}
+
+	return nil
+}
+
+// resolveLocalPath converts a relative path into an absolute path within
+// destination, refusing anything which escapes it or overlaps the resource
+// metadata directory.
+func resolveLocalPath(destination, path string) (string, error) {
+	if path == "" {
+		return "", errors.New("empty path")
+	} else if filepath.IsAbs(path) {
+		return "", fmt.Errorf("absolute path not allowed: %s", path)
+	}
+
+	clean := filepath.Clean(path)
+
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, fmt.Sprintf("..%c", filepath.Separator)) {
+		return "", fmt.Errorf("path outside of destination: %s", path)
+	} else if clean == ".resource" || strings.HasPrefix(clean, fmt.Sprintf(".resource%c", filepath.Separator)) {
+		return "", fmt.Errorf("path conflicts with resource metadata: %s", path)
+	}
+
+	return filepath.Join(destination, clean), nil
+}
+
+// createSymlink links symlinkPath (relative to destination) to target. Each
+// symlink may only be claimed by a single file.
+func createSymlink(destination, symlinkPath, target string, claimed map[string]string) error {
+	linkPath, err := resolveLocalPath(destination, symlinkPath)
+	if err != nil {
+		return err
+	}
+
+	if claimedTarget, found := claimed[linkPath]; found {
+		return fmt.Errorf("symlink %s already points to %s", symlinkPath, claimedTarget)
+	} else if linkPath == target {
+		return fmt.Errorf("symlink %s conflicts with file path", symlinkPath)
+	}
+
+	claimed[linkPath] = target
+
+	relTarget, err := filepath.Rel(filepath.Dir(linkPath), target)
+	if err != nil {
+		return errors.Wrap(err, "relativizing target")
+	}
+
+	err = os.MkdirAll(filepath.Dir(linkPath), 0755)
+	if err != nil {
+		return errors.Wrap(err, "creating directory")
+	}
+
+	if stat, err := os.Lstat(linkPath); err == nil {
+		if stat.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("symlink %s conflicts with an existing file", symlinkPath)
+		}
+
+		err = os.Remove(linkPath)
+		if err != nil {
+			return errors.Wrap(err, "removing existing symlink")
+		}
+	}
+
+	return os.Symlink(relTarget, linkPath)
+}
@@ -0,0 +1,67 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cheggaaa/pb"
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink/file"
+	"github.com/dpb587/metalink/transfer"
+	"github.com/dpb587/metalink/verification"
+)
+
+// transferFile attempts each URL of file in order of preference until one is
+// successfully transferred and verified. The URL which was used is returned.
+func transferFile(source api.Source, downloader transfer.Transfer, meta4file metalink.File, local file.Reference, reporter verification.VerificationResultReporter) (string, error) {
+	candidates, err := source.FileCandidates(meta4file)
+	if err != nil {
+		return "", err
+	} else if len(candidates) == 0 {
+		return "", errors.New("no valid url found")
+	}
+
+	var errs []error
+	var uris []string
+
+	for _, candidate := range candidates {
+		var uri string
+
+		if len(candidate.URLs) > 0 {
+			uri = candidate.URLs[0].URL
+		} else {
+			uri = candidate.MetaURLs[0].URL
+		}
+
+		fmt.Fprintf(os.Stderr, "using mirror %s\n", api.Redact(uri))
+
+		progress := pb.New64(int64(meta4file.Size)).Set(pb.Bytes, true).SetRefreshRate(time.Second).SetWidth(80)
+		progress.SetWriter(os.Stderr)
+
+		err = downloader.TransferFile(candidate, local, progress, reporter)
+		if err == nil {
+			return uri, nil
+		}
+
+		fmt.Fprintf(os.Stderr, "mirror failed: %s\n", api.Redact(err.Error()))
+
+		errs = append(errs, err)
+		uris = append(uris, uri)
+	}
+
+	if len(errs) == 1 {
+		return "", errs[0]
+	}
+
+	messages := make([]string, len(errs))
+
+	for idx, err := range errs {
+		messages[idx] = fmt.Sprintf("%s: %s", uris[idx], err)
+	}
+
+	return "", fmt.Errorf("all %d mirrors failed: %s", len(errs), strings.Join(messages, "; "))
+}
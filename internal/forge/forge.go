@@ -0,0 +1,156 @@
+package forge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PullRequest describes a pull (or merge) request to be opened.
+type PullRequest struct {
+	// Repository is the path of the repository (e.g. owner/name).
+	Repository string
+	Head       string
+	Base       string
+	Title      string
+	Body       string
+	AutoMerge  bool
+}
+
+// Forge opens pull requests against a hosted git service.
+type Forge interface {
+	// CreatePullRequest opens the pull request, or reuses an open pull request
+	// of the same head and base (e.g. of a retried publish), and returns its
+	// web URL.
+	CreatePullRequest(pr PullRequest) (string, error)
+}
+
+// New returns the forge of a type (github, gitlab, or gitea). The API URL
+// defaults to the public service when supported.
+func New(httpClient *http.Client, forgeType, apiURL, token string) (Forge, error) {
+	client := apiClient{
+		httpClient: httpClient,
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+	}
+
+	switch forgeType {
+	case "github":
+		if client.apiURL == "" {
+			client.apiURL = "https://api.github.com"
+		}
+
+		if token != "" {
+			client.authorization = fmt.Sprintf("Bearer %s", token)
+		}
+
+		return GitHub{client: client}, nil
+	case "gitlab":
+		if client.apiURL == "" {
+			client.apiURL = "https://gitlab.com/api/v4"
+		}
+
+		client.header = "PRIVATE-TOKEN"
+		client.authorization = token
+
+		return GitLab{client: client}, nil
+	case "gitea":
+		if client.apiURL == "" {
+			return nil, errors.New("gitea requires an API URL")
+		}
+
+		if token != "" {
+			client.authorization = fmt.Sprintf("token %s", token)
+		}
+
+		return Gitea{client: client}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported forge: %s", forgeType)
+}
+
+type apiClient struct {
+	httpClient    *http.Client
+	apiURL        string
+	header        string
+	authorization string
+}
+
+// send requests a path of the API (or an absolute URL) with a JSON body and
+// decodes the JSON response into result, if not nil.
+func (c apiClient) send(method, path string, body, result interface{}) error {
+	url := path
+	if !strings.Contains(url, "://") {
+		url = c.apiURL + path
+	}
+
+	var reader io.Reader
+
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "encoding request")
+		}
+
+		reader = bytes.NewReader(bodyBytes)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.authorization != "" {
+		header := c.header
+		if header == "" {
+			header = "Authorization"
+		}
+
+		req.Header.Set(header, c.authorization)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "requesting %s", req.URL.Path)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return responseError(res)
+	} else if result == nil {
+		return nil
+	}
+
+	err = json.NewDecoder(res.Body).Decode(result)
+	if err != nil {
+		return errors.Wrap(err, "decoding response")
+	}
+
+	return nil
+}
+
+func responseError(res *http.Response) error {
+	var body struct {
+		Message interface{} `json:"message"`
+	}
+
+	bodyBytes, _ := ioutil.ReadAll(io.LimitReader(res.Body, 64*1024))
+
+	if json.Unmarshal(bodyBytes, &body) == nil && body.Message != nil {
+		return fmt.Errorf("unexpected response: %s: %v", res.Status, body.Message)
+	}
+
+	return fmt.Errorf("unexpected response: %s", res.Status)
+}
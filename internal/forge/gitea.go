@@ -0,0 +1,79 @@
+package forge
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type Gitea struct {
+	client apiClient
+}
+
+var _ Forge = Gitea{}
+
+type giteaPullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+func (f Gitea) CreatePullRequest(pr PullRequest) (string, error) {
+	created, err := f.findPullRequest(pr)
+	if err != nil {
+		return "", errors.Wrap(err, "finding pull request")
+	}
+
+	if created == nil {
+		created = &giteaPullRequest{}
+
+		err = f.client.send(http.MethodPost, "/repos/"+pr.Repository+"/pulls", map[string]interface{}{
+			"head":  pr.Head,
+			"base":  pr.Base,
+			"title": pr.Title,
+			"body":  pr.Body,
+		}, created)
+		if err != nil {
+			return "", errors.Wrap(err, "creating pull request")
+		}
+	}
+
+	if pr.AutoMerge {
+		err = f.client.send(http.MethodPost, fmt.Sprintf("/repos/%s/pulls/%d/merge", pr.Repository, created.Number), map[string]interface{}{
+			"Do":                        "merge",
+			"merge_when_checks_succeed": true,
+		}, nil)
+		if err != nil {
+			return created.HTMLURL, errors.Wrap(err, "enabling auto-merge")
+		}
+	}
+
+	return created.HTMLURL, nil
+}
+
+// findPullRequest returns the open pull request of the head and base, if any.
+// The API does not filter by branches, so every page is searched.
+func (f Gitea) findPullRequest(pr PullRequest) (*giteaPullRequest, error) {
+	for page := 1; ; page++ {
+		var open []giteaPullRequest
+
+		err := f.client.send(http.MethodGet, fmt.Sprintf("/repos/%s/pulls?state=open&limit=50&page=%d", pr.Repository, page), nil, &open)
+		if err != nil {
+			return nil, err
+		} else if len(open) == 0 {
+			return nil, nil
+		}
+
+		for _, candidate := range open {
+			if candidate.Head.Ref == pr.Head && candidate.Base.Ref == pr.Base {
+				return &candidate, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package forge
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type GitHub struct {
+	client apiClient
+}
+
+var _ Forge = GitHub{}
+
+type githubPullRequest struct {
+	HTMLURL string `json:"html_url"`
+	NodeID  string `json:"node_id"`
+}
+
+func (f GitHub) CreatePullRequest(pr PullRequest) (string, error) {
+	var open []githubPullRequest
+
+	// heads are qualified by the owner to exclude pull requests of forks
+	err := f.client.send(http.MethodGet, "/repos/"+pr.Repository+"/pulls?"+url.Values{
+		"state": {"open"},
+		"head":  {strings.SplitN(pr.Repository, "/", 2)[0] + ":" + pr.Head},
+		"base":  {pr.Base},
+	}.Encode(), nil, &open)
+	if err != nil {
+		return "", errors.Wrap(err, "finding pull request")
+	}
+
+	var created githubPullRequest
+
+	if len(open) > 0 {
+		created = open[0]
+	} else {
+		err = f.client.send(http.MethodPost, "/repos/"+pr.Repository+"/pulls", map[string]interface{}{
+			"title": pr.Title,
+			"body":  pr.Body,
+			"head":  pr.Head,
+			"base":  pr.Base,
+		}, &created)
+		if err != nil {
+			return "", errors.Wrap(err, "creating pull request")
+		}
+	}
+
+	if pr.AutoMerge {
+		// auto-merge is only available through the GraphQL API
+		var response struct {
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+
+		err = f.client.send(http.MethodPost, f.graphqlURL(), map[string]interface{}{
+			"query": `mutation($id: ID!) { enablePullRequestAutoMerge(input: {pullRequestId: $id}) { clientMutationId } }`,
+			"variables": map[string]interface{}{
+				"id": created.NodeID,
+			},
+		}, &response)
+		if err != nil {
+			return created.HTMLURL, errors.Wrap(err, "enabling auto-merge")
+		} else if len(response.Errors) > 0 {
+			return created.HTMLURL, errors.Errorf("enabling auto-merge: %s", response.Errors[0].Message)
+		}
+	}
+
+	return created.HTMLURL, nil
+}
+
+// graphqlURL returns the GraphQL endpoint, which GitHub Enterprise serves at
+// /api/graphql rather than under the REST prefix.
+func (f GitHub) graphqlURL() string {
+	if strings.HasSuffix(f.client.apiURL, "/api/v3") {
+		return strings.TrimSuffix(f.client.apiURL, "/v3") + "/graphql"
+	}
+
+	return f.client.apiURL + "/graphql"
+}
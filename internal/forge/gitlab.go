@@ -0,0 +1,62 @@
+package forge
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+type GitLab struct {
+	client apiClient
+}
+
+var _ Forge = GitLab{}
+
+type gitlabMergeRequest struct {
+	IID    int    `json:"iid"`
+	WebURL string `json:"web_url"`
+}
+
+func (f GitLab) CreatePullRequest(pr PullRequest) (string, error) {
+	project := fmt.Sprintf("/projects/%s", url.PathEscape(pr.Repository))
+
+	var open []gitlabMergeRequest
+
+	err := f.client.send(http.MethodGet, project+"/merge_requests?"+url.Values{
+		"state":         {"opened"},
+		"source_branch": {pr.Head},
+		"target_branch": {pr.Base},
+	}.Encode(), nil, &open)
+	if err != nil {
+		return "", errors.Wrap(err, "finding merge request")
+	}
+
+	var created gitlabMergeRequest
+
+	if len(open) > 0 {
+		created = open[0]
+	} else {
+		err = f.client.send(http.MethodPost, project+"/merge_requests", map[string]interface{}{
+			"source_branch": pr.Head,
+			"target_branch": pr.Base,
+			"title":         pr.Title,
+			"description":   pr.Body,
+		}, &created)
+		if err != nil {
+			return "", errors.Wrap(err, "creating merge request")
+		}
+	}
+
+	if pr.AutoMerge {
+		err = f.client.send(http.MethodPut, fmt.Sprintf("%s/merge_requests/%d/merge", project, created.IID), map[string]interface{}{
+			"merge_when_pipeline_succeeds": true,
+		}, nil)
+		if err != nil {
+			return created.WebURL, errors.Wrap(err, "enabling auto-merge")
+		}
+	}
+
+	return created.WebURL, nil
+}
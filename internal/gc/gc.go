@@ -0,0 +1,187 @@
+package gc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink-repository-resource/internal/presign"
+	"github.com/dpb587/metalink-repository-resource/internal/storage"
+	"github.com/dpb587/metalink/repository"
+	"github.com/pkg/errors"
+)
+
+type Options struct {
+	// MinAge protects recently uploaded objects which may belong to a metalink
+	// which is still being published.
+	MinAge time.Duration
+
+	// Delete removes unreferenced objects instead of only reporting them.
+	Delete bool
+
+	// AllowRoot permits destinations whose static prefix is the root of a
+	// bucket (or of the file system), where unrelated objects are likely.
+	AllowRoot bool
+
+	// RepositoryURI is the source of the metalinks, whose objects are never
+	// unreferenced (e.g. when stored in the same bucket as mirrored files).
+	RepositoryURI string
+}
+
+type Object struct {
+	URI          string    `json:"uri"`
+	Size         uint64    `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Deleted      bool      `json:"deleted"`
+}
+
+type Report struct {
+	Prefixes     []string `json:"prefixes"`
+	Referenced   int      `json:"referenced"`
+	Objects      int      `json:"objects"`
+	Unreferenced []Object `json:"unreferenced"`
+}
+
+type StorageGetter func(uri string) (storage.Storage, error)
+
+// DestinationPrefix returns the static part of a mirror destination template
+// (i.e. everything before the first template action). Unless allowRoot, the
+// prefix must be below the bucket (i.e. the first path segment) of the
+// destination.
+func DestinationPrefix(destination string, allowRoot bool) (string, error) {
+	prefix := destination
+
+	if idx := strings.Index(destination, "{{"); idx >= 0 {
+		prefix = destination[0:idx]
+	}
+
+	schemeIdx := strings.Index(prefix, "://")
+	if schemeIdx < 0 {
+		return "", fmt.Errorf("destination has no static prefix: %s", destination)
+	}
+
+	hostPath := strings.SplitN(prefix[schemeIdx+3:], "/", 2)
+	if len(hostPath) != 2 {
+		return "", fmt.Errorf("destination has no static prefix: %s", destination)
+	} else if allowRoot {
+		return prefix, nil
+	}
+
+	bucketKey := strings.SplitN(hostPath[1], "/", 2)
+	if len(bucketKey) != 2 || bucketKey[0] == "" || bucketKey[1] == "" {
+		return "", fmt.Errorf("destination prefix is the root of a bucket (use --allow-root to include everything): %s", destination)
+	}
+
+	return prefix, nil
+}
+
+// Collect finds objects under the mirror destinations which are not referenced
+// by any of the metalinks.
+func Collect(metalinks []repository.RepositoryMetalink, mirrorFiles []api.MirrorFileParams, getStorage StorageGetter, options Options) (Report, error) {
+	report := Report{
+		Unreferenced: []Object{},
+	}
+
+	referenced := map[string]struct{}{}
+
+	for _, meta4 := range metalinks {
+		for _, file := range meta4.Metalink.Files {
+			for _, url := range file.URLs {
+				referenced[url.URL] = struct{}{}
+
+				if unsigned, _, _, ok := presign.Parse(url.URL); ok {
+					// replaced by a presigned URL
+					referenced[unsigned] = struct{}{}
+				}
+			}
+
+			for _, metaURL := range file.MetaURLs {
+				referenced[metaURL.URL] = struct{}{}
+			}
+		}
+	}
+
+	report.Referenced = len(referenced)
+
+	seen := map[string]struct{}{}
+	threshold := time.Now().Add(-options.MinAge)
+
+	for _, mirrorFile := range mirrorFiles {
+		prefix, err := DestinationPrefix(mirrorFile.Destination, options.AllowRoot)
+		if err != nil {
+			return report, err
+		}
+
+		if _, found := seen[prefix]; found {
+			continue
+		}
+
+		seen[prefix] = struct{}{}
+		report.Prefixes = append(report.Prefixes, prefix)
+
+		store, err := getStorage(prefix)
+		if err != nil {
+			return report, errors.Wrapf(err, "getting storage: %s", prefix)
+		}
+
+		objects, err := store.List(prefix)
+		if err != nil {
+			return report, errors.Wrapf(err, "listing objects: %s", prefix)
+		}
+
+		sort.Slice(objects, func(i, j int) bool {
+			return objects[i].URI < objects[j].URI
+		})
+
+		for _, object := range objects {
+			report.Objects++
+
+			if isReferenced(referenced, object) || isRepository(options.RepositoryURI, object) || object.LastModified.After(threshold) {
+				continue
+			}
+
+			unreferenced := Object{
+				URI:          object.URI,
+				Size:         object.Size,
+				LastModified: object.LastModified,
+			}
+
+			if options.Delete {
+				err = store.Delete(object.URI)
+				if err != nil {
+					return report, errors.Wrapf(err, "deleting object: %s", object.URI)
+				}
+
+				unreferenced.Deleted = true
+			}
+
+			report.Unreferenced = append(report.Unreferenced, unreferenced)
+		}
+	}
+
+	return report, nil
+}
+
+func isReferenced(referenced map[string]struct{}, object storage.Object) bool {
+	if _, found := referenced[object.URI]; found {
+		return true
+	} else if object.ReaderURI == "" {
+		return false
+	}
+
+	_, found := referenced[object.ReaderURI]
+
+	return found
+}
+
+func isRepository(repositoryURI string, object storage.Object) bool {
+	if repositoryURI == "" {
+		return false
+	}
+
+	repositoryURI = strings.TrimSuffix(repositoryURI, "/")
+
+	return object.URI == repositoryURI || strings.HasPrefix(object.URI, repositoryURI+"/")
+}
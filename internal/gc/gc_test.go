@@ -0,0 +1,151 @@
+package gc_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink-repository-resource/internal/gc"
+	"github.com/dpb587/metalink-repository-resource/internal/storage"
+	"github.com/dpb587/metalink/repository"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeStorage struct {
+	objects []storage.Object
+	deleted []string
+}
+
+func (s *fakeStorage) List(prefix string) ([]storage.Object, error) {
+	var objects []storage.Object
+
+	for _, object := range s.objects {
+		if strings.HasPrefix(object.URI, prefix) {
+			objects = append(objects, object)
+		}
+	}
+
+	return objects, nil
+}
+
+func (s *fakeStorage) Delete(uri string) error {
+	s.deleted = append(s.deleted, uri)
+
+	return nil
+}
+
+var _ = Describe("DestinationPrefix", func() {
+	It("uses everything before the first template action", func() {
+		Expect(gc.DestinationPrefix("s3://s3.amazonaws.com/bucket/blobs/{{.Version}}/{{.Name}}", false)).To(Equal("s3://s3.amazonaws.com/bucket/blobs/"))
+		Expect(gc.DestinationPrefix("file:///tmp/blobs/v{{.Version}}", false)).To(Equal("file:///tmp/blobs/v"))
+	})
+
+	It("refuses prefixes at or above the root of a bucket", func() {
+		for _, destination := range []string{
+			"s3://s3.amazonaws.com/bucket/{{.Version}}/{{.Name}}",
+			"s3://s3.amazonaws.com/bucket{{.Version}}/{{.Name}}",
+			"s3://s3.amazonaws.com/{{.Name}}",
+			"file:///{{.Name}}",
+		} {
+			_, err := gc.DestinationPrefix(destination, false)
+			Expect(err).To(MatchError(ContainSubstring("destination prefix is the root of a bucket")), destination)
+		}
+	})
+
+	It("optionally allows the root of a bucket", func() {
+		Expect(gc.DestinationPrefix("s3://s3.amazonaws.com/bucket/{{.Name}}", true)).To(Equal("s3://s3.amazonaws.com/bucket/"))
+	})
+
+	It("requires a scheme and path", func() {
+		_, err := gc.DestinationPrefix("s3://{{.Name}}", true)
+		Expect(err).To(MatchError(ContainSubstring("destination has no static prefix")))
+
+		_, err = gc.DestinationPrefix("{{.Name}}", true)
+		Expect(err).To(MatchError(ContainSubstring("destination has no static prefix")))
+	})
+})
+
+var _ = Describe("Collect", func() {
+	var store *fakeStorage
+	var old time.Time
+
+	getStorage := func(string) (storage.Storage, error) {
+		return store, nil
+	}
+
+	metalinks := func(urls ...string) []repository.RepositoryMetalink {
+		file := metalink.File{Name: "file.tgz"}
+
+		for _, url := range urls {
+			file.URLs = append(file.URLs, metalink.URL{URL: url})
+		}
+
+		return []repository.RepositoryMetalink{{Metalink: metalink.Metalink{Files: []metalink.File{file}}}}
+	}
+
+	BeforeEach(func() {
+		old = time.Now().Add(-48 * time.Hour)
+		store = &fakeStorage{}
+	})
+
+	It("deletes objects under the destination which are not referenced", func() {
+		store.objects = []storage.Object{
+			{URI: "s3://s3.amazonaws.com/bucket/blobs/kept.tgz", ReaderURI: "https://s3.amazonaws.com/bucket/blobs/kept.tgz", LastModified: old},
+			{URI: "s3://s3.amazonaws.com/bucket/blobs/retracted.tgz", LastModified: old},
+			{URI: "s3://s3.amazonaws.com/bucket/blobs/recent.tgz", LastModified: time.Now()},
+			{URI: "s3://s3.amazonaws.com/bucket/other/unrelated.tgz", LastModified: old},
+		}
+
+		report, err := gc.Collect(
+			metalinks("https://s3.amazonaws.com/bucket/blobs/kept.tgz"),
+			[]api.MirrorFileParams{{Destination: "s3://s3.amazonaws.com/bucket/blobs/{{.Name}}"}},
+			getStorage,
+			gc.Options{MinAge: 24 * time.Hour, Delete: true},
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Objects).To(Equal(3))
+		Expect(store.deleted).To(Equal([]string{"s3://s3.amazonaws.com/bucket/blobs/retracted.tgz"}))
+	})
+
+	It("keeps objects referenced by presigned URLs", func() {
+		store.objects = []storage.Object{
+			{URI: "s3://s3.amazonaws.com/bucket/blobs/kept.tgz", ReaderURI: "https://s3.amazonaws.com/bucket/blobs/kept.tgz", LastModified: old},
+			{URI: "s3://s3.amazonaws.com/bucket/blobs/retracted.tgz", ReaderURI: "https://s3.amazonaws.com/bucket/blobs/retracted.tgz", LastModified: old},
+		}
+
+		report, err := gc.Collect(
+			metalinks("https://s3.amazonaws.com/bucket/blobs/kept.tgz?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=fake-key%2F20240101%2Fus-east-1%2Fs3%2Faws4_request&X-Amz-Date=20240101T000000Z&X-Amz-Expires=604800&X-Amz-SignedHeaders=host&X-Amz-Signature=fake-signature"),
+			[]api.MirrorFileParams{{Destination: "s3://s3.amazonaws.com/bucket/blobs/{{.Name}}"}},
+			getStorage,
+			gc.Options{Delete: true},
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Unreferenced).To(HaveLen(1))
+		Expect(store.deleted).To(Equal([]string{"s3://s3.amazonaws.com/bucket/blobs/retracted.tgz"}))
+	})
+
+	It("never collects objects of the repository", func() {
+		store.objects = []storage.Object{
+			{URI: "s3://s3.amazonaws.com/bucket/blobs/metalinks/v1.0.0.meta4", LastModified: old},
+			{URI: "s3://s3.amazonaws.com/bucket/blobs/retracted.tgz", LastModified: old},
+		}
+
+		report, err := gc.Collect(
+			metalinks(),
+			[]api.MirrorFileParams{{Destination: "s3://s3.amazonaws.com/bucket/blobs/{{.Name}}"}},
+			getStorage,
+			gc.Options{Delete: true, RepositoryURI: "s3://s3.amazonaws.com/bucket/blobs/metalinks"},
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Unreferenced).To(HaveLen(1))
+		Expect(store.deleted).To(Equal([]string{"s3://s3.amazonaws.com/bucket/blobs/retracted.tgz"}))
+	})
+
+	It("refuses destinations at the root of a bucket", func() {
+		_, err := gc.Collect(metalinks(), []api.MirrorFileParams{{Destination: "s3://s3.amazonaws.com/bucket/{{.Name}}"}}, getStorage, gc.Options{Delete: true})
+		Expect(err).To(MatchError(ContainSubstring("destination prefix is the root of a bucket")))
+		Expect(store.deleted).To(BeEmpty())
+	})
+})
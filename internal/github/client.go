@@ -0,0 +1,143 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultAPIURL = "https://api.github.com"
+
+type Release struct {
+	TagName     string     `json:"tag_name"`
+	Name        string     `json:"name"`
+	Draft       bool       `json:"draft"`
+	Prerelease  bool       `json:"prerelease"`
+	PublishedAt *time.Time `json:"published_at"`
+	Assets      []Asset    `json:"assets"`
+}
+
+type Asset struct {
+	URL                string `json:"url"`
+	Name               string `json:"name"`
+	Size               uint64 `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+
+	// Digest is provided by newer API versions (e.g. sha256:abc...).
+	Digest string `json:"digest"`
+}
+
+// Client is a minimal client for the releases endpoints of the GitHub REST API.
+type Client struct {
+	httpClient *http.Client
+	apiURL     string
+	token      string
+}
+
+func NewClient(httpClient *http.Client, apiURL, token string) *Client {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+
+	return &Client{
+		httpClient: httpClient,
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		token:      token,
+	}
+}
+
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="?next"?`)
+
+// ListReleases returns every release of a repository, following pagination.
+func (c *Client) ListReleases(owner, repository string) ([]Release, error) {
+	var releases []Release
+
+	next := fmt.Sprintf("%s/repos/%s/%s/releases?per_page=100", c.apiURL, owner, repository)
+
+	for next != "" {
+		res, err := c.do(next, "application/vnd.github+json")
+		if err != nil {
+			return nil, err
+		} else if res.StatusCode != http.StatusOK {
+			return nil, responseError(res)
+		}
+
+		var page []Release
+
+		err = json.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding releases")
+		}
+
+		releases = append(releases, page...)
+		next = ""
+
+		if match := linkNextRegex.FindStringSubmatch(res.Header.Get("Link")); match != nil {
+			nextURL, err := res.Request.URL.Parse(match[1])
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing next link")
+			}
+
+			next = nextURL.String()
+		}
+	}
+
+	return releases, nil
+}
+
+// DownloadAsset downloads the content of an asset through the API, which also
+// works for private repositories.
+func (c *Client) DownloadAsset(asset Asset) (io.ReadCloser, error) {
+	res, err := c.do(asset.URL, "application/octet-stream")
+	if err != nil {
+		return nil, err
+	} else if res.StatusCode != http.StatusOK {
+		return nil, responseError(res)
+	}
+
+	return res.Body, nil
+}
+
+func (c *Client) do(url, accept string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+
+	req.Header.Set("Accept", accept)
+
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "requesting %s", req.URL.Path)
+	}
+
+	return res, nil
+}
+
+func responseError(res *http.Response) error {
+	defer res.Body.Close()
+
+	var body struct {
+		Message string `json:"message"`
+	}
+
+	bodyBytes, _ := ioutil.ReadAll(io.LimitReader(res.Body, 64*1024))
+
+	if json.Unmarshal(bodyBytes, &body) == nil && body.Message != "" {
+		return fmt.Errorf("unexpected response: %s: %s", res.Status, body.Message)
+	}
+
+	return fmt.Errorf("unexpected response: %s", res.Status)
+}
@@ -0,0 +1,142 @@
+package jsonpath
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type step struct {
+	key      string
+	index    int
+	wildcard bool
+	isIndex  bool
+}
+
+// Selector is a subset of JSONPath supporting member access (`.name` or
+// `['name']`), array indices (`[0]`), and wildcards (`[*]` or `.*`). A
+// leading `$` is optional and selectors are evaluated relative to the given
+// value.
+type Selector struct {
+	expr  string
+	steps []step
+}
+
+func Parse(expr string) (Selector, error) {
+	selector := Selector{expr: expr}
+	remaining := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+
+	for remaining != "" {
+		switch {
+		case strings.HasPrefix(remaining, "["):
+			end := strings.Index(remaining, "]")
+			if end < 0 {
+				return selector, fmt.Errorf("unterminated bracket: %s", expr)
+			}
+
+			inner := remaining[1:end]
+			remaining = remaining[end+1:]
+
+			if inner == "*" {
+				selector.steps = append(selector.steps, step{wildcard: true})
+			} else if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				selector.steps = append(selector.steps, step{key: inner[1 : len(inner)-1]})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return selector, fmt.Errorf("invalid index %q: %s", inner, expr)
+				}
+
+				selector.steps = append(selector.steps, step{index: index, isIndex: true})
+			}
+		default:
+			remaining = strings.TrimPrefix(remaining, ".")
+
+			end := strings.IndexAny(remaining, ".[")
+			if end < 0 {
+				end = len(remaining)
+			}
+
+			key := remaining[0:end]
+			remaining = remaining[end:]
+
+			if key == "" {
+				return selector, fmt.Errorf("empty member name: %s", expr)
+			} else if key == "*" {
+				selector.steps = append(selector.steps, step{wildcard: true})
+			} else {
+				selector.steps = append(selector.steps, step{key: key})
+			}
+		}
+	}
+
+	return selector, nil
+}
+
+func (s Selector) String() string {
+	return s.expr
+}
+
+// IsZero is true for selectors which were never parsed.
+func (s Selector) IsZero() bool {
+	return s.expr == ""
+}
+
+// Select returns all values matched by the selector. Missing members and
+// indices are ignored rather than treated as errors.
+func (s Selector) Select(value interface{}) []interface{} {
+	values := []interface{}{value}
+
+	for _, step := range s.steps {
+		var next []interface{}
+
+		for _, value := range values {
+			switch typed := value.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					keys := make([]string, 0, len(typed))
+
+					for k := range typed {
+						keys = append(keys, k)
+					}
+
+					sort.Strings(keys)
+
+					for _, k := range keys {
+						next = append(next, typed[k])
+					}
+				} else if v, ok := typed[step.key]; ok && !step.isIndex {
+					next = append(next, v)
+				}
+			case []interface{}:
+				if step.wildcard {
+					next = append(next, typed...)
+				} else if step.isIndex {
+					index := step.index
+					if index < 0 {
+						index += len(typed)
+					}
+
+					if index >= 0 && index < len(typed) {
+						next = append(next, typed[index])
+					}
+				}
+			}
+		}
+
+		values = next
+	}
+
+	return values
+}
+
+// SelectOne returns the first matched value, if any.
+func (s Selector) SelectOne(value interface{}) (interface{}, bool) {
+	values := s.Select(value)
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	return values[0], true
+}
@@ -0,0 +1,231 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink/file/url"
+	"github.com/dpb587/metalink/repository"
+	"github.com/dpb587/metalink/repository/filter"
+	"github.com/dpb587/metalink/repository/source"
+	"github.com/dpb587/metalink/verification/hash"
+)
+
+const (
+	ProblemUnparsable        = "unparsable"
+	ProblemEmptyFiles        = "empty-files"
+	ProblemMissingVersion    = "missing-version"
+	ProblemMixedVersions     = "mixed-versions"
+	ProblemDuplicateVersion  = "duplicate-version"
+	ProblemDuplicateFileName = "duplicate-file-name"
+	ProblemMissingURLs       = "missing-urls"
+	ProblemDeadURL           = "dead-url"
+	ProblemSizeMismatch      = "size-mismatch"
+	ProblemHashMismatch      = "hash-mismatch"
+)
+
+type Options struct {
+	// CheckURLs requests the size of every URL (e.g. HEAD) and compares it with
+	// the size of the file.
+	CheckURLs bool
+
+	// Verify downloads every URL and verifies its hash.
+	Verify bool
+}
+
+type Problem struct {
+	Type    string `json:"type"`
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+	File    string `json:"file,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Message string `json:"message"`
+}
+
+type Report struct {
+	Metalinks int       `json:"metalinks"`
+	Files     int       `json:"files"`
+	URLs      int       `json:"urls"`
+	Problems  []Problem `json:"problems"`
+}
+
+func (r *Report) add(p Problem) {
+	r.Problems = append(r.Problems, p)
+}
+
+// Lint loads the repository and reports structural problems of its metalinks.
+// Sources fail to load when any metalink cannot be parsed, so such problems
+// are reported for the repository as a whole.
+func Lint(repo source.Source, f filter.Filter, urlLoader url.Loader, options Options) (Report, error) {
+	report := Report{
+		Problems: []Problem{},
+	}
+
+	err := repo.Load()
+	if err != nil {
+		report.add(Problem{
+			Type:    ProblemUnparsable,
+			Message: err.Error(),
+		})
+
+		return report, nil
+	}
+
+	metalinks, err := repo.Filter(f)
+	if err != nil {
+		return report, err
+	}
+
+	sort.SliceStable(metalinks, func(i, j int) bool {
+		return metalinks[i].Reference.Path < metalinks[j].Reference.Path
+	})
+
+	versionPaths := map[string][]string{}
+
+	for _, meta4 := range metalinks {
+		report.Metalinks++
+
+		version := lintMetalink(&report, meta4)
+		if version != "" {
+			versionPaths[version] = append(versionPaths[version], meta4.Reference.Path)
+		}
+
+		for _, file := range meta4.Metalink.Files {
+			report.Files++
+			report.URLs += len(file.URLs)
+
+			if options.CheckURLs || options.Verify {
+				lintURLs(&report, meta4, file, urlLoader, options)
+			}
+		}
+	}
+
+	var versions []string
+
+	for version := range versionPaths {
+		versions = append(versions, version)
+	}
+
+	sort.Strings(versions)
+
+	for _, version := range versions {
+		paths := versionPaths[version]
+		if len(paths) < 2 {
+			continue
+		}
+
+		for _, path := range paths {
+			report.add(Problem{
+				Type:    ProblemDuplicateVersion,
+				Path:    path,
+				Version: version,
+				Message: fmt.Sprintf("version is published by %d metalinks", len(paths)),
+			})
+		}
+	}
+
+	return report, nil
+}
+
+func lintMetalink(report *Report, meta4 repository.RepositoryMetalink) string {
+	path := meta4.Reference.Path
+
+	if len(meta4.Metalink.Files) == 0 {
+		report.add(Problem{
+			Type:    ProblemEmptyFiles,
+			Path:    path,
+			Message: "metalink has no files",
+		})
+
+		return ""
+	}
+
+	version := meta4.Metalink.Files[0].Version
+	fileNames := map[string]bool{}
+
+	for _, file := range meta4.Metalink.Files {
+		if file.Version == "" {
+			report.add(Problem{
+				Type:    ProblemMissingVersion,
+				Path:    path,
+				File:    file.Name,
+				Message: "file has no version",
+			})
+		} else if file.Version != version {
+			report.add(Problem{
+				Type:    ProblemMixedVersions,
+				Path:    path,
+				Version: file.Version,
+				File:    file.Name,
+				Message: fmt.Sprintf("file version differs from metalink version %s", version),
+			})
+		}
+
+		if fileNames[file.Name] {
+			report.add(Problem{
+				Type:    ProblemDuplicateFileName,
+				Path:    path,
+				Version: version,
+				File:    file.Name,
+				Message: "file name is used more than once",
+			})
+		}
+
+		fileNames[file.Name] = true
+
+		if len(file.URLs) == 0 && len(file.MetaURLs) == 0 {
+			report.add(Problem{
+				Type:    ProblemMissingURLs,
+				Path:    path,
+				Version: version,
+				File:    file.Name,
+				Message: "file has no urls",
+			})
+		}
+	}
+
+	return version
+}
+
+func lintURLs(report *Report, meta4 repository.RepositoryMetalink, file metalink.File, urlLoader url.Loader, options Options) {
+	problem := func(problemType string, fileURL metalink.URL, message string) {
+		report.add(Problem{
+			Type:    problemType,
+			Path:    meta4.Reference.Path,
+			Version: file.Version,
+			File:    file.Name,
+			URL:     fileURL.URL,
+			Message: message,
+		})
+	}
+
+	for _, fileURL := range file.URLs {
+		ref, err := urlLoader.LoadURL(fileURL)
+		if err != nil {
+			problem(ProblemDeadURL, fileURL, err.Error())
+
+			continue
+		}
+
+		size, err := ref.Size()
+		if err != nil {
+			problem(ProblemDeadURL, fileURL, err.Error())
+
+			continue
+		} else if file.Size > 0 && size != file.Size {
+			problem(ProblemSizeMismatch, fileURL, fmt.Sprintf("expected %d bytes but found %d", file.Size, size))
+
+			continue
+		}
+
+		if !options.Verify || len(file.Hashes) == 0 {
+			continue
+		}
+
+		result := hash.StrongestSignerVerifier.Verify(ref, file)
+		if result.Error() != nil {
+			problem(ProblemHashMismatch, fileURL, result.Error().Error())
+		}
+	}
+}
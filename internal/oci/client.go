@@ -0,0 +1,464 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MediaTypeImageManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeEmptyJSON     = "application/vnd.oci.empty.v1+json"
+
+	AnnotationTitle = "org.opencontainers.image.title"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// EmptyJSON is the content of the empty config used by artifacts.
+var EmptyJSON = []byte("{}")
+
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Client is a minimal client of the OCI distribution API supporting
+// anonymous, basic, and bearer token authentication.
+type Client struct {
+	httpClient *http.Client
+	registry   string
+	options    Options
+
+	tokensMutex sync.Mutex
+	tokens      map[string]string
+}
+
+func NewClient(httpClient *http.Client, registry string, options Options) *Client {
+	return &Client{
+		httpClient: httpClient,
+		registry:   registry,
+		options:    options,
+		tokens:     map[string]string{},
+	}
+}
+
+func Digest(content []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+}
+
+func (c *Client) url(format string, args ...interface{}) string {
+	scheme := "https"
+	if c.options.PlainHTTP {
+		scheme = "http"
+	}
+
+	return fmt.Sprintf("%s://%s/v2/%s", scheme, c.registry, fmt.Sprintf(format, args...))
+}
+
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="?next"?`)
+
+func (c *Client) ListTags(repository string) ([]string, error) {
+	var tags []string
+
+	next := c.url("%s/tags/list", repository)
+
+	for next != "" {
+		res, err := c.do(repository, "pull", http.MethodGet, next, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode == http.StatusNotFound {
+			res.Body.Close()
+
+			// repositories do not exist until something is pushed
+			return tags, nil
+		} else if res.StatusCode != http.StatusOK {
+			return nil, responseError(res)
+		}
+
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+
+		err = json.NewDecoder(res.Body).Decode(&list)
+		res.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding tags")
+		}
+
+		tags = append(tags, list.Tags...)
+		next = ""
+
+		if match := linkNextRegex.FindStringSubmatch(res.Header.Get("Link")); match != nil {
+			nextURL, err := res.Request.URL.Parse(match[1])
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing next link")
+			}
+
+			next = nextURL.String()
+		}
+	}
+
+	return tags, nil
+}
+
+func (c *Client) GetManifest(repository, reference string) (Manifest, error) {
+	var manifest Manifest
+
+	res, err := c.do(repository, "pull", http.MethodGet, c.url("%s/manifests/%s", repository, reference), http.Header{"Accept": []string{MediaTypeImageManifest}}, nil)
+	if err != nil {
+		return manifest, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return manifest, ErrNotFound
+	} else if res.StatusCode != http.StatusOK {
+		return manifest, responseError(res)
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&manifest)
+	if err != nil {
+		return manifest, errors.Wrap(err, "decoding manifest")
+	}
+
+	return manifest, nil
+}
+
+func (c *Client) PutManifest(repository, reference string, manifest Manifest) (string, error) {
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return "", errors.Wrap(err, "marshaling manifest")
+	}
+
+	res, err := c.do(repository, "pull,push", http.MethodPut, c.url("%s/manifests/%s", repository, reference), http.Header{"Content-Type": []string{manifest.MediaType}}, manifestBytes)
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		return "", responseError(res)
+	}
+
+	return Digest(manifestBytes), nil
+}
+
+func (c *Client) GetBlob(repository, digest string) (io.ReadCloser, int64, error) {
+	res, err := c.do(repository, "pull", http.MethodGet, c.url("%s/blobs/%s", repository, digest), nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+
+		return nil, 0, ErrNotFound
+	} else if res.StatusCode != http.StatusOK {
+		return nil, 0, responseError(res)
+	}
+
+	return res.Body, res.ContentLength, nil
+}
+
+// StatBlob returns the size of a blob or ErrNotFound.
+func (c *Client) StatBlob(repository, digest string) (int64, error) {
+	res, err := c.do(repository, "pull", http.MethodHead, c.url("%s/blobs/%s", repository, digest), nil, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return 0, ErrNotFound
+	} else if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected response: %s", res.Status)
+	}
+
+	return res.ContentLength, nil
+}
+
+// PushBlob uploads content with the given digest (unless it already exists)
+// using a monolithic upload.
+func (c *Client) PushBlob(repository, digest string, size int64, content io.Reader) error {
+	_, err := c.StatBlob(repository, digest)
+	if err == nil {
+		return nil
+	} else if err != ErrNotFound {
+		return err
+	}
+
+	res, err := c.do(repository, "pull,push", http.MethodPost, c.url("%s/blobs/uploads/", repository), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusAccepted {
+		return responseError(res)
+	}
+
+	location, err := res.Request.URL.Parse(res.Header.Get("Location"))
+	if err != nil {
+		return errors.Wrap(err, "parsing upload location")
+	}
+
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodPut, location.String(), content)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	res, err = c.send(repository, "pull,push", req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		return responseError(res)
+	}
+
+	return nil
+}
+
+func (c *Client) do(repository, actions, method, url string, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+
+	if body != nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	return c.send(repository, actions, req)
+}
+
+// send authenticates requests, retrying once after an authentication
+// challenge. Request bodies which cannot be replayed are only sent after a
+// token was acquired for the scope.
+func (c *Client) send(repository, actions string, req *http.Request) (*http.Response, error) {
+	scope := fmt.Sprintf("repository:%s:%s", repository, actions)
+
+	if req.Body != nil && req.GetBody == nil && !c.hasToken(scope) {
+		// learn the required authentication without consuming the body
+		probe, err := http.NewRequest(http.MethodGet, c.url(""), nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating request")
+		}
+
+		res, err := c.send(repository, actions, probe)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Body.Close()
+	}
+
+	c.authorize(req, scope)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "requesting %s", req.URL.Path)
+	}
+
+	if res.StatusCode != http.StatusUnauthorized {
+		return res, nil
+	}
+
+	challenge := res.Header.Get("WWW-Authenticate")
+	res.Body.Close()
+
+	err = c.authenticate(challenge, scope)
+	if err != nil {
+		return nil, errors.Wrap(err, "authenticating")
+	}
+
+	retry := req.Clone(req.Context())
+
+	if req.Body != nil {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("unauthorized: %s", req.URL.Path)
+		}
+
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			return nil, errors.Wrap(err, "rewinding body")
+		}
+	}
+
+	c.authorize(retry, scope)
+
+	res, err = c.httpClient.Do(retry)
+	if err != nil {
+		return nil, errors.Wrapf(err, "requesting %s", req.URL.Path)
+	}
+
+	return res, nil
+}
+
+func (c *Client) authorize(req *http.Request, scope string) {
+	c.tokensMutex.Lock()
+	defer c.tokensMutex.Unlock()
+
+	if token, ok := c.tokens[scope]; ok {
+		req.Header.Set("Authorization", token)
+	} else if token, ok := c.tokens[""]; ok {
+		req.Header.Set("Authorization", token)
+	}
+}
+
+func (c *Client) hasToken(scope string) bool {
+	c.tokensMutex.Lock()
+	defer c.tokensMutex.Unlock()
+
+	_, scoped := c.tokens[scope]
+	_, basic := c.tokens[""]
+
+	return scoped || basic
+}
+
+var challengeParamRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func (c *Client) authenticate(challenge, scope string) error {
+	scheme := strings.ToLower(strings.SplitN(challenge, " ", 2)[0])
+
+	if scheme == "basic" {
+		if c.options.Username == "" {
+			return errors.New("registry requires credentials")
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(c.options.Username, c.options.Password)
+
+		c.tokensMutex.Lock()
+		c.tokens[""] = req.Header.Get("Authorization")
+		c.tokensMutex.Unlock()
+
+		return nil
+	} else if scheme != "bearer" {
+		return fmt.Errorf("unsupported authentication challenge: %s", challenge)
+	}
+
+	params := map[string]string{}
+
+	for _, match := range challengeParamRegex.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+
+	realm, err := neturl.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("invalid authentication realm: %s", params["realm"])
+	}
+
+	query := realm.Query()
+
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "creating token request")
+	}
+
+	if c.options.Username != "" {
+		req.SetBasicAuth(c.options.Username, c.options.Password)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "requesting token")
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("requesting token: unexpected response: %s", res.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&token)
+	if err != nil {
+		return errors.Wrap(err, "decoding token")
+	}
+
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+
+	c.tokensMutex.Lock()
+	c.tokens[scope] = fmt.Sprintf("Bearer %s", token.Token)
+	c.tokensMutex.Unlock()
+
+	return nil
+}
+
+func responseError(res *http.Response) error {
+	defer res.Body.Close()
+
+	var body struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	bodyBytes, _ := ioutil.ReadAll(io.LimitReader(res.Body, 64*1024))
+
+	if json.Unmarshal(bodyBytes, &body) == nil && len(body.Errors) > 0 {
+		return fmt.Errorf("unexpected response: %s: %s: %s", res.Status, body.Errors[0].Code, body.Errors[0].Message)
+	}
+
+	return fmt.Errorf("unexpected response: %s", res.Status)
+}
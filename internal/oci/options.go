@@ -0,0 +1,35 @@
+package oci
+
+import (
+	"github.com/dpb587/metalink-repository-resource/internal/option"
+)
+
+// Options are shared by the oci URL handler and repository source.
+type Options struct {
+	Username  string
+	Password  string
+	PlainHTTP bool
+}
+
+// ParseOptions parses the options of a handler or source configuration.
+func ParseOptions(options map[string]interface{}) (Options, error) {
+	var opts Options
+	var err error
+
+	opts.Username, err = option.String(options, "username")
+	if err != nil {
+		return Options{}, err
+	}
+
+	opts.Password, err = option.String(options, "password")
+	if err != nil {
+		return Options{}, err
+	}
+
+	opts.PlainHTTP, err = option.Bool(options, "plain_http")
+	if err != nil {
+		return Options{}, err
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,70 @@
+// Package option parses the untyped options of source and URL handler
+// configurations.
+package option
+
+import (
+	"fmt"
+)
+
+// String returns the string option of key, or an empty string if it is unset.
+func String(options map[string]interface{}, key string) (string, error) {
+	val, ok := options[key]
+	if !ok {
+		return "", nil
+	}
+
+	valStr, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("option %s: expected string", key)
+	}
+
+	return valStr, nil
+}
+
+// Bool returns the boolean option of key, or false if it is unset.
+func Bool(options map[string]interface{}, key string) (bool, error) {
+	val, ok := options[key]
+	if !ok {
+		return false, nil
+	}
+
+	switch typed := val.(type) {
+	case bool:
+		return typed, nil
+	case string:
+		// options given on the command line are strings
+		if typed == "true" {
+			return true, nil
+		} else if typed == "false" {
+			return false, nil
+		}
+	}
+
+	return false, fmt.Errorf("option %s: expected boolean", key)
+}
+
+// Strings returns the list of strings option of key, or nil if it is unset.
+func Strings(options map[string]interface{}, key string) ([]string, error) {
+	val, ok := options[key]
+	if !ok {
+		return nil, nil
+	}
+
+	valSlice, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("option %s: expected list of strings", key)
+	}
+
+	var result []string
+
+	for _, item := range valSlice {
+		itemStr, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("option %s: expected list of strings", key)
+		}
+
+		result = append(result, itemStr)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package presign
+
+import (
+	"fmt"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink/file"
+	"github.com/dpb587/metalink/file/url"
+	"github.com/pkg/errors"
+)
+
+// RefreshWindow is how long before their expiration presigned URLs are
+// refreshed, allowing for downloads to start.
+const RefreshWindow = 5 * time.Minute
+
+// Presigner is implemented by references which may be shared as time-limited
+// URLs.
+type Presigner interface {
+	PresignedURL(expiry time.Duration) (string, error)
+}
+
+// Find returns the presigner of a reference, or of the references it wraps.
+func Find(ref file.Reference) (Presigner, bool) {
+	for ref != nil {
+		if presigner, ok := ref.(Presigner); ok {
+			return presigner, true
+		}
+
+		unwrapper, ok := ref.(interface{ Unwrap() file.Reference })
+		if !ok {
+			break
+		}
+
+		ref = unwrapper.Unwrap()
+	}
+
+	return nil, false
+}
+
+// Parse returns the URL without its query, and when it expires, for URLs
+// presigned with AWS Signature Version 4.
+func Parse(uri string) (string, time.Time, time.Duration, bool) {
+	parsed, err := neturl.Parse(uri)
+	if err != nil {
+		return "", time.Time{}, 0, false
+	}
+
+	query := parsed.Query()
+	if query.Get("X-Amz-Signature") == "" {
+		return "", time.Time{}, 0, false
+	}
+
+	signed, err := time.Parse("20060102T150405Z", query.Get("X-Amz-Date"))
+	if err != nil {
+		return "", time.Time{}, 0, false
+	}
+
+	seconds, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil {
+		return "", time.Time{}, 0, false
+	}
+
+	expiry := time.Duration(seconds) * time.Second
+
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+
+	return parsed.String(), signed.Add(expiry), expiry, true
+}
+
+// IsPresigned returns whether a URL is presigned with AWS Signature Version 4.
+func IsPresigned(uri string) bool {
+	_, _, _, ok := Parse(uri)
+
+	return ok
+}
+
+// Refresh replaces presigned URLs of a metalink which expire within the
+// RefreshWindow with new URLs of the same expiry. URLs which no handler of the
+// loader can presign are left unchanged.
+func Refresh(urlLoader url.Loader, meta4 metalink.Metalink, now time.Time) (metalink.Metalink, error) {
+	for fileIdx, file := range meta4.Files {
+		for urlIdx, fileURL := range file.URLs {
+			unsigned, expires, expiry, ok := Parse(fileURL.URL)
+			if !ok || expires.After(now.Add(RefreshWindow)) {
+				continue
+			}
+
+			unsignedURL := metalink.URL{URL: unsigned}
+
+			var presigner Presigner
+
+			if urlLoader.SupportsURL(unsignedURL) {
+				ref, err := urlLoader.LoadURL(unsignedURL)
+				if err != nil {
+					return meta4, errors.Wrapf(err, "loading %s", unsigned)
+				}
+
+				presigner, _ = Find(ref)
+			}
+
+			if presigner == nil {
+				fmt.Fprintf(os.Stderr, "presigned URL cannot be refreshed: %s\n", api.Redact(unsigned))
+
+				continue
+			}
+
+			presigned, err := presigner.PresignedURL(expiry)
+			if err != nil {
+				return meta4, errors.Wrapf(err, "presigning %s", unsigned)
+			}
+
+			meta4.Files[fileIdx].URLs[urlIdx].URL = presigned
+		}
+	}
+
+	return meta4, nil
+}
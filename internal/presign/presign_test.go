@@ -0,0 +1,128 @@
+package presign_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/internal/presign"
+	"github.com/dpb587/metalink/file"
+	"github.com/dpb587/metalink/file/filefakes"
+	"github.com/dpb587/metalink/file/url/urlfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type presigningReference struct {
+	*filefakes.FakeReference
+
+	presignedURL string
+	err          error
+	expiries     []time.Duration
+}
+
+func (r *presigningReference) PresignedURL(expiry time.Duration) (string, error) {
+	r.expiries = append(r.expiries, expiry)
+
+	return r.presignedURL, r.err
+}
+
+type wrappingReference struct {
+	*filefakes.FakeReference
+
+	wrapped file.Reference
+}
+
+func (r wrappingReference) Unwrap() file.Reference {
+	return r.wrapped
+}
+
+var _ = Describe("Refresh", func() {
+	var now time.Time
+	var loader *urlfakes.FakeLoader
+	var ref *presigningReference
+
+	presignedURL := func(signed time.Time, expiry time.Duration) string {
+		return fmt.Sprintf("https://bucket.s3.amazonaws.com/file.tgz?X-Amz-Date=%s&X-Amz-Expires=%d&X-Amz-Signature=abc", signed.UTC().Format("20060102T150405Z"), int(expiry.Seconds()))
+	}
+
+	meta4 := func(urls ...string) metalink.Metalink {
+		file := metalink.File{Name: "file.tgz"}
+
+		for _, url := range urls {
+			file.URLs = append(file.URLs, metalink.URL{URL: url})
+		}
+
+		return metalink.Metalink{Files: []metalink.File{file}}
+	}
+
+	BeforeEach(func() {
+		now = time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)
+		loader = &urlfakes.FakeLoader{}
+		ref = &presigningReference{
+			FakeReference: &filefakes.FakeReference{},
+			presignedURL:  "https://bucket.s3.amazonaws.com/file.tgz?X-Amz-Signature=refreshed",
+		}
+
+		loader.SupportsURLReturns(true)
+		loader.LoadURLReturns(ref, nil)
+	})
+
+	It("refreshes URLs expiring within the window with the same expiry", func() {
+		refreshed, err := presign.Refresh(loader, meta4(presignedURL(now.Add(-time.Hour+time.Minute), time.Hour)), now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refreshed.Files[0].URLs[0].URL).To(Equal("https://bucket.s3.amazonaws.com/file.tgz?X-Amz-Signature=refreshed"))
+
+		Expect(loader.LoadURLCallCount()).To(Equal(1))
+		Expect(loader.LoadURLArgsForCall(0)).To(Equal(metalink.URL{URL: "https://bucket.s3.amazonaws.com/file.tgz"}))
+		Expect(ref.expiries).To(Equal([]time.Duration{time.Hour}))
+	})
+
+	It("refreshes expired URLs", func() {
+		refreshed, err := presign.Refresh(loader, meta4(presignedURL(now.Add(-48*time.Hour), time.Hour)), now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refreshed.Files[0].URLs[0].URL).To(ContainSubstring("refreshed"))
+	})
+
+	It("keeps URLs expiring after the window", func() {
+		original := presignedURL(now, presign.RefreshWindow+time.Minute)
+
+		refreshed, err := presign.Refresh(loader, meta4(original, "https://example.com/file.tgz"), now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refreshed.Files[0].URLs[0].URL).To(Equal(original))
+		Expect(refreshed.Files[0].URLs[1].URL).To(Equal("https://example.com/file.tgz"))
+		Expect(loader.LoadURLCallCount()).To(Equal(0))
+	})
+
+	It("refreshes through wrapped references", func() {
+		loader.LoadURLReturns(wrappingReference{FakeReference: &filefakes.FakeReference{}, wrapped: ref}, nil)
+
+		refreshed, err := presign.Refresh(loader, meta4(presignedURL(now.Add(-time.Hour), time.Hour)), now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refreshed.Files[0].URLs[0].URL).To(ContainSubstring("refreshed"))
+	})
+
+	It("keeps URLs which cannot be presigned", func() {
+		original := presignedURL(now.Add(-time.Hour), time.Hour)
+
+		loader.LoadURLReturns(&filefakes.FakeReference{}, nil)
+
+		refreshed, err := presign.Refresh(loader, meta4(original), now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refreshed.Files[0].URLs[0].URL).To(Equal(original))
+
+		loader.SupportsURLReturns(false)
+
+		refreshed, err = presign.Refresh(loader, meta4(original), now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refreshed.Files[0].URLs[0].URL).To(Equal(original))
+	})
+
+	It("fails when presigning fails", func() {
+		ref.err = errors.New("fake-err")
+
+		_, err := presign.Refresh(loader, meta4(presignedURL(now.Add(-time.Hour), time.Hour)), now)
+		Expect(err).To(MatchError(ContainSubstring("fake-err")))
+	})
+})
@@ -0,0 +1,264 @@
+package publish
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/cheggaaa/pb"
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/api"
+	"github.com/dpb587/metalink-repository-resource/internal/presign"
+	"github.com/dpb587/metalink/file"
+	"github.com/dpb587/metalink/file/url"
+	"github.com/dpb587/metalink/repository/source"
+	metalinktemplate "github.com/dpb587/metalink/template"
+	"github.com/dpb587/metalink/verification"
+	"github.com/dpb587/metalink/verification/hash"
+	"github.com/pkg/errors"
+)
+
+const DefaultMetalinkName = "v{{ .Version }}.meta4"
+
+// RenderMetalinkName executes a metalink name template for a version.
+func RenderMetalinkName(name, version string) (string, error) {
+	tmpl, err := template.New("metalink").Parse(name)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing metalink name")
+	}
+
+	buf := &bytes.Buffer{}
+
+	err = tmpl.Execute(buf, map[string]string{
+		"Version": version,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// CreateMetalink builds a metalink from local files matching the globs. The
+// returned map references the local file of each metalink file by name.
+func CreateMetalink(urlLoader url.Loader, version string, files []string) (metalink.Metalink, map[string]string, error) {
+	now := time.Now()
+	meta4 := metalink.Metalink{
+		Generator: "metalink-repository-resource/0.0.0",
+		Published: &now,
+	}
+	localCache := map[string]string{}
+
+	for _, paramFile := range files {
+		filePaths, err := filepath.Glob(paramFile)
+		if err != nil {
+			return meta4, nil, errors.Wrap(err, "globbing path")
+		}
+
+		for _, filePath := range filePaths {
+			filepathAbs, err := filepath.Abs(filePath)
+			if err != nil {
+				return meta4, nil, errors.Wrap(err, "finding absolute path")
+			}
+
+			file := metalink.File{
+				Name:    path.Base(filePath),
+				Version: version,
+				Hashes:  []metalink.Hash{},
+			}
+
+			localCache[file.Name] = fmt.Sprintf("file://%s", filepathAbs)
+
+			local, err := urlLoader.LoadURL(metalink.URL{URL: localCache[file.Name]})
+			if err != nil {
+				return meta4, nil, errors.Wrap(err, "loading local file")
+			}
+
+			file.Size, err = local.Size()
+			if err != nil {
+				return meta4, nil, errors.Wrap(err, "getting size")
+			}
+
+			hashmap := []verification.Signer{
+				hash.SHA512SignerVerifier,
+				hash.SHA256SignerVerifier,
+				hash.SHA1SignerVerifier,
+				hash.MD5SignerVerifier,
+			}
+
+			for _, hasher := range hashmap {
+				verification, err := hasher.Sign(local)
+				if err != nil {
+					return meta4, nil, errors.Wrap(err, "building hash")
+				}
+
+				err = verification.Apply(&file)
+				if err != nil {
+					return meta4, nil, errors.Wrap(err, "adding hash")
+				}
+			}
+
+			meta4.Files = append(meta4.Files, file)
+		}
+	}
+
+	return meta4, localCache, nil
+}
+
+// MirrorMetalink uploads the files of a metalink to each mirror destination and
+// adds the resulting URLs to the metalink. Destinations which a file already
+// references are skipped.
+func MirrorMetalink(urlLoader url.Loader, mirrorFiles []api.MirrorFileParams, meta4 metalink.Metalink, localCache map[string]string) (metalink.Metalink, error) {
+	for fileIdx, file := range meta4.Files {
+		// TODO support multiple URLs
+		localURI, isLocal := localCache[file.Name]
+		if !isLocal {
+			if len(file.URLs) < 1 {
+				return meta4, errors.New("file is missing url")
+			}
+
+			localURI = file.URLs[0].URL
+		}
+
+		local, err := urlLoader.LoadURL(metalink.URL{URL: localURI})
+		if err != nil {
+			return meta4, errors.Wrap(err, "loading local file")
+		}
+
+		for _, uploadParams := range mirrorFiles {
+			remoteURLTmpl, err := metalinktemplate.New(uploadParams.Destination)
+			if err != nil {
+				return meta4, errors.Wrap(err, "parsing upload destination")
+			}
+
+			remoteURL, err := remoteURLTmpl.ExecuteString(file)
+			if err != nil {
+				return meta4, errors.Wrap(err, "generating upload destination")
+			}
+
+			remote, err := urlLoader.LoadURL(metalink.URL{URL: remoteURL})
+			if err != nil {
+				return meta4, errors.Wrap(err, "loading upload destination")
+			}
+
+			// already mirrored (e.g. when resuming a previous remirror); the object
+			// itself is not checked
+			if hasURL(meta4.Files[fileIdx], remote.ReaderURI()) {
+				fmt.Fprintf(os.Stderr, "skipping upload to %s (already referenced by the metalink)\n", api.Redact(remoteURL))
+
+				continue
+			}
+
+			var uri string
+			var uploadError error
+
+			for retry := 1; retry <= 3; retry++ {
+				uploadError = nil
+
+				if retry > 1 {
+					fmt.Fprintf(os.Stderr, "\nretrying (attempt #%d)...\n", retry)
+				}
+
+				fmt.Fprintf(os.Stderr, "uploading to %s\n", api.Redact(remoteURL))
+
+				remote, err := urlLoader.LoadURL(metalink.URL{URL: remoteURL})
+				if err != nil {
+					return meta4, errors.Wrap(err, "loading upload destination")
+				}
+
+				uri = remote.ReaderURI()
+
+				progress := pb.New64(int64(file.Size)).Set(pb.Bytes, true).SetRefreshRate(time.Second).SetWidth(80)
+				progress.Start()
+
+				err = remote.WriteFrom(local, progress)
+				progress.Finish()
+
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "uploading failed: %s\n", api.Redact(err.Error()))
+
+					uploadError = err
+
+					continue
+				}
+
+				break
+			}
+
+			if uploadError != nil {
+				return meta4, errors.Wrap(uploadError, "uploading")
+			}
+
+			uris := []string{uri}
+
+			if uploadParams.Presign != nil {
+				presigned, err := presignURL(remote, *uploadParams.Presign)
+				if err != nil {
+					return meta4, errors.Wrap(err, "presigning upload destination")
+				}
+
+				if uploadParams.Presign.Replace {
+					uris = nil
+				}
+
+				uris = append(uris, presigned)
+			}
+
+			for _, uri := range uris {
+				meta4.Files[fileIdx].URLs = append(
+					meta4.Files[fileIdx].URLs,
+					metalink.URL{
+						Location: uploadParams.Location,
+						Priority: uploadParams.Priority,
+						URL:      uri,
+					},
+				)
+			}
+		}
+	}
+
+	return meta4, nil
+}
+
+// PullRequestURL returns the URL of the pull request opened by the last Put
+// of a source which publishes through pull requests.
+func PullRequestURL(repo source.Source) string {
+	if prs, ok := repo.(interface{ PullRequestURL() string }); ok {
+		return prs.PullRequestURL()
+	}
+
+	return ""
+}
+
+// presignURL returns a presigned URL of the reference, which is limited to the
+// maximum expiry of S3 (seven days) by default.
+func presignURL(ref file.Reference, params api.PresignParams) (string, error) {
+	presigner, ok := presign.Find(ref)
+	if !ok {
+		return "", fmt.Errorf("presigning is not supported: %s", ref.ReaderURI())
+	}
+
+	expiry := 7 * 24 * time.Hour
+	if params.Expiry != nil {
+		expiry = params.Expiry.Duration
+	}
+
+	return presigner.PresignedURL(expiry)
+}
+
+func hasURL(file metalink.File, uri string) bool {
+	for _, url := range file.URLs {
+		if url.URL == uri {
+			return true
+		} else if unsigned, _, _, ok := presign.Parse(url.URL); ok && unsigned == uri {
+			// replaced by a presigned URL
+			return true
+		}
+	}
+
+	return false
+}
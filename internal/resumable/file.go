@@ -0,0 +1,85 @@
+package resumable
+
+import (
+	"io"
+	"os"
+
+	"github.com/cheggaaa/pb"
+	"github.com/dpb587/metalink/file"
+	fileurl "github.com/dpb587/metalink/file/url/file"
+	"github.com/pkg/errors"
+)
+
+// FileReference is a local file which, when resumable, continues a previous,
+// partial write if the remote supports reading from an offset.
+type FileReference struct {
+	fileurl.Reference
+
+	path   string
+	resume bool
+}
+
+var _ file.Reference = FileReference{}
+
+// NewFileReference returns a file which is always overwritten.
+func NewFileReference(path string) FileReference {
+	return FileReference{
+		Reference: fileurl.NewReference(path),
+		path:      path,
+	}
+}
+
+// NewResumableFileReference returns a file which is only ever written by
+// downloads of the same content (e.g. partial downloads of a cache).
+func NewResumableFileReference(path string) FileReference {
+	return FileReference{
+		Reference: fileurl.NewReference(path),
+		path:      path,
+		resume:    true,
+	}
+}
+
+func (o FileReference) WriteFrom(from file.Reference, progress *pb.ProgressBar) error {
+	var offset uint64
+
+	if !o.resume {
+		// an existing file may be stale or unrelated
+	} else if stat, err := os.Stat(o.path); err == nil && stat.Mode().IsRegular() {
+		offset = uint64(stat.Size())
+	}
+
+	var reader io.ReadCloser
+	var resumed bool
+	var err error
+
+	if rangeFrom, ok := from.(RangeReader); ok && offset > 0 {
+		reader, resumed, err = rangeFrom.ReaderFrom(offset)
+	} else {
+		reader, err = from.Reader()
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "Opening from")
+	}
+
+	defer reader.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+
+	if resumed {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+
+		progress.Add64(int64(offset))
+	}
+
+	writer, err := os.OpenFile(o.path, flags, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Opening file for writing")
+	}
+
+	defer writer.Close()
+
+	_, err = io.Copy(writer, progress.NewProxyReader(reader))
+
+	return err
+}
@@ -0,0 +1,90 @@
+package resumable
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink/file"
+	"github.com/dpb587/metalink/file/url"
+	httpurl "github.com/dpb587/metalink/file/url/http"
+	"github.com/pkg/errors"
+)
+
+type httpLoader struct {
+	client *http.Client
+}
+
+var _ url.Loader = &httpLoader{}
+
+func NewHTTPLoader(client *http.Client) url.Loader {
+	return &httpLoader{
+		client: client,
+	}
+}
+
+func (l httpLoader) SupportsURL(source metalink.URL) bool {
+	parsed, err := neturl.Parse(source.URL)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Scheme == "http" || parsed.Scheme == "https"
+}
+
+func (l httpLoader) LoadURL(source metalink.URL) (file.Reference, error) {
+	return httpReference{
+		Reference: httpurl.NewReference(l.client, source.URL),
+		client:    l.client,
+		url:       source.URL,
+	}, nil
+}
+
+type httpReference struct {
+	httpurl.Reference
+
+	client *http.Client
+	url    string
+}
+
+var _ RangeReader = httpReference{}
+
+func (o httpReference) ReaderFrom(offset uint64) (io.ReadCloser, bool, error) {
+	if offset == 0 {
+		reader, err := o.Reader()
+
+		return reader, false, err
+	}
+
+	request, err := http.NewRequest("GET", o.url, nil)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "Creating request")
+	}
+
+	request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+
+	response, err := o.client.Do(request)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "Loading URL")
+	}
+
+	switch response.StatusCode {
+	case http.StatusPartialContent:
+		return response.Body, true, nil
+	case http.StatusOK:
+		return response.Body, false, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		response.Body.Close()
+
+		// the partial content is not usable; start over
+		reader, err := o.Reader()
+
+		return reader, false, err
+	}
+
+	response.Body.Close()
+
+	return nil, false, fmt.Errorf("Unexpected response code: %d", response.StatusCode)
+}
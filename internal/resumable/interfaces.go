@@ -0,0 +1,12 @@
+package resumable
+
+import (
+	"io"
+)
+
+// RangeReader is implemented by references which are able to start reading
+// from an offset. The returned bool is false when the remote ignored the
+// offset and the reader starts from the beginning.
+type RangeReader interface {
+	ReaderFrom(offset uint64) (io.ReadCloser, bool, error)
+}
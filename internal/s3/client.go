@@ -0,0 +1,123 @@
+package s3
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	neturl "net/url"
+	"regexp"
+	"strings"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+// http://docs.aws.amazon.com/general/latest/gr/rande.html#s3_region
+var endpointRegex = regexp.MustCompile(`^s3(\.(dualstack\.)?|\-)[^\.]+\.amazonaws.com$`)
+
+// IsEndpoint returns whether host is a regional endpoint of AWS S3.
+func IsEndpoint(host string) bool {
+	return endpointRegex.MatchString(host)
+}
+
+// Location is a bucket and key (or prefix) of s3://endpoint/bucket/key URIs.
+type Location struct {
+	Host   string
+	Bucket string
+	Key    string
+}
+
+// ParseLocation parses s3://endpoint/bucket/key URIs.
+func ParseLocation(parsed *neturl.URL) (Location, error) {
+	split := strings.SplitN(strings.TrimPrefix(parsed.Path, "/"), "/", 2)
+	if len(split) != 2 || split[0] == "" {
+		return Location{}, fmt.Errorf("invalid s3 bucket/object path: %s", parsed.Path)
+	}
+
+	return Location{
+		Host:   parsed.Host,
+		Bucket: split[0],
+		Key:    split[1],
+	}, nil
+}
+
+// ReaderURI returns the HTTP(S) URL of an object.
+func ReaderURI(location Location, options Options, key string) string {
+	base := fmt.Sprintf("https://%s", location.Host)
+	if options.Endpoint != "" {
+		base = strings.TrimSuffix(options.Endpoint, "/")
+	}
+
+	return fmt.Sprintf("%s/%s/%s", base, location.Bucket, key)
+}
+
+// NewClient creates a client for the endpoint of a location, or the endpoint
+// of the options when configured.
+func NewClient(location Location, options Options) (*minio.Client, error) {
+	endpoint, port, secure := location.Host, "", true
+
+	if options.Endpoint != "" {
+		parsed, err := neturl.Parse(options.Endpoint)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing endpoint")
+		}
+
+		endpoint, secure = parsed.Host, parsed.Scheme != "http"
+	} else {
+		if parsed, err := neturl.Parse("//" + location.Host); err == nil {
+			endpoint, port = parsed.Hostname(), parsed.Port()
+		}
+
+		if IsEndpoint(endpoint) {
+			endpoint = "s3.amazonaws.com"
+		}
+
+		if port != "" && port != "443" {
+			endpoint = fmt.Sprintf("%s:%s", endpoint, port)
+		}
+	}
+
+	creds, err := newCredentials(endpoint, options)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := minio.DefaultTransport(secure)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating transport")
+	}
+
+	if secure && (options.CACert != "" || options.InsecureSkipVerify) {
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: options.InsecureSkipVerify,
+		}
+
+		if options.CACert != "" {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(options.CACert)) {
+				return nil, errors.New("parsing ca_cert: no certificates found")
+			}
+
+			transport.TLSClientConfig.RootCAs = pool
+		}
+	}
+
+	bucketLookup := minio.BucketLookupAuto
+	if options.PathStyle {
+		bucketLookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        creds,
+		Secure:       secure,
+		Transport:    transport,
+		Region:       options.Region,
+		BucketLookup: bucketLookup,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating s3 client")
+	}
+
+	return client, nil
+}
@@ -0,0 +1,186 @@
+package s3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"github.com/pkg/errors"
+)
+
+const defaultSTSEndpoint = "https://sts.amazonaws.com"
+
+// newCredentials returns static credentials or those of the AWS credential
+// chain, optionally used to assume a role.
+func newCredentials(endpoint string, options Options) (*credentials.Credentials, error) {
+	var creds *credentials.Credentials
+
+	if options.Profile != "" {
+		// unlike the chain, a missing profile must not fall back to other
+		// credentials
+		creds = credentials.New(&profileCredentials{
+			FileAWSCredentials: credentials.FileAWSCredentials{Profile: options.Profile},
+		})
+	} else if options.CredentialChain {
+		iam := &credentials.IAM{
+			Client: &http.Client{Transport: http.DefaultTransport},
+		}
+
+		if os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "" {
+			// otherwise the endpoint would be used for instance metadata
+			iam.Endpoint = options.STSEndpoint
+		}
+
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			iam,
+		})
+	} else {
+		creds = credentials.NewStaticV4(options.AccessKey, options.SecretKey, options.SessionToken)
+	}
+
+	if options.RoleARN == "" {
+		return creds, nil
+	}
+
+	stsEndpoint := options.STSEndpoint
+	if stsEndpoint == "" {
+		if endpoint != "s3.amazonaws.com" {
+			return nil, errors.New("role arn is only supported for s3 endpoints (or with sts_endpoint)")
+		}
+
+		stsEndpoint = defaultSTSEndpoint
+	}
+
+	sessionName := options.SessionName
+	if sessionName == "" {
+		sessionName = "metalink-session"
+	}
+
+	region := options.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return credentials.New(&assumeRole{
+		client:      http.DefaultClient,
+		endpoint:    stsEndpoint,
+		region:      region,
+		source:      creds,
+		roleARN:     options.RoleARN,
+		externalID:  options.ExternalID,
+		sessionName: sessionName,
+	}), nil
+}
+
+// profileCredentials retrieves the keys of a profile of the shared credentials
+// file, which must exist.
+type profileCredentials struct {
+	credentials.FileAWSCredentials
+}
+
+var _ credentials.Provider = &profileCredentials{}
+
+func (p *profileCredentials) Retrieve() (credentials.Value, error) {
+	value, err := p.FileAWSCredentials.Retrieve()
+	if err != nil {
+		return credentials.Value{}, errors.Wrapf(err, "loading profile %s", p.Profile)
+	} else if value.AccessKeyID == "" {
+		// e.g. role_arn with source_profile, which is not supported
+		return credentials.Value{}, fmt.Errorf("loading profile %s: no aws_access_key_id or credential_process", p.Profile)
+	}
+
+	return value, nil
+}
+
+// assumeRole retrieves temporary credentials of a role using the credentials
+// of source, which may be temporary themselves (unlike STSAssumeRole).
+type assumeRole struct {
+	credentials.Expiry
+
+	client      *http.Client
+	endpoint    string
+	region      string
+	source      *credentials.Credentials
+	roleARN     string
+	externalID  string
+	sessionName string
+}
+
+var _ credentials.Provider = &assumeRole{}
+
+func (a *assumeRole) Retrieve() (credentials.Value, error) {
+	source, err := a.source.Get()
+	if err != nil {
+		return credentials.Value{}, errors.Wrap(err, "getting source credentials")
+	} else if source.AccessKeyID == "" {
+		return credentials.Value{}, errors.New("assuming role: no source credentials found")
+	}
+
+	form := neturl.Values{}
+	form.Set("Action", "AssumeRole")
+	form.Set("Version", credentials.STSVersion)
+	form.Set("RoleArn", a.roleARN)
+	form.Set("RoleSessionName", a.sessionName)
+	form.Set("DurationSeconds", "3600")
+
+	if a.externalID != "" {
+		form.Set("ExternalId", a.externalID)
+	}
+
+	body := form.Encode()
+	hash := sha256.Sum256([]byte(body))
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(a.endpoint, "/")+"/", strings.NewReader(body))
+	if err != nil {
+		return credentials.Value{}, errors.Wrap(err, "creating request")
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(hash[:]))
+
+	if source.SessionToken != "" {
+		// signed along with the other headers
+		req.Header.Set("X-Amz-Security-Token", source.SessionToken)
+	}
+
+	res, err := a.client.Do(signer.SignV4STS(*req, source.AccessKeyID, source.SecretAccessKey, a.region))
+	if err != nil {
+		return credentials.Value{}, errors.Wrap(err, "assuming role")
+	}
+
+	defer res.Body.Close()
+
+	resBytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return credentials.Value{}, errors.Wrap(err, "reading response")
+	} else if res.StatusCode != http.StatusOK {
+		return credentials.Value{}, fmt.Errorf("assuming role: unexpected status %d: %s", res.StatusCode, resBytes)
+	}
+
+	var parsed credentials.AssumeRoleResponse
+
+	err = xml.Unmarshal(resBytes, &parsed)
+	if err != nil {
+		return credentials.Value{}, errors.Wrap(err, "parsing response")
+	}
+
+	result := parsed.Result.Credentials
+	a.SetExpiration(result.Expiration, credentials.DefaultExpiryWindow)
+
+	return credentials.Value{
+		AccessKeyID:     result.AccessKey,
+		SecretAccessKey: result.SecretKey,
+		SessionToken:    result.SessionToken,
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package s3
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("credentials", func() {
+	var tmpDir string
+	var previousEnv map[string]*string
+
+	setenv := func(name, value string) {
+		if _, found := previousEnv[name]; !found {
+			if previous, set := os.LookupEnv(name); set {
+				previousEnv[name] = &previous
+			} else {
+				previousEnv[name] = nil
+			}
+		}
+
+		Expect(os.Setenv(name, value)).To(Succeed())
+	}
+
+	resolve := func(options Options) (string, error) {
+		creds, err := newCredentials("s3.amazonaws.com", options)
+		Expect(err).NotTo(HaveOccurred())
+
+		value, err := creds.Get()
+
+		return value.AccessKeyID, err
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		tmpDir, err = ioutil.TempDir("", "metalink-repository-resource-s3")
+		Expect(err).NotTo(HaveOccurred())
+
+		previousEnv = map[string]*string{}
+
+		credentialsFile := filepath.Join(tmpDir, "credentials")
+		Expect(ioutil.WriteFile(credentialsFile, []byte(`[default]
+aws_access_key_id = default-access-key
+aws_secret_access_key = default-secret-key
+
+[releases]
+aws_access_key_id = releases-access-key
+aws_secret_access_key = releases-secret-key
+
+[assumed]
+role_arn = arn:aws:iam::123456789012:role/releases
+source_profile = releases
+`), 0600)).To(Succeed())
+
+		setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+		setenv("AWS_PROFILE", "")
+		setenv("AWS_ACCESS_KEY_ID", "env-access-key")
+		setenv("AWS_SECRET_ACCESS_KEY", "env-secret-key")
+		setenv("AWS_SESSION_TOKEN", "")
+	})
+
+	AfterEach(func() {
+		for name, value := range previousEnv {
+			if value == nil {
+				Expect(os.Unsetenv(name)).To(Succeed())
+			} else {
+				Expect(os.Setenv(name, *value)).To(Succeed())
+			}
+		}
+
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("uses static keys by default", func() {
+		Expect(resolve(Options{AccessKey: "static-access-key", SecretKey: "static-secret-key"})).To(Equal("static-access-key"))
+	})
+
+	Context("with the credential chain", func() {
+		It("prefers the environment", func() {
+			Expect(resolve(Options{CredentialChain: true})).To(Equal("env-access-key"))
+		})
+
+		It("falls back to the default profile", func() {
+			setenv("AWS_ACCESS_KEY_ID", "")
+			setenv("AWS_SECRET_ACCESS_KEY", "")
+
+			Expect(resolve(Options{CredentialChain: true})).To(Equal("default-access-key"))
+		})
+
+		It("uses the profile of the environment", func() {
+			setenv("AWS_ACCESS_KEY_ID", "")
+			setenv("AWS_SECRET_ACCESS_KEY", "")
+			setenv("AWS_PROFILE", "releases")
+
+			Expect(resolve(Options{CredentialChain: true})).To(Equal("releases-access-key"))
+		})
+	})
+
+	Context("with a profile", func() {
+		It("takes precedence over the environment", func() {
+			Expect(resolve(Options{Profile: "releases"})).To(Equal("releases-access-key"))
+			Expect(resolve(Options{Profile: "releases", CredentialChain: true})).To(Equal("releases-access-key"))
+		})
+
+		It("fails when the profile does not exist", func() {
+			_, err := resolve(Options{Profile: "missing"})
+			Expect(err).To(MatchError(ContainSubstring("loading profile missing")))
+		})
+
+		It("fails for profiles without keys", func() {
+			_, err := resolve(Options{Profile: "assumed"})
+			Expect(err).To(MatchError(ContainSubstring("loading profile assumed: no aws_access_key_id")))
+		})
+	})
+})
@@ -0,0 +1,144 @@
+package s3
+
+import (
+	"fmt"
+	neturl "net/url"
+
+	"github.com/dpb587/metalink-repository-resource/internal/option"
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// Options are shared by the s3 URL handler, repository source and storage.
+type Options struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	RoleARN      string
+	ExternalID   string
+	SessionName  string
+	STSEndpoint  string
+
+	// CredentialChain resolves credentials from the environment, shared
+	// credentials file (of Profile), web identity tokens, or instance metadata.
+	CredentialChain bool
+	Profile         string
+
+	// Endpoint overrides the host of URIs (e.g. https://minio.example.com:9000
+	// or http://127.0.0.1:9000).
+	Endpoint           string
+	Region             string
+	PathStyle          bool
+	InsecureSkipVerify bool
+	CACert             string
+
+	// SSE is the server-side encryption of written objects (AES256 or
+	// aws:kms).
+	SSE          string
+	KMSKeyID     string
+	StorageClass string
+	ACL          string
+	Tags         map[string]string
+	CacheControl string
+}
+
+// ParseOptions parses the options of a handler or source configuration.
+func ParseOptions(options map[string]interface{}) (Options, error) {
+	var opts Options
+	var err error
+
+	for key, dest := range map[string]*string{
+		"access_key":    &opts.AccessKey,
+		"secret_key":    &opts.SecretKey,
+		"session_token": &opts.SessionToken,
+		"role_arn":      &opts.RoleARN,
+		"external_id":   &opts.ExternalID,
+		"session_name":  &opts.SessionName,
+		"sts_endpoint":  &opts.STSEndpoint,
+		"profile":       &opts.Profile,
+		"endpoint":      &opts.Endpoint,
+		"region":        &opts.Region,
+		"ca_cert":       &opts.CACert,
+		"sse":           &opts.SSE,
+		"kms_key_id":    &opts.KMSKeyID,
+		"storage_class": &opts.StorageClass,
+		"acl":           &opts.ACL,
+		"cache_control": &opts.CacheControl,
+	} {
+		*dest, err = option.String(options, key)
+		if err != nil {
+			return Options{}, err
+		}
+	}
+
+	for key, dest := range map[string]*bool{
+		"credential_chain":     &opts.CredentialChain,
+		"path_style":           &opts.PathStyle,
+		"insecure_skip_verify": &opts.InsecureSkipVerify,
+	} {
+		*dest, err = option.Bool(options, key)
+		if err != nil {
+			return Options{}, err
+		}
+	}
+
+	if val, ok := options["tags"]; ok {
+		valMap, ok := val.(map[string]interface{})
+		if !ok {
+			return Options{}, fmt.Errorf("option tags: expected map")
+		}
+
+		opts.Tags = map[string]string{}
+
+		for k, v := range valMap {
+			opts.Tags[k] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	switch opts.SSE {
+	case "", "AES256", "aws:kms":
+	default:
+		return Options{}, fmt.Errorf("option sse: expected AES256 or aws:kms: %s", opts.SSE)
+	}
+
+	if opts.Endpoint != "" {
+		endpoint, err := neturl.Parse(opts.Endpoint)
+		if err != nil || endpoint.Host == "" || endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+			return Options{}, fmt.Errorf("option endpoint: expected http(s)://HOST[:PORT]: %s", opts.Endpoint)
+		}
+	}
+
+	if (opts.CredentialChain || opts.Profile != "") && (opts.AccessKey != "" || opts.SecretKey != "" || opts.SessionToken != "") {
+		return Options{}, fmt.Errorf("option credential_chain: cannot be combined with access_key, secret_key or session_token")
+	}
+
+	if opts.KMSKeyID != "" && opts.SSE == "AES256" {
+		return Options{}, fmt.Errorf("option kms_key_id: requires sse of aws:kms")
+	}
+
+	return opts, nil
+}
+
+// PutObjectOptions returns the options for writing objects.
+func (o Options) PutObjectOptions() minio.PutObjectOptions {
+	opts := minio.PutObjectOptions{
+		ContentType:  "application/octet-stream",
+		StorageClass: o.StorageClass,
+		CacheControl: o.CacheControl,
+		UserTags:     o.Tags,
+	}
+
+	if o.ACL != "" {
+		// canned ACLs are passed through as amz headers
+		opts.UserMetadata = map[string]string{"x-amz-acl": o.ACL}
+	}
+
+	if o.SSE == "aws:kms" || o.KMSKeyID != "" {
+		// without an encryption context, this cannot fail
+		opts.ServerSideEncryption, _ = encrypt.NewSSEKMS(o.KMSKeyID, nil)
+	} else if o.SSE == "AES256" {
+		opts.ServerSideEncryption = encrypt.NewSSE()
+	}
+
+	return opts
+}
@@ -0,0 +1,242 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink/repository"
+	"github.com/pkg/errors"
+)
+
+type Options struct {
+	// Title is used for the index page and feed.
+	Title string
+
+	// BaseURL is the public location of the site, used for absolute links in
+	// the feed. When empty, the repository URI is used for feed identifiers.
+	BaseURL string
+
+	// RepositoryURI identifies the repository the site was rendered from.
+	RepositoryURI string
+
+	// Now is used for feed timestamps of metalinks without a published date.
+	Now time.Time
+}
+
+type Index struct {
+	Repository string         `json:"repository"`
+	Generated  time.Time      `json:"generated"`
+	Versions   []IndexVersion `json:"versions"`
+}
+
+type IndexVersion struct {
+	Version   string      `json:"version"`
+	Path      string      `json:"path"`
+	Page      string      `json:"page"`
+	Published *time.Time  `json:"published,omitempty"`
+	Files     []IndexFile `json:"files"`
+}
+
+type IndexFile struct {
+	Name   string            `json:"name"`
+	Size   uint64            `json:"size"`
+	Hashes map[string]string `json:"hashes"`
+	URLs   []string          `json:"urls"`
+}
+
+var unsafePageCharacters = regexp.MustCompile(`[^a-zA-Z0-9._+-]`)
+
+// Render creates the files of a static site for the metalinks, which are
+// expected to already be sorted. The result maps relative paths to content.
+func Render(metalinks []repository.RepositoryMetalink, options Options) (map[string][]byte, error) {
+	index := Index{
+		Repository: options.RepositoryURI,
+		Generated:  options.Now.UTC(),
+		Versions:   []IndexVersion{},
+	}
+
+	for _, meta4 := range metalinks {
+		if len(meta4.Metalink.Files) == 0 {
+			continue
+		}
+
+		index.Versions = append(index.Versions, newIndexVersion(meta4))
+	}
+
+	result := map[string][]byte{}
+
+	indexJSON, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling index.json")
+	}
+
+	result["index.json"] = append(indexJSON, '\n')
+
+	indexHTML := &bytes.Buffer{}
+
+	err = indexTemplate.Execute(indexHTML, map[string]interface{}{
+		"Title": options.Title,
+		"Index": index,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "rendering index.html")
+	}
+
+	result["index.html"] = indexHTML.Bytes()
+
+	for _, version := range index.Versions {
+		versionHTML := &bytes.Buffer{}
+
+		err = versionTemplate.Execute(versionHTML, map[string]interface{}{
+			"Title":   options.Title,
+			"Version": version,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "rendering %s", version.Page)
+		}
+
+		result[version.Page] = versionHTML.Bytes()
+	}
+
+	feedXML, err := renderFeed(index, options)
+	if err != nil {
+		return nil, errors.Wrap(err, "rendering feed.atom")
+	}
+
+	result["feed.atom"] = feedXML
+
+	return result, nil
+}
+
+func newIndexVersion(meta4 repository.RepositoryMetalink) IndexVersion {
+	version := IndexVersion{
+		Version:   meta4.Metalink.Files[0].Version,
+		Path:      meta4.Reference.Path,
+		Published: meta4.Metalink.Published,
+		Files:     []IndexFile{},
+	}
+
+	version.Page = fmt.Sprintf("versions/%s.html", unsafePageCharacters.ReplaceAllString(version.Version, "_"))
+
+	for _, file := range meta4.Metalink.Files {
+		indexFile := IndexFile{
+			Name:   file.Name,
+			Size:   file.Size,
+			Hashes: map[string]string{},
+			URLs:   []string{},
+		}
+
+		for _, hash := range file.Hashes {
+			indexFile.Hashes[string(hash.Type)] = hash.Hash
+		}
+
+		for _, url := range file.URLs {
+			indexFile.URLs = append(indexFile.URLs, url.URL)
+		}
+
+		for _, metaURL := range file.MetaURLs {
+			indexFile.URLs = append(indexFile.URLs, metaURL.URL)
+		}
+
+		version.Files = append(version.Files, indexFile)
+	}
+
+	return version
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Summary string      `xml:"summary"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func renderFeed(index Index, options Options) ([]byte, error) {
+	baseURL := strings.TrimSuffix(options.BaseURL, "/")
+	idPrefix := baseURL
+	if idPrefix == "" {
+		idPrefix = options.RepositoryURI
+	}
+
+	feed := atomFeed{
+		Title:   options.Title,
+		ID:      idPrefix,
+		Updated: options.Now.UTC().Format(time.RFC3339),
+	}
+
+	if baseURL != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "self", Href: fmt.Sprintf("%s/feed.atom", baseURL)})
+		feed.Links = append(feed.Links, atomLink{Href: fmt.Sprintf("%s/index.html", baseURL)})
+	}
+
+	for _, version := range index.Versions {
+		updated := options.Now
+
+		if version.Published != nil {
+			updated = *version.Published
+		}
+
+		entry := atomEntry{
+			Title:   version.Version,
+			ID:      fmt.Sprintf("%s#%s", idPrefix, version.Version),
+			Updated: updated.UTC().Format(time.RFC3339),
+			Summary: fmt.Sprintf("%d file(s)", len(version.Files)),
+		}
+
+		if baseURL != "" {
+			entry.Links = append(entry.Links, atomLink{Href: fmt.Sprintf("%s/%s", baseURL, version.Page)})
+		}
+
+		var content []string
+
+		for _, file := range version.Files {
+			content = append(content, fmt.Sprintf("%s (%d bytes)", file.Name, file.Size))
+		}
+
+		entry.Content = atomContent{Type: "text", Body: strings.Join(content, "\n")}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	feedXML, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), append(feedXML, '\n')...), nil
+}
+
+// hashTypes orders hashes from strongest to weakest for display.
+var hashTypes = []metalink.HashType{
+	metalink.HashTypeSHA512,
+	metalink.HashTypeSHA256,
+	metalink.HashTypeSHA1,
+	metalink.HashTypeMD5,
+}
@@ -0,0 +1,79 @@
+package site
+
+import (
+	"html/template"
+	"strings"
+)
+
+var templateFuncs = template.FuncMap{
+	// browsers only follow some schemes; others (e.g. s3) are displayed as text
+	"linkable": func(uri string) bool {
+		for _, prefix := range []string{"http://", "https://", "ftp://"} {
+			if strings.HasPrefix(uri, prefix) {
+				return true
+			}
+		}
+
+		return false
+	},
+	"hashTypes": func() []string {
+		var types []string
+
+		for _, hashType := range hashTypes {
+			types = append(types, string(hashType))
+		}
+
+		return types
+	},
+}
+
+var indexTemplate = template.Must(template.New("index").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<link rel="alternate" type="application/atom+xml" href="feed.atom">
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+<ul>
+{{- range .Index.Versions }}
+<li><a href="{{ .Page }}">{{ .Version }}</a>{{ if .Published }} ({{ .Published.UTC.Format "2006-01-02" }}){{ end }}</li>
+{{- end }}
+</ul>
+<p><a href="index.json">index.json</a> &middot; <a href="feed.atom">feed.atom</a></p>
+</body>
+</html>
+`))
+
+var versionTemplate = template.Must(template.New("version").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }} {{ .Version.Version }}</title>
+</head>
+<body>
+<h1>{{ .Title }} {{ .Version.Version }}</h1>
+<p><a href="../index.html">All versions</a></p>
+{{- range .Version.Files }}
+{{- $file := . }}
+<h2>{{ .Name }}</h2>
+<dl>
+<dt>Size</dt>
+<dd>{{ .Size }} bytes</dd>
+{{- range $hashType := hashTypes }}
+{{- with index $file.Hashes $hashType }}
+<dt>{{ $hashType }}</dt>
+<dd><code>{{ . }}</code></dd>
+{{- end }}
+{{- end }}
+</dl>
+<ul>
+{{- range .URLs }}
+<li>{{ if linkable . }}<a href="{{ . }}">{{ . }}</a>{{ else }}<code>{{ . }}</code>{{ end }}</li>
+{{- end }}
+</ul>
+{{- end }}
+</body>
+</html>
+`))
@@ -0,0 +1,331 @@
+package git
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dpb587/metalink-repository-resource/internal/forge"
+	"github.com/dpb587/metalink/repository/source"
+	"github.com/dpb587/metalink/repository/utility"
+
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/pkg/errors"
+)
+
+var schemes = map[string]string{
+	"git":       "git",
+	"git+file":  "file",
+	"git+http":  "http",
+	"git+https": "https",
+	"git+ssh":   "ssh",
+}
+
+type Factory struct {
+	fs         boshsys.FileSystem
+	cmdRunner  boshsys.CmdRunner
+	httpClient *http.Client
+}
+
+var _ source.Factory = &Factory{}
+
+func NewFactory(fs boshsys.FileSystem, cmdRunner boshsys.CmdRunner, httpClient *http.Client) Factory {
+	return Factory{
+		fs:         fs,
+		cmdRunner:  cmdRunner,
+		httpClient: httpClient,
+	}
+}
+
+func (f Factory) Schemes() []string {
+	var schemeKeys = []string{}
+
+	for scheme := range schemes {
+		schemeKeys = append(schemeKeys, scheme)
+	}
+
+	return schemeKeys
+}
+
+func (f Factory) Create(uri string, options map[string]interface{}) (source.Source, error) {
+	parsedURI, err := utility.ParseUriOrGitCloneArg(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing source URI")
+	}
+
+	auth := ""
+
+	if parsedURI.User != nil {
+		auth = fmt.Sprintf("%s@", parsedURI.User.String())
+	}
+
+	splitpath := strings.SplitN(parsedURI.Path, "//", 2)
+	gitpath := splitpath[0]
+	fspath := ""
+
+	if len(splitpath) == 2 {
+		fspath = splitpath[1]
+	}
+
+	var privateKey *string
+	commits := sourceCommitSettings{
+		authorEmail:    "metalink-repository@localhost",
+		authorName:     "metalink-repository",
+		committerEmail: "metalink-repository@localhost",
+		committerName:  "metalink-repository",
+		message:        "update metalink",
+	}
+
+	if val, found := options["private_key"]; found {
+		privateKeyQ := val.(string)
+		privateKey = &privateKeyQ
+	}
+
+	if val, found := options["author_email"]; found {
+		commits.authorEmail = val.(string)
+	}
+
+	if val, found := options["author_name"]; found {
+		commits.authorName = val.(string)
+	}
+
+	if val, found := options["committer_email"]; found {
+		commits.committerEmail = val.(string)
+	}
+
+	if val, found := options["committer_name"]; found {
+		commits.committerName = val.(string)
+	}
+
+	if val, found := options["message"]; found {
+		commits.message = val.(string)
+	}
+
+	if val, found := options["signing_key"]; found {
+		valStr, ok := val.(string)
+		if !ok {
+			return nil, errors.New("failed to parse signing_key option: expected string")
+		}
+
+		commits.signingKey = valStr
+	}
+
+	if val, found := options["tag"]; found {
+		valStr, ok := val.(string)
+		if !ok {
+			return nil, errors.New("failed to parse tag option: expected string")
+		}
+
+		commits.tag = valStr
+	}
+
+	if val, found := options["tag_message"]; found {
+		valStr, ok := val.(string)
+		if !ok {
+			return nil, errors.New("failed to parse tag_message option: expected string")
+		}
+
+		commits.tagMessage = valStr
+	}
+
+	var trustedKeys []string
+
+	if val, found := options["trusted_signing_keys"]; found {
+		switch typed := val.(type) {
+		case string:
+			trustedKeys = append(trustedKeys, typed)
+		case []interface{}:
+			for _, key := range typed {
+				keyStr, ok := key.(string)
+				if !ok {
+					return nil, errors.New("failed to parse trusted_signing_keys option: expected list of strings")
+				}
+
+				trustedKeys = append(trustedKeys, keyStr)
+			}
+		default:
+			return nil, errors.New("failed to parse trusted_signing_keys option: expected list of strings")
+		}
+	}
+
+	commits.rebase = 3 // default to rebasing
+
+	if val, found := options["rebase"]; found {
+		valStr := fmt.Sprintf("%v", val)
+
+		b, err1 := strconv.ParseBool(valStr)
+		if err1 == nil {
+			commits.rebase = map[bool]uint64{true: commits.rebase, false: 0}[b]
+		}
+
+		u, err2 := strconv.ParseUint(valStr, 10, 8)
+		if err2 == nil {
+			commits.rebase = u
+		}
+
+		if err1 != nil && err2 != nil {
+			return nil, fmt.Errorf("failed to parse rebase option: %s", val)
+		}
+	}
+
+	var clones sourceCloneSettings
+
+	if val, found := options["depth"]; found {
+		depth, err := strconv.ParseUint(fmt.Sprintf("%v", val), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse depth option: %s", val)
+		}
+
+		clones.depth = int(depth)
+	}
+
+	if val, found := options["sparse_checkout"]; found {
+		sparse, err := strconv.ParseBool(fmt.Sprintf("%v", val))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sparse_checkout option: %s", val)
+		}
+
+		clones.sparse = sparse
+	}
+
+	if val, found := options["cache_dir"]; found {
+		valStr, ok := val.(string)
+		if !ok {
+			return nil, errors.New("failed to parse cache_dir option: expected string")
+		}
+
+		clones.cacheDir = valStr
+	}
+
+	if clones.depth > 0 && len(trustedKeys) > 0 {
+		// the history of the shallow boundary commit cannot be verified
+		return nil, errors.New("depth option cannot be combined with trusted_signing_keys")
+	}
+
+	var pullRequest *sourcePullRequestSettings
+
+	if val, found := options["pull_request"]; found {
+		pullRequest, err = f.createPullRequestSettings(val, gitpath, commits.message)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse pull_request option")
+		} else if commits.tag != "" {
+			// the pull request may be squashed or rebased, so its commit is not
+			// necessarily the one which is merged
+			return nil, errors.New("tag option cannot be combined with pull_request")
+		}
+	}
+
+	var transport sourceTransportSettings
+
+	if val, found := options["username"]; found {
+		valStr, ok := val.(string)
+		if !ok {
+			return nil, errors.New("failed to parse username option: expected string")
+		}
+
+		transport.username = valStr
+	}
+
+	if val, found := options["token"]; found {
+		valStr, ok := val.(string)
+		if !ok {
+			return nil, errors.New("failed to parse token option: expected string")
+		}
+
+		transport.token = valStr
+	}
+
+	if val, found := options["credential_helper"]; found {
+		valStr, ok := val.(string)
+		if !ok {
+			return nil, errors.New("failed to parse credential_helper option: expected string")
+		}
+
+		transport.credentialHelper = valStr
+	}
+
+	if val, found := options["ca_cert"]; found {
+		valStr, ok := val.(string)
+		if !ok {
+			return nil, errors.New("failed to parse ca_cert option: expected string")
+		}
+
+		transport.caCert = valStr
+	}
+
+	if val, found := options["insecure_skip_verify"]; found {
+		insecureSkipVerify, err := strconv.ParseBool(fmt.Sprintf("%v", val))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse insecure_skip_verify option: %s", val)
+		}
+
+		transport.insecureSkipVerify = insecureSkipVerify
+	}
+
+	var cloneUrl string
+	if parsedURI.Scheme == "git+ssh" {
+		cloneUrl = fmt.Sprintf("%s%s:%s", auth, parsedURI.Host, gitpath)
+	} else {
+		cloneUrl = fmt.Sprintf("%s://%s%s%s", schemes[parsedURI.Scheme], auth, parsedURI.Host, gitpath)
+	}
+
+	return NewSource(uri, cloneUrl, parsedURI.Fragment, fspath, privateKey, commits, trustedKeys, clones, pullRequest, transport, f.fs, f.cmdRunner), nil
+}
+
+func (f Factory) createPullRequestSettings(val interface{}, gitpath, message string) (*sourcePullRequestSettings, error) {
+	options, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("expected hash")
+	}
+
+	stringOptions := map[string]string{}
+
+	for _, key := range []string{"forge", "api_url", "token", "repository", "branch", "title", "body"} {
+		if val, found := options[key]; found {
+			valStr, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: expected string", key)
+			}
+
+			stringOptions[key] = valStr
+		}
+	}
+
+	if stringOptions["branch"] == "" {
+		return nil, errors.New("branch: required")
+	}
+
+	settings := &sourcePullRequestSettings{
+		repository: stringOptions["repository"],
+		branch:     stringOptions["branch"],
+		title:      stringOptions["title"],
+		body:       stringOptions["body"],
+	}
+
+	if settings.repository == "" {
+		settings.repository = strings.TrimSuffix(strings.Trim(gitpath, "/"), ".git")
+	}
+
+	if settings.title == "" {
+		settings.title = message
+	}
+
+	if val, found := options["auto_merge"]; found {
+		autoMerge, err := strconv.ParseBool(fmt.Sprintf("%v", val))
+		if err != nil {
+			return nil, fmt.Errorf("auto_merge: expected boolean: %v", val)
+		}
+
+		settings.autoMerge = autoMerge
+	}
+
+	var err error
+
+	settings.forge, err = forge.New(f.httpClient, stringOptions["forge"], stringOptions["api_url"], stringOptions["token"])
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
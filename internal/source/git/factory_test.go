@@ -0,0 +1,47 @@
+package git
+
+import (
+	"net/http"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Factory", func() {
+	create := func(options map[string]interface{}) error {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		_, err := NewFactory(boshsys.NewOsFileSystem(logger), boshsys.NewExecCmdRunner(logger), http.DefaultClient).Create("git+https://git.example.com/metalinks.git", options)
+
+		return err
+	}
+
+	for _, key := range []string{
+		"signing_key",
+		"tag",
+		"tag_message",
+		"cache_dir",
+		"username",
+		"token",
+		"credential_helper",
+		"ca_cert",
+	} {
+		key := key
+
+		It("fails when the "+key+" option is not a string", func() {
+			err := create(map[string]interface{}{key: 123})
+			Expect(err).To(MatchError("failed to parse " + key + " option: expected string"))
+		})
+	}
+
+	It("fails when an option of the pull request is not a string", func() {
+		err := create(map[string]interface{}{
+			"pull_request": map[string]interface{}{
+				"branch": 123,
+			},
+		})
+		Expect(err).To(MatchError("failed to parse pull_request option: branch: expected string"))
+	})
+})
@@ -0,0 +1,78 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/dpb587/metalink-repository-resource/internal/forge"
+	"github.com/pkg/errors"
+)
+
+type sourcePullRequestSettings struct {
+	forge      forge.Forge
+	repository string
+	branch     string
+	title      string
+	body       string
+	autoMerge  bool
+}
+
+// PullRequestURL returns the URL of the pull request opened by Put, if any.
+func (s Source) PullRequestURL() string {
+	return s.pullRequestURL
+}
+
+// publishPullRequest pushes the commit to a branch and opens a pull request for
+// merging it into the source branch.
+func (s *Source) publishPullRequest(content []byte) error {
+	rendered, err := renderVersionTemplates(content, s.pullRequest.branch, s.pullRequest.title, s.pullRequest.body)
+	if err != nil {
+		return errors.Wrap(err, "Rendering pull request")
+	}
+
+	head, title, body := rendered[0], rendered[1], rendered[2]
+
+	base := s.branch
+
+	if base == "" {
+		stdout, _, exitStatus, err := s.cmdRunner.RunComplexCommand(boshsys.Command{
+			Name:       "git",
+			Args:       []string{"rev-parse", "--abbrev-ref", "HEAD"},
+			WorkingDir: s.clonedir,
+		})
+		if err != nil {
+			return errors.Wrap(err, "Finding branch")
+		} else if exitStatus != 0 {
+			return fmt.Errorf("git rev-parse exit status: %d", exitStatus)
+		}
+
+		base = strings.TrimSpace(stdout)
+	}
+
+	// the branch is generated, so stale attempts are overwritten (and their
+	// pull request reused)
+	_, _, exitStatus, err := s.runRemote(boshsys.Command{
+		Args:       []string{"push", "--force", "origin", fmt.Sprintf("HEAD:refs/heads/%s", head)},
+		WorkingDir: s.clonedir,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Pushing branch")
+	} else if exitStatus != 0 {
+		return fmt.Errorf("git push exit status: %d", exitStatus)
+	}
+
+	s.pullRequestURL, err = s.pullRequest.forge.CreatePullRequest(forge.PullRequest{
+		Repository: s.pullRequest.repository,
+		Head:       head,
+		Base:       base,
+		Title:      title,
+		Body:       body,
+		AutoMerge:  s.pullRequest.autoMerge,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Opening pull request")
+	}
+
+	return nil
+}
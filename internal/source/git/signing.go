@@ -0,0 +1,321 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/dpb587/metalink"
+	"github.com/pkg/errors"
+)
+
+const openPGPArmorPrefix = "-----BEGIN PGP "
+
+func isOpenPGPKey(key string) bool {
+	return strings.HasPrefix(strings.TrimSpace(key), openPGPArmorPrefix)
+}
+
+// renderTag renders the tag name and message templates with the version of
+// the metalink being published.
+func (s Source) renderTag(content []byte) (string, string, error) {
+	rendered, err := renderVersionTemplates(content, s.commits.tag, s.commits.tagMessage)
+	if err != nil {
+		return "", "", err
+	}
+
+	if rendered[1] == "" {
+		rendered[1] = rendered[0]
+	}
+
+	return rendered[0], rendered[1], nil
+}
+
+// renderVersionTemplates renders templates with the version of a metalink.
+func renderVersionTemplates(content []byte, texts ...string) ([]string, error) {
+	var meta4 metalink.Metalink
+
+	err := metalink.Unmarshal(content, &meta4)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unmarshaling metalink")
+	} else if len(meta4.Files) == 0 {
+		return nil, errors.New("Metalink has no files")
+	}
+
+	data := struct{ Version string }{Version: meta4.Files[0].Version}
+
+	var rendered []string
+
+	for _, text := range texts {
+		tmpl, err := template.New("template").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, errors.Wrap(err, "Parsing template")
+		}
+
+		buf := &bytes.Buffer{}
+
+		err = tmpl.Execute(buf, data)
+		if err != nil {
+			return nil, errors.Wrap(err, "Executing template")
+		}
+
+		rendered = append(rendered, buf.String())
+	}
+
+	return rendered, nil
+}
+
+// signingConfig returns the git arguments and environment for signing commits
+// and tags with the configured OpenPGP or SSH key, which is written to dir.
+func (s Source) signingConfig(dir string) ([]string, map[string]string, error) {
+	keyPath := filepath.Join(dir, "key")
+
+	err := s.writeKey(keyPath, s.commits.signingKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !isOpenPGPKey(s.commits.signingKey) {
+		return []string{
+			"-c", "gpg.format=ssh",
+			"-c", fmt.Sprintf("user.signingkey=%s", keyPath),
+			"-c", "commit.gpgsign=true",
+			"-c", "tag.gpgsign=true",
+		}, nil, nil
+	}
+
+	env, err := s.importOpenPGPKeys(filepath.Join(dir, "gnupg"), []string{keyPath})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stdout, _, exitStatus, err := s.cmdRunner.RunComplexCommand(boshsys.Command{
+		Name: "gpg",
+		Args: []string{"--batch", "--with-colons", "--list-secret-keys"},
+		Env:  env,
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Listing signing key")
+	} else if exitStatus != 0 {
+		return nil, nil, fmt.Errorf("gpg list-secret-keys exit status: %d", exitStatus)
+	}
+
+	var fingerprint string
+
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) > 9 && fields[0] == "fpr" {
+			fingerprint = fields[9]
+
+			break
+		}
+	}
+
+	if fingerprint == "" {
+		return nil, nil, errors.New("Signing key is not an OpenPGP secret key")
+	}
+
+	return []string{
+		"-c", "gpg.format=openpgp",
+		"-c", fmt.Sprintf("user.signingkey=%s", fingerprint),
+		"-c", "commit.gpgsign=true",
+		"-c", "tag.gpgsign=true",
+	}, env, nil
+}
+
+// createTag tags HEAD unless the remote already has the tag, which is never
+// moved. Local tags of previous, unpushed attempts are replaced.
+func (s Source) createTag(tag, message string, signingArgs []string, env map[string]string) error {
+	ref := fmt.Sprintf("refs/tags/%s", tag)
+
+	stdout, _, exitStatus, err := s.runRemote(boshsys.Command{
+		Args:       []string{"ls-remote", "--tags", s.uri, ref},
+		WorkingDir: s.clonedir,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Checking remote tag")
+	} else if exitStatus != 0 {
+		return fmt.Errorf("git ls-remote exit status: %d", exitStatus)
+	} else if strings.TrimSpace(stdout) != "" {
+		return fmt.Errorf("Tag %s already exists", tag)
+	}
+
+	stdout, _, exitStatus, err = s.cmdRunner.RunComplexCommand(boshsys.Command{
+		Name:       "git",
+		Args:       []string{"tag", "--list", tag},
+		WorkingDir: s.clonedir,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Listing tags")
+	} else if exitStatus != 0 {
+		return fmt.Errorf("git tag exit status: %d", exitStatus)
+	}
+
+	if strings.TrimSpace(stdout) != "" {
+		_, _, exitStatus, err = s.cmdRunner.RunComplexCommand(boshsys.Command{
+			Name:       "git",
+			Args:       []string{"tag", "--delete", tag},
+			WorkingDir: s.clonedir,
+		})
+		if err != nil {
+			return errors.Wrap(err, "Deleting unpushed tag")
+		} else if exitStatus != 0 {
+			return fmt.Errorf("git tag exit status: %d", exitStatus)
+		}
+	}
+
+	_, _, exitStatus, err = s.cmdRunner.RunComplexCommand(boshsys.Command{
+		Name: "git",
+		Args: append(append([]string{}, signingArgs...),
+			"tag",
+			"--annotate",
+			"--message",
+			message,
+			tag,
+			"HEAD",
+		),
+		WorkingDir: s.clonedir,
+		Env:        env,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Creating tag")
+	} else if exitStatus != 0 {
+		return fmt.Errorf("git tag exit status: %d", exitStatus)
+	}
+
+	return nil
+}
+
+// signatureVerifier requires the most recent commit of a file to be signed by
+// one of the trusted keys.
+type signatureVerifier struct {
+	source Source
+	args   []string
+	env    map[string]string
+}
+
+// newSignatureVerifier writes the trusted keys to dir.
+func (s Source) newSignatureVerifier(dir string) (*signatureVerifier, error) {
+	var err error
+
+	verifier := &signatureVerifier{source: s}
+
+	var openPGPKeyPaths []string
+	var allowedSigners []string
+
+	for keyIdx, key := range s.trustedKeys {
+		if isOpenPGPKey(key) {
+			keyPath := filepath.Join(dir, fmt.Sprintf("key%d.asc", keyIdx))
+
+			err = s.writeKey(keyPath, key)
+			if err != nil {
+				return nil, err
+			}
+
+			openPGPKeyPaths = append(openPGPKeyPaths, keyPath)
+
+			continue
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(key), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				// any principal may sign with a trusted key
+				allowedSigners = append(allowedSigners, fmt.Sprintf(`* namespaces="git" %s`, line))
+			}
+		}
+	}
+
+	// both verifiers are always isolated from the user's keyring and
+	// configuration, even without any of their keys
+	verifier.env, err = s.importOpenPGPKeys(filepath.Join(dir, "gnupg"), openPGPKeyPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	allowedSignersPath := filepath.Join(dir, "allowed_signers")
+
+	err = s.fs.WriteFileString(allowedSignersPath, strings.Join(append(allowedSigners, ""), "\n"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Writing allowed signers")
+	}
+
+	verifier.args = []string{"-c", fmt.Sprintf("gpg.ssh.allowedSignersFile=%s", allowedSignersPath)}
+
+	return verifier, nil
+}
+
+func (v signatureVerifier) Verify(path string) error {
+	stdout, _, exitStatus, err := v.source.cmdRunner.RunComplexCommand(boshsys.Command{
+		Name:       "git",
+		Args:       []string{"log", "-1", "--format=%H", "--", path},
+		WorkingDir: v.source.clonedir,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Finding commit")
+	} else if exitStatus != 0 {
+		return fmt.Errorf("git log exit status: %d", exitStatus)
+	}
+
+	commit := strings.TrimSpace(stdout)
+	if commit == "" {
+		return errors.New("Metalink is not committed")
+	}
+
+	_, stderr, exitStatus, err := v.source.cmdRunner.RunComplexCommand(boshsys.Command{
+		Name:       "git",
+		Args:       append(append([]string{}, v.args...), "verify-commit", commit),
+		WorkingDir: v.source.clonedir,
+		Env:        v.env,
+		Quiet:      true,
+	})
+	if exitStatus > 0 {
+		// the command runner also reports non-zero exits as errors
+		return fmt.Errorf("Commit %s is not signed by a trusted key: %s", commit, strings.TrimSpace(stderr))
+	} else if err != nil {
+		return errors.Wrap(err, "Verifying commit")
+	}
+
+	return nil
+}
+
+func (s Source) importOpenPGPKeys(gnupghome string, keyPaths []string) (map[string]string, error) {
+	err := s.fs.MkdirAll(gnupghome, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "Creating keyring")
+	}
+
+	env := map[string]string{"GNUPGHOME": gnupghome}
+
+	if len(keyPaths) == 0 {
+		return env, nil
+	}
+
+	_, _, exitStatus, err := s.cmdRunner.RunComplexCommand(boshsys.Command{
+		Name: "gpg",
+		Args: append([]string{"--batch", "--import"}, keyPaths...),
+		Env:  env,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Importing keys")
+	} else if exitStatus != 0 {
+		return nil, fmt.Errorf("gpg import exit status: %d", exitStatus)
+	}
+
+	return env, nil
+}
+
+func (s Source) writeKey(path, key string) error {
+	err := s.fs.WriteFileString(path, strings.TrimSpace(key)+"\n")
+	if err != nil {
+		return errors.Wrap(err, "Writing key")
+	}
+
+	err = s.fs.Chmod(path, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Securing key")
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	pkgtesting "github.com/dpb587/metalink-repository-resource/internal/testing"
+	"github.com/dpb587/metalink/repository/source"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("signature verification", func() {
+	var remote *pkgtesting.GitRemote
+	var tmpDir, sshKeyPath, sshPublicKey string
+
+	metalinkFiles := map[string]string{
+		"metalinks/v1.0.0.meta4": `<metalink xmlns="urn:ietf:params:xml:ns:metalink"><file name="fake-file"><version>1.0.0</version><size>1</size><url>https://example.com/fake-file</url></file></metalink>`,
+	}
+
+	createSource := func(options map[string]interface{}) (source.Source, error) {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		return NewFactory(boshsys.NewOsFileSystem(logger), boshsys.NewExecCmdRunner(logger), http.DefaultClient).Create(remote.URI("metalinks"), options)
+	}
+
+	commitWithSSHKey := func() {
+		Expect(remote.Commit(metalinkFiles, "add 1.0.0", "-c", "gpg.format=ssh", "-c", "user.signingkey="+sshKeyPath, "-c", "commit.gpgsign=true")).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		remote, err = pkgtesting.NewGitRemote("master")
+		Expect(err).NotTo(HaveOccurred())
+
+		tmpDir, err = ioutil.TempDir("", "metalink-repository-resource-signing")
+		Expect(err).NotTo(HaveOccurred())
+
+		sshKeyPath, sshPublicKey, err = pkgtesting.GenerateSSHKey(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(remote.Close()).To(Succeed())
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	It("accepts metalinks last committed with trusted keys", func() {
+		commitWithSSHKey()
+
+		src, err := createSource(map[string]interface{}{"trusted_signing_keys": []interface{}{sshPublicKey}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(src.Load()).To(Succeed())
+	})
+
+	It("rejects metalinks last committed without a signature", func() {
+		commitWithSSHKey()
+		Expect(remote.Commit(map[string]string{"metalinks/v1.0.0.meta4": metalinkFiles["metalinks/v1.0.0.meta4"] + "\n"}, "tamper with 1.0.0")).To(Succeed())
+
+		src, err := createSource(map[string]interface{}{"trusted_signing_keys": []interface{}{sshPublicKey}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(src.Load()).To(MatchError(ContainSubstring("not signed by a trusted key")))
+	})
+
+	Context("with keys of the user's configuration", func() {
+		var previousConfig string
+		var hadConfig bool
+
+		BeforeEach(func() {
+			allowedSigners := filepath.Join(tmpDir, "allowed_signers")
+			Expect(ioutil.WriteFile(allowedSigners, []byte(fmt.Sprintf("* %s\n", sshPublicKey)), 0600)).To(Succeed())
+
+			globalConfig := filepath.Join(tmpDir, "gitconfig")
+			Expect(ioutil.WriteFile(globalConfig, []byte(fmt.Sprintf("[gpg \"ssh\"]\n\tallowedSignersFile = %s\n", allowedSigners)), 0600)).To(Succeed())
+
+			previousConfig, hadConfig = os.LookupEnv("GIT_CONFIG_GLOBAL")
+			Expect(os.Setenv("GIT_CONFIG_GLOBAL", globalConfig)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			if hadConfig {
+				Expect(os.Setenv("GIT_CONFIG_GLOBAL", previousConfig)).To(Succeed())
+			} else {
+				Expect(os.Unsetenv("GIT_CONFIG_GLOBAL")).To(Succeed())
+			}
+		})
+
+		It("only trusts the configured keys", func() {
+			commitWithSSHKey()
+
+			_, openPGPPublicKey, err := pkgtesting.GenerateOpenPGPKey(filepath.Join(tmpDir, "gnupg"))
+			Expect(err).NotTo(HaveOccurred())
+
+			src, err := createSource(map[string]interface{}{"trusted_signing_keys": []interface{}{openPGPPublicKey}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(src.Load()).To(MatchError(ContainSubstring("not signed by a trusted key")))
+		})
+	})
+
+	It("refuses shallow clones", func() {
+		_, err := createSource(map[string]interface{}{
+			"trusted_signing_keys": []interface{}{sshPublicKey},
+			"depth":                "1",
+		})
+		Expect(err).To(MatchError("depth option cannot be combined with trusted_signing_keys"))
+	})
+})
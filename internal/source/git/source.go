@@ -0,0 +1,594 @@
+package git
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink/repository"
+	"github.com/dpb587/metalink/repository/filter"
+	"github.com/dpb587/metalink/repository/source"
+	"github.com/pkg/errors"
+)
+
+type Source struct {
+	rawURI      string
+	uri         string
+	branch      string
+	path        string
+	privateKey  *string
+	commits     sourceCommitSettings
+	trustedKeys []string
+	clones      sourceCloneSettings
+	pullRequest *sourcePullRequestSettings
+	transport   sourceTransportSettings
+	fs          boshsys.FileSystem
+	cmdRunner   boshsys.CmdRunner
+
+	pathGlobs      []string
+	pullRequestURL string
+
+	clonedir string
+
+	metalinks []repository.RepositoryMetalink
+}
+
+type sourceCommitSettings struct {
+	committerName  string
+	committerEmail string
+	authorName     string
+	authorEmail    string
+	message        string
+	rebase         uint64
+	signingKey     string
+	tag            string
+	tagMessage     string
+}
+
+type sourceCloneSettings struct {
+	depth    int
+	sparse   bool
+	cacheDir string
+}
+
+var _ source.Source = &Source{}
+
+func NewSource(rawURI string, uri string, branch string, path string, privateKey *string, commits sourceCommitSettings, trustedKeys []string, clones sourceCloneSettings, pullRequest *sourcePullRequestSettings, transport sourceTransportSettings, fs boshsys.FileSystem, cmdRunner boshsys.CmdRunner) *Source {
+	return &Source{
+		rawURI:      rawURI,
+		uri:         uri,
+		branch:      branch,
+		path:        path,
+		privateKey:  privateKey,
+		commits:     commits,
+		trustedKeys: trustedKeys,
+		clones:      clones,
+		pullRequest: pullRequest,
+		transport:   transport,
+		fs:          fs,
+		cmdRunner:   cmdRunner,
+	}
+}
+
+func (s *Source) Load() error {
+	err := s.requireClone()
+	if err != nil {
+		return errors.Wrap(err, "Cloning repository")
+	}
+
+	var verifier *signatureVerifier
+
+	if len(s.trustedKeys) > 0 {
+		keysDir, err := s.fs.TempDir("metalink-git-trusted")
+		if err != nil {
+			return errors.Wrap(err, "Creating key directory")
+		}
+
+		defer s.fs.RemoveAll(keysDir)
+
+		verifier, err = s.newSignatureVerifier(keysDir)
+		if err != nil {
+			return errors.Wrap(err, "Preparing signature verification")
+		}
+	}
+
+	localdir := filepath.Join(s.clonedir, s.path)
+
+	legacyPaths, err := filepath.Glob(localdir)
+	if err != nil {
+		return errors.Wrap(err, "Globbing path")
+	}
+
+	for _, legacyPath := range legacyPaths {
+		var files []string
+
+		err = s.fs.Walk(legacyPath, func(p string, _ os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			} else if path.Ext(p) != ".meta4" {
+				return nil
+			}
+
+			files = append(files, p)
+
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "Walking path")
+		}
+
+		uri := s.URI()
+		s.metalinks = []repository.RepositoryMetalink{}
+
+		for _, file := range files {
+			if verifier != nil {
+				err = verifier.Verify(file)
+				if err != nil {
+					return errors.Wrapf(err, "Verifying metalink %s", strings.TrimPrefix(strings.TrimPrefix(file, legacyPath), "/"))
+				}
+			}
+
+			metalinkBytes, err := s.fs.ReadFile(file)
+			if err != nil {
+				return errors.Wrap(err, "Reading metalink")
+			}
+
+			repometa4 := repository.RepositoryMetalink{
+				Reference: repository.RepositoryMetalinkReference{
+					Repository: uri,
+					Path:       strings.TrimPrefix(strings.TrimPrefix(file, legacyPath), "/"),
+				},
+			}
+
+			err = metalink.Unmarshal(metalinkBytes, &repometa4.Metalink)
+			if err != nil {
+				return errors.Wrap(err, "Unmarshaling")
+			}
+
+			s.metalinks = append(s.metalinks, repometa4)
+		}
+	}
+
+	return nil
+}
+
+// LimitPaths avoids checking out metalinks which cannot match the globs (as
+// used by repositorypath filters) when sparse checkouts are enabled. It must
+// be called before Load.
+func (s *Source) LimitPaths(globs []string) {
+	s.pathGlobs = globs
+}
+
+func (s Source) URI() string {
+	return s.rawURI
+}
+
+func (s Source) Filter(f filter.Filter) ([]repository.RepositoryMetalink, error) {
+	return source.FilterInMemory(s.metalinks, f)
+}
+
+func (s *Source) Put(name string, data io.Reader) error {
+	err := s.requireClone()
+	if err != nil {
+		return errors.Wrap(err, "Cloning repository")
+	}
+
+	filepath := path.Join(s.path, name)
+
+	content, err := ioutil.ReadAll(data)
+	if err != nil {
+		return errors.Wrap(err, "Reading metalink")
+	}
+
+	var tag, tagMessage string
+
+	if s.commits.tag != "" {
+		tag, tagMessage, err = s.renderTag(content)
+		if err != nil {
+			return errors.Wrap(err, "Rendering tag")
+		}
+	}
+
+	var signingArgs []string
+	var signingEnv map[string]string
+
+	if s.commits.signingKey != "" {
+		keysDir, err := s.fs.TempDir("metalink-git-signing")
+		if err != nil {
+			return errors.Wrap(err, "Creating key directory")
+		}
+
+		defer s.fs.RemoveAll(keysDir)
+
+		signingArgs, signingEnv, err = s.signingConfig(keysDir)
+		if err != nil {
+			return errors.Wrap(err, "Preparing signing key")
+		}
+	}
+
+	err = s.fs.WriteFile(path.Join(s.clonedir, filepath), content)
+	if err != nil {
+		return errors.Wrap(err, "Writing metalink")
+	}
+
+	addArgs := []string{"add"}
+
+	if s.clones.sparse {
+		// the path may be outside of the sparse checkout
+		addArgs = append(addArgs, "--sparse")
+	}
+
+	_, _, exitStatus, err := s.cmdRunner.RunComplexCommand(boshsys.Command{
+		Name:       "git",
+		Args:       append(addArgs, filepath),
+		WorkingDir: s.clonedir,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Staging metalink")
+	} else if exitStatus != 0 {
+		return fmt.Errorf("git add exit status: %d", exitStatus)
+	}
+
+	commitEnv := map[string]string{
+		"GIT_AUTHOR_EMAIL":    s.commits.authorEmail,
+		"GIT_AUTHOR_NAME":     s.commits.authorName,
+		"GIT_COMMITTER_EMAIL": s.commits.committerEmail,
+		"GIT_COMMITTER_NAME":  s.commits.committerName,
+	}
+
+	for k, v := range signingEnv {
+		commitEnv[k] = v
+	}
+
+	_, _, exitStatus, err = s.cmdRunner.RunComplexCommand(boshsys.Command{
+		Name: "git",
+		Args: append(append([]string{}, signingArgs...),
+			"commit",
+			"-m",
+			s.commits.message,
+			filepath,
+		),
+		WorkingDir: s.clonedir,
+		Env:        commitEnv,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Creating commit")
+	} else if exitStatus != 0 {
+		return fmt.Errorf("git commit exit status: %d", exitStatus)
+	}
+
+	if tag != "" {
+		err = s.createTag(tag, tagMessage, signingArgs, commitEnv)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.pullRequest != nil {
+		return s.publishPullRequest(content)
+	}
+
+	pushArgs := []string{"push"}
+
+	if tag != "" {
+		// the tag must never be published without its commit (or vice versa)
+		pushArgs = []string{"push", "--atomic", "origin", "HEAD", fmt.Sprintf("refs/tags/%s", tag)}
+	}
+
+	attempts := s.commits.rebase
+
+	var finalError error
+
+	for true {
+		_, _, exitStatus, err = s.runRemote(boshsys.Command{
+			Args:       pushArgs,
+			WorkingDir: s.clonedir,
+		})
+		if err != nil {
+			err = errors.Wrap(err, "Pushing repository")
+		} else if exitStatus != 0 {
+			err = fmt.Errorf("git push exit status: %d", exitStatus)
+		} else {
+			break
+		}
+
+		if attempts <= 0 {
+			finalError = err
+
+			break
+		}
+
+		time.Sleep(5 * time.Second)
+
+		_, _, exitStatus, err = s.runRemote(boshsys.Command{
+			Args: []string{
+				"pull",
+				"--rebase",
+				s.uri,
+				s.branch,
+			},
+			WorkingDir: s.clonedir,
+			Env:        commitEnv,
+		})
+		if err != nil {
+			return errors.Wrap(err, "rebasing")
+		} else if exitStatus != 0 {
+			return fmt.Errorf("git pull exit status: %d", exitStatus)
+		}
+
+		_, _, exitStatus, err = s.cmdRunner.RunComplexCommand(boshsys.Command{
+			Name: "git",
+			Args: append(append([]string{}, signingArgs...),
+				"commit",
+				"--amend",
+				"--reset-author",
+				"--no-edit",
+			),
+			Env:        commitEnv,
+			WorkingDir: s.clonedir,
+		})
+		if err != nil {
+			return errors.Wrap(err, "rebasing")
+		} else if exitStatus != 0 {
+			return fmt.Errorf("git pull exit status: %d", exitStatus)
+		}
+
+		if tag != "" {
+			// the rebased commit replaces the tagged one
+			err = s.createTag(tag, tagMessage, signingArgs, commitEnv)
+			if err != nil {
+				return err
+			}
+		}
+
+		attempts--
+	}
+
+	return finalError
+}
+
+func (s *Source) requireClone() error {
+	if s.clonedir != "" {
+		return nil
+	}
+
+	tmpdir := s.clonedirPath()
+
+	err := s.fs.MkdirAll(tmpdir, 0700)
+	if err != nil {
+		return errors.Wrap(err, "Creating tmpdir for git")
+	}
+
+	ref := "FETCH_HEAD"
+
+	if !s.fs.FileExists(fmt.Sprintf("%s/.git", tmpdir)) {
+		ref = "HEAD"
+
+		args := []string{
+			"clone",
+			"--single-branch",
+			"--no-checkout",
+		}
+
+		if s.branch != "" {
+			args = append(args, "--branch", s.branch)
+		}
+
+		if s.clones.depth > 0 {
+			args = append(args, "--depth", strconv.Itoa(s.clones.depth))
+		}
+
+		if s.clones.sparse {
+			// blobs outside of the sparse checkout are never downloaded
+			args = append(args, "--filter=blob:none")
+		}
+
+		args = append(args, s.uri, tmpdir)
+
+		err = s.runClone("Cloning repository", "", args...)
+		if err != nil {
+			return err
+		}
+	} else {
+		// cached clones are updated incrementally and reset to the remote
+		// branch, discarding any commits which failed to be pushed
+		args := []string{"fetch"}
+
+		if s.clones.depth > 0 {
+			args = append(args, "--depth", strconv.Itoa(s.clones.depth))
+		}
+
+		args = append(args, s.uri)
+
+		if s.branch != "" {
+			args = append(args, s.branch)
+		} else {
+			args = append(args, "HEAD")
+		}
+
+		err = s.runClone("Fetching repository", tmpdir, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.clones.sparse {
+		sparsePaths := s.sparseCheckoutPaths()
+
+		if len(sparsePaths) > 0 {
+			args := append([]string{"sparse-checkout", "set", "--cone"}, sparsePaths...)
+
+			err = s.runClone("Configuring sparse checkout", tmpdir, args...)
+		} else {
+			err = s.runClone("Disabling sparse checkout", tmpdir, "sparse-checkout", "disable")
+		}
+	} else if ref == "FETCH_HEAD" {
+		// cached clones may have been sparse in earlier runs
+		err = s.disableSparseCheckout(tmpdir)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.runClone("Checking out repository", tmpdir, "reset", "--quiet", "--hard", ref)
+	if err != nil {
+		return err
+	}
+
+	s.clonedir = tmpdir
+
+	return nil
+}
+
+// clonedirPath returns the directory of the clone, which is reused by later
+// runs for the same URI.
+func (s Source) clonedirPath() string {
+	cacheDir := s.clones.cacheDir
+	if cacheDir == "" {
+		cacheDir = os.TempDir()
+	}
+
+	return fmt.Sprintf("%s/metalink-git-source-%x-1", strings.TrimSuffix(cacheDir, "/"), md5.Sum([]byte(s.rawURI)))
+}
+
+// sparseCheckoutPaths returns the directories to check out: the source path
+// limited to the static prefixes of the path globs. Nothing is returned when
+// the whole repository must be checked out.
+func (s Source) sparseCheckoutPaths() []string {
+	if !s.clones.sparse {
+		return nil
+	}
+
+	globs := s.pathGlobs
+	if len(globs) == 0 {
+		globs = []string{""}
+	}
+
+	var paths []string
+
+	for _, glob := range globs {
+		prefix := glob
+
+		if idx := strings.IndexAny(prefix, `*?[\`); idx >= 0 {
+			prefix = prefix[:idx]
+		}
+
+		if idx := strings.LastIndex(prefix, "/"); idx >= 0 {
+			prefix = prefix[:idx]
+		} else {
+			prefix = ""
+		}
+
+		prefix = strings.Trim(path.Join(s.path, prefix), "/")
+		if prefix == "" || prefix == "." {
+			return nil
+		}
+
+		paths = append(paths, prefix)
+	}
+
+	return paths
+}
+
+// disableSparseCheckout checks out the whole repository if the clone was
+// configured for sparse checkouts, without requiring their support otherwise.
+func (s Source) disableSparseCheckout(workingDir string) error {
+	stdout, _, exitStatus, err := s.cmdRunner.RunComplexCommand(boshsys.Command{
+		Name:       "git",
+		Args:       []string{"config", "--get", "core.sparseCheckout"},
+		WorkingDir: workingDir,
+		Quiet:      true,
+	})
+	if exitStatus == 1 {
+		// not configured
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "Checking sparse checkout")
+	} else if strings.TrimSpace(stdout) != "true" {
+		return nil
+	}
+
+	return s.runClone("Disabling sparse checkout", workingDir, "sparse-checkout", "disable")
+}
+
+func (s Source) runClone(action string, workingDir string, args ...string) error {
+	_, _, exitStatus, err := s.runRemote(boshsys.Command{
+		Args:       args,
+		WorkingDir: workingDir,
+	})
+	if err != nil {
+		return errors.Wrap(err, action)
+	} else if exitStatus != 0 {
+		return fmt.Errorf("git %s exit status: %d", args[0], exitStatus)
+	}
+
+	return nil
+}
+
+// runRemote runs a git command which may access the remote. Credentials are
+// written to a temporary directory, outside of the (possibly cached) clone, and
+// removed once the command exits.
+func (s Source) runRemote(cmd boshsys.Command) (string, string, int, error) {
+	credentialsDir, err := s.fs.TempDir("metalink-git-credentials")
+	if err != nil {
+		return "", "", -1, errors.Wrap(err, "Creating credentials directory")
+	}
+
+	defer s.fs.RemoveAll(credentialsDir)
+
+	args, env, err := s.transportConfig(credentialsDir)
+	if err != nil {
+		return "", "", -1, errors.Wrap(err, "Configuring transport")
+	}
+
+	for k, v := range cmd.Env {
+		env[k] = v
+	}
+
+	cmd.Name = "git"
+	cmd.Args = append(args, cmd.Args...)
+	cmd.Env = env
+
+	if s.privateKey != nil {
+		keyPath := filepath.Join(credentialsDir, "key")
+
+		err = s.fs.WriteFile(keyPath, []byte(*s.privateKey))
+		if err != nil {
+			return "", "", -1, errors.Wrap(err, "Writing private key")
+		}
+
+		err = s.fs.Chmod(keyPath, 0600)
+		if err != nil {
+			return "", "", -1, errors.Wrap(err, "Securing private key")
+		}
+
+		cmd.Name = filepath.Join(credentialsDir, "git")
+
+		err = s.fs.WriteFileString(cmd.Name, fmt.Sprintf(`#!/bin/bash
+eval $(ssh-agent)
+trap "kill $SSH_AGENT_PID" 0
+set -eu
+SSH_ASKPASS=false DISPLAY= ssh-add "%s"
+git "$@"
+`, keyPath))
+		if err != nil {
+			return "", "", -1, errors.Wrap(err, "Writing git wrapper")
+		}
+
+		err = s.fs.Chmod(cmd.Name, 0755)
+		if err != nil {
+			return "", "", -1, errors.Wrap(err, "Chmod'ing git wrapper")
+		}
+	}
+
+	return s.cmdRunner.RunComplexCommand(cmd)
+}
@@ -0,0 +1,41 @@
+package git
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("sparse checkout paths", func() {
+	paths := func(sourcePath string, sparse bool, globs ...string) []string {
+		s := Source{path: sourcePath, clones: sourceCloneSettings{sparse: sparse}}
+		s.LimitPaths(globs)
+
+		return s.sparseCheckoutPaths()
+	}
+
+	It("is empty when sparse checkouts are disabled", func() {
+		Expect(paths("metalinks", false)).To(BeEmpty())
+	})
+
+	It("uses the source path without globs", func() {
+		Expect(paths("metalinks", true)).To(Equal([]string{"metalinks"}))
+	})
+
+	It("uses the directory prefix of each glob", func() {
+		Expect(paths("metalinks", true, "v1/*", "v2/stable/v2.*.meta4", "v3/[a-z]*/*")).To(Equal([]string{
+			"metalinks/v1",
+			"metalinks/v2/stable",
+			"metalinks/v3",
+		}))
+	})
+
+	It("uses the source path for globs without a directory prefix", func() {
+		Expect(paths("metalinks", true, "*.meta4")).To(Equal([]string{"metalinks"}))
+		Expect(paths("metalinks", true, "v1/*", "*.meta4")).To(Equal([]string{"metalinks/v1", "metalinks"}))
+	})
+
+	It("checks out everything at the repository root", func() {
+		Expect(paths("", true)).To(BeEmpty())
+		Expect(paths("", true, "*.meta4")).To(BeEmpty())
+	})
+})
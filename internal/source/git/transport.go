@@ -0,0 +1,68 @@
+package git
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// credentialHelper answers credential requests from the environment so that
+// secrets are neither stored in the clone nor visible in command arguments.
+const credentialHelper = `!f() { test "$1" = get || return 0; echo "username=$METALINK_GIT_USERNAME"; echo "password=$METALINK_GIT_PASSWORD"; }; f`
+
+type sourceTransportSettings struct {
+	username           string
+	token              string
+	credentialHelper   string
+	caCert             string
+	insecureSkipVerify bool
+}
+
+// transportConfig returns the git arguments and environment which configure
+// HTTP(S) authentication and certificate verification. Files are written to
+// dir.
+func (s Source) transportConfig(dir string) ([]string, map[string]string, error) {
+	env := map[string]string{}
+	var args []string
+
+	if s.transport.token != "" || s.transport.credentialHelper != "" {
+		// replace any helpers of the user's configuration
+		args = append(args, "-c", "credential.helper=")
+	}
+
+	if s.transport.token != "" {
+		username := s.transport.username
+		if username == "" {
+			username = "x-access-token"
+		}
+
+		env["METALINK_GIT_USERNAME"] = username
+		env["METALINK_GIT_PASSWORD"] = s.transport.token
+		env["GIT_TERMINAL_PROMPT"] = "0"
+
+		args = append(args, "-c", fmt.Sprintf("credential.helper=%s", credentialHelper))
+	}
+
+	if s.transport.credentialHelper != "" {
+		args = append(args, "-c", fmt.Sprintf("credential.helper=%s", s.transport.credentialHelper))
+	}
+
+	if s.transport.caCert != "" {
+		caPath := filepath.Join(dir, "ca.pem")
+
+		err := s.fs.WriteFileString(caPath, s.transport.caCert)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Writing CA certificate")
+		}
+
+		// environment variables take precedence over any http.* configuration
+		env["GIT_SSL_CAINFO"] = caPath
+	}
+
+	if s.transport.insecureSkipVerify {
+		env["GIT_SSL_NO_VERIFY"] = "true"
+	}
+
+	return args, env, nil
+}
@@ -0,0 +1,84 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/dpb587/metalink-repository-resource/internal/github"
+	"github.com/dpb587/metalink-repository-resource/internal/option"
+	"github.com/dpb587/metalink/repository/source"
+	"github.com/pkg/errors"
+)
+
+type Factory struct {
+	httpClient *http.Client
+}
+
+var _ source.Factory = &Factory{}
+
+func NewFactory(httpClient *http.Client) Factory {
+	return Factory{
+		httpClient: httpClient,
+	}
+}
+
+func (f Factory) Schemes() []string {
+	return []string{
+		"github",
+	}
+}
+
+// Create supports URIs like github://owner/repository.
+func (f Factory) Create(uri string, options map[string]interface{}) (source.Source, error) {
+	parsedURI, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing source URI")
+	}
+
+	owner := parsedURI.Host
+	repository := strings.Trim(parsedURI.Path, "/")
+	if owner == "" || repository == "" || strings.Contains(repository, "/") {
+		return nil, fmt.Errorf("expected github://OWNER/REPOSITORY: %s", uri)
+	}
+
+	token, err := option.String(options, "token")
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL, err := option.String(options, "api_url")
+	if err != nil {
+		return nil, err
+	}
+
+	sourceOptions := Options{
+		ChecksumAssets: DefaultChecksumAssets,
+	}
+
+	sourceOptions.Prereleases, err = option.Bool(options, "prereleases")
+	if err != nil {
+		return nil, err
+	}
+
+	versionRegex, err := option.String(options, "version_regex")
+	if err != nil {
+		return nil, err
+	} else if versionRegex != "" {
+		sourceOptions.VersionRegex, err = regexp.Compile(versionRegex)
+		if err != nil {
+			return nil, errors.Wrap(err, "option version_regex")
+		}
+	}
+
+	if _, ok := options["checksum_assets"]; ok {
+		sourceOptions.ChecksumAssets, err = option.Strings(options, "checksum_assets")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewSource(uri, github.NewClient(f.httpClient, apiURL, token), owner, repository, sourceOptions), nil
+}
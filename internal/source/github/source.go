@@ -0,0 +1,256 @@
+package github
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/internal/github"
+	"github.com/dpb587/metalink/repository"
+	"github.com/dpb587/metalink/repository/filter"
+	"github.com/dpb587/metalink/repository/source"
+	"github.com/pkg/errors"
+)
+
+// DefaultChecksumAssets match the companion checksum assets commonly
+// published alongside release assets.
+var DefaultChecksumAssets = []string{
+	"*SUMS",
+	"*SUMS.txt",
+	"*checksums.txt",
+	"*.sha256",
+	"*.sha256sum",
+	"*.sha512",
+	"*.sha512sum",
+}
+
+type Options struct {
+	Prereleases    bool
+	VersionRegex   *regexp.Regexp
+	ChecksumAssets []string
+}
+
+// Source is a read-only repository which synthesizes a metalink for each
+// release of a GitHub repository.
+type Source struct {
+	uri        string
+	client     *github.Client
+	owner      string
+	repository string
+	options    Options
+
+	metalinks []repository.RepositoryMetalink
+}
+
+var _ source.Source = &Source{}
+
+func NewSource(uri string, client *github.Client, owner, repository string, options Options) *Source {
+	return &Source{
+		uri:        uri,
+		client:     client,
+		owner:      owner,
+		repository: repository,
+		options:    options,
+	}
+}
+
+func (s *Source) Load() error {
+	s.metalinks = []repository.RepositoryMetalink{}
+
+	releases, err := s.client.ListReleases(s.owner, s.repository)
+	if err != nil {
+		return errors.Wrap(err, "listing releases")
+	}
+
+	for _, release := range releases {
+		if release.Draft || (release.Prerelease && !s.options.Prereleases) {
+			continue
+		}
+
+		version := strings.TrimPrefix(release.TagName, "v")
+
+		if s.options.VersionRegex != nil {
+			match := s.options.VersionRegex.FindStringSubmatch(release.TagName)
+			if match == nil {
+				continue
+			} else if len(match) > 1 {
+				version = match[1]
+			}
+		}
+
+		meta4, err := s.metalink(release, version)
+		if err != nil {
+			return errors.Wrapf(err, "release %s", release.TagName)
+		} else if len(meta4.Files) == 0 {
+			continue
+		}
+
+		s.metalinks = append(s.metalinks, repository.RepositoryMetalink{
+			Reference: repository.RepositoryMetalinkReference{
+				Repository: s.uri,
+				Path:       fmt.Sprintf("%s.meta4", release.TagName),
+			},
+			Metalink: meta4,
+		})
+	}
+
+	return nil
+}
+
+func (s Source) metalink(release github.Release, version string) (metalink.Metalink, error) {
+	meta4 := metalink.Metalink{
+		Published: release.PublishedAt,
+	}
+
+	hashes := map[string][]metalink.Hash{}
+	var assets []github.Asset
+
+	for _, asset := range release.Assets {
+		if !s.isChecksumAsset(asset.Name) {
+			assets = append(assets, asset)
+
+			continue
+		}
+
+		reader, err := s.client.DownloadAsset(asset)
+		if err != nil {
+			return meta4, errors.Wrapf(err, "downloading checksums: %s", asset.Name)
+		}
+
+		err = parseChecksums(reader, asset.Name, hashes)
+		reader.Close()
+		if err != nil {
+			return meta4, errors.Wrapf(err, "reading checksums: %s", asset.Name)
+		}
+	}
+
+	for _, asset := range assets {
+		file := metalink.File{
+			Name:    asset.Name,
+			Version: version,
+			Size:    asset.Size,
+			URLs: []metalink.URL{
+				{
+					URL: asset.BrowserDownloadURL,
+				},
+			},
+		}
+
+		if strings.HasPrefix(asset.Digest, "sha256:") {
+			file.Hashes = append(file.Hashes, metalink.Hash{
+				Type: metalink.HashTypeSHA256,
+				Hash: strings.TrimPrefix(asset.Digest, "sha256:"),
+			})
+		}
+
+		for _, hash := range hashes[asset.Name] {
+			if !hasHashType(file, hash.Type) {
+				file.Hashes = append(file.Hashes, hash)
+			}
+		}
+
+		meta4.Files = append(meta4.Files, file)
+	}
+
+	return meta4, nil
+}
+
+func (s Source) isChecksumAsset(name string) bool {
+	for _, pattern := range s.options.ChecksumAssets {
+		if match, _ := path.Match(pattern, name); match {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s Source) URI() string {
+	return s.uri
+}
+
+func (s Source) Filter(f filter.Filter) ([]repository.RepositoryMetalink, error) {
+	return source.FilterInMemory(s.metalinks, f)
+}
+
+func (s Source) Put(_ string, _ io.Reader) error {
+	return errors.New("github repositories are read-only")
+}
+
+var bsdChecksumRegex = regexp.MustCompile(`^[A-Z0-9-]+ \((.+)\) = ([a-fA-F0-9]+)$`)
+
+// parseChecksums reads GNU (`HASH  NAME`) and BSD (`SHA256 (NAME) = HASH`)
+// style checksum lines. A lone hash applies to the asset named by the checksum
+// asset without its extension (e.g. `tool.tgz.sha256`).
+func parseChecksums(reader io.Reader, checksumAssetName string, hashes map[string][]metalink.Hash) error {
+	scanner := bufio.NewScanner(reader)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var name, hash string
+
+		if match := bsdChecksumRegex.FindStringSubmatch(line); match != nil {
+			name, hash = match[1], match[2]
+		} else {
+			fields := strings.Fields(line)
+			hash = fields[0]
+
+			if len(fields) > 1 {
+				name = strings.TrimPrefix(fields[1], "*")
+			} else {
+				name = strings.TrimSuffix(checksumAssetName, path.Ext(checksumAssetName))
+			}
+		}
+
+		hashType, ok := hashTypeOf(hash)
+		if !ok {
+			continue
+		}
+
+		hashes[path.Base(name)] = append(hashes[path.Base(name)], metalink.Hash{
+			Type: hashType,
+			Hash: strings.ToLower(hash),
+		})
+	}
+
+	return scanner.Err()
+}
+
+func hashTypeOf(hash string) (metalink.HashType, bool) {
+	for _, c := range hash {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return "", false
+		}
+	}
+
+	switch len(hash) {
+	case 128:
+		return metalink.HashTypeSHA512, true
+	case 64:
+		return metalink.HashTypeSHA256, true
+	case 40:
+		return metalink.HashTypeSHA1, true
+	case 32:
+		return metalink.HashTypeMD5, true
+	}
+
+	return "", false
+}
+
+func hasHashType(file metalink.File, hashType metalink.HashType) bool {
+	for _, hash := range file.Hashes {
+		if hash.Type == hashType {
+			return true
+		}
+	}
+
+	return false
+}
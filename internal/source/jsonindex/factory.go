@@ -0,0 +1,136 @@
+package jsonindex
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dpb587/metalink-repository-resource/internal/jsonpath"
+	"github.com/dpb587/metalink/repository/source"
+	"github.com/pkg/errors"
+)
+
+type Factory struct {
+	httpClient *http.Client
+}
+
+var _ source.Factory = &Factory{}
+
+func NewFactory(httpClient *http.Client) Factory {
+	return Factory{
+		httpClient: httpClient,
+	}
+}
+
+func (f Factory) Schemes() []string {
+	return []string{
+		"jsonindex+http",
+		"jsonindex+https",
+	}
+}
+
+func (f Factory) Create(uri string, options map[string]interface{}) (source.Source, error) {
+	indexURL := strings.TrimPrefix(uri, "jsonindex+")
+
+	var selectors Selectors
+	var err error
+
+	selectors.Releases, err = selectorOption(options, "releases", "$[*]")
+	if err != nil {
+		return nil, err
+	}
+
+	selectors.Version, err = selectorOption(options, "version", "")
+	if err != nil {
+		return nil, err
+	} else if selectors.Version.IsZero() {
+		return nil, errors.New("option version: required")
+	}
+
+	selectors.Published, err = selectorOption(options, "published", "")
+	if err != nil {
+		return nil, err
+	}
+
+	selectors.Files, err = selectorOption(options, "files", "")
+	if err != nil {
+		return nil, err
+	}
+
+	selectors.FileName, err = selectorOption(options, "file_name", "")
+	if err != nil {
+		return nil, err
+	}
+
+	selectors.FileURL, err = selectorOption(options, "file_url", "")
+	if err != nil {
+		return nil, err
+	} else if selectors.FileURL.IsZero() {
+		return nil, errors.New("option file_url: required")
+	}
+
+	selectors.FileSize, err = selectorOption(options, "file_size", "")
+	if err != nil {
+		return nil, err
+	}
+
+	selectors.FileHashes = map[string]jsonpath.Selector{}
+
+	if val, ok := options["file_hashes"]; ok {
+		valMap, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("option file_hashes: expected hash of hash types and selectors")
+		}
+
+		for hashType, hashSelector := range valMap {
+			hashSelectorStr, ok := hashSelector.(string)
+			if !ok {
+				return nil, fmt.Errorf("option file_hashes: %s: expected string", hashType)
+			}
+
+			selectors.FileHashes[hashType], err = jsonpath.Parse(hashSelectorStr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "option file_hashes: %s", hashType)
+			}
+		}
+	}
+
+	if val, ok := options["version_regex"]; ok {
+		valStr, ok := val.(string)
+		if !ok {
+			return nil, errors.New("option version_regex: expected string")
+		}
+
+		selectors.VersionRegex, err = regexp.Compile(valStr)
+		if err != nil {
+			return nil, errors.Wrap(err, "option version_regex")
+		}
+	}
+
+	return NewSource(uri, f.httpClient, indexURL, selectors), nil
+}
+
+func selectorOption(options map[string]interface{}, key, defaultExpr string) (jsonpath.Selector, error) {
+	expr := defaultExpr
+
+	if val, ok := options[key]; ok {
+		valStr, ok := val.(string)
+		if !ok {
+			return jsonpath.Selector{}, fmt.Errorf("option %s: expected string", key)
+		}
+
+		expr = valStr
+	}
+
+	if expr == "" {
+		return jsonpath.Selector{}, nil
+	}
+
+	selector, err := jsonpath.Parse(expr)
+	if err != nil {
+		return selector, errors.Wrapf(err, "option %s", key)
+	}
+
+	return selector, nil
+}
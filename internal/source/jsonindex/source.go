@@ -0,0 +1,224 @@
+package jsonindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/internal/jsonpath"
+	"github.com/dpb587/metalink/repository"
+	"github.com/dpb587/metalink/repository/filter"
+	"github.com/dpb587/metalink/repository/source"
+	"github.com/pkg/errors"
+)
+
+// Selectors map the entries of a JSON index to metalinks. Release selectors
+// are relative to the document; all others are relative to a release (or, for
+// file selectors, to a file when Files is configured).
+type Selectors struct {
+	Releases     jsonpath.Selector
+	Version      jsonpath.Selector
+	VersionRegex *regexp.Regexp
+	Published    jsonpath.Selector
+
+	Files      jsonpath.Selector
+	FileName   jsonpath.Selector
+	FileURL    jsonpath.Selector
+	FileSize   jsonpath.Selector
+	FileHashes map[string]jsonpath.Selector
+}
+
+// Source is a read-only repository of metalinks synthesized from a JSON index.
+type Source struct {
+	uri        string
+	httpClient *http.Client
+	indexURL   string
+	selectors  Selectors
+
+	metalinks []repository.RepositoryMetalink
+}
+
+var _ source.Source = &Source{}
+
+func NewSource(uri string, httpClient *http.Client, indexURL string, selectors Selectors) *Source {
+	return &Source{
+		uri:        uri,
+		httpClient: httpClient,
+		indexURL:   indexURL,
+		selectors:  selectors,
+	}
+}
+
+func (s *Source) Load() error {
+	s.metalinks = []repository.RepositoryMetalink{}
+
+	baseURL, err := neturl.Parse(s.indexURL)
+	if err != nil {
+		return errors.Wrap(err, "parsing index url")
+	}
+
+	res, err := s.httpClient.Get(s.indexURL)
+	if err != nil {
+		return errors.Wrap(err, "requesting index")
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("requesting index: unexpected response: %s", res.Status)
+	}
+
+	var document interface{}
+
+	decoder := json.NewDecoder(res.Body)
+	decoder.UseNumber()
+
+	err = decoder.Decode(&document)
+	if err != nil {
+		return errors.Wrap(err, "decoding index")
+	}
+
+	for releaseIdx, release := range s.selectors.Releases.Select(document) {
+		meta4, err := s.metalink(baseURL, release)
+		if err != nil {
+			return errors.Wrapf(err, "release %d", releaseIdx)
+		} else if len(meta4.Files) == 0 {
+			continue
+		}
+
+		s.metalinks = append(s.metalinks, repository.RepositoryMetalink{
+			Reference: repository.RepositoryMetalinkReference{
+				Repository: s.uri,
+				Path:       fmt.Sprintf("v%s.meta4", meta4.Files[0].Version),
+			},
+			Metalink: meta4,
+		})
+	}
+
+	return nil
+}
+
+func (s Source) metalink(baseURL *neturl.URL, release interface{}) (metalink.Metalink, error) {
+	var meta4 metalink.Metalink
+
+	version, found := selectString(s.selectors.Version, release)
+	if !found {
+		// entries without versions (e.g. drafts) are ignored
+		return meta4, nil
+	}
+
+	if s.selectors.VersionRegex != nil {
+		match := s.selectors.VersionRegex.FindStringSubmatch(version)
+		if match == nil {
+			return meta4, nil
+		} else if len(match) > 1 {
+			version = match[1]
+		}
+	}
+
+	if !s.selectors.Published.IsZero() {
+		if published, found := selectString(s.selectors.Published, release); found {
+			publishedTime, err := time.Parse(time.RFC3339, published)
+			if err != nil {
+				return meta4, errors.Wrap(err, "parsing published")
+			}
+
+			meta4.Published = &publishedTime
+		}
+	}
+
+	files := []interface{}{release}
+
+	if !s.selectors.Files.IsZero() {
+		files = s.selectors.Files.Select(release)
+	}
+
+	for fileIdx, file := range files {
+		fileURL, found := selectString(s.selectors.FileURL, file)
+		if !found {
+			continue
+		}
+
+		resolvedURL, err := baseURL.Parse(fileURL)
+		if err != nil {
+			return meta4, errors.Wrapf(err, "file %d: parsing url", fileIdx)
+		}
+
+		meta4file := metalink.File{
+			Name:    path.Base(resolvedURL.Path),
+			Version: version,
+			URLs: []metalink.URL{
+				{
+					URL: resolvedURL.String(),
+				},
+			},
+		}
+
+		if !s.selectors.FileName.IsZero() {
+			if name, found := selectString(s.selectors.FileName, file); found {
+				meta4file.Name = name
+			}
+		}
+
+		if !s.selectors.FileSize.IsZero() {
+			if size, found := selectString(s.selectors.FileSize, file); found {
+				meta4file.Size, err = strconv.ParseUint(size, 10, 64)
+				if err != nil {
+					return meta4, errors.Wrapf(err, "file %d: parsing size", fileIdx)
+				}
+			}
+		}
+
+		for _, hashType := range []metalink.HashType{metalink.HashTypeSHA512, metalink.HashTypeSHA256, metalink.HashTypeSHA1, metalink.HashTypeMD5} {
+			selector, ok := s.selectors.FileHashes[string(hashType)]
+			if !ok {
+				continue
+			}
+
+			if hash, found := selectString(selector, file); found {
+				meta4file.Hashes = append(meta4file.Hashes, metalink.Hash{Type: hashType, Hash: hash})
+			}
+		}
+
+		meta4.Files = append(meta4.Files, meta4file)
+	}
+
+	return meta4, nil
+}
+
+func (s Source) URI() string {
+	return s.uri
+}
+
+func (s Source) Filter(f filter.Filter) ([]repository.RepositoryMetalink, error) {
+	return source.FilterInMemory(s.metalinks, f)
+}
+
+func (s Source) Put(_ string, _ io.Reader) error {
+	return errors.New("jsonindex repositories are read-only")
+}
+
+func selectString(selector jsonpath.Selector, value interface{}) (string, bool) {
+	selected, found := selector.SelectOne(value)
+	if !found {
+		return "", false
+	}
+
+	switch typed := selected.(type) {
+	case string:
+		return typed, typed != ""
+	case json.Number:
+		return typed.String(), true
+	case bool:
+		return strconv.FormatBool(typed), true
+	}
+
+	return "", false
+}
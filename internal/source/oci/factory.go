@@ -0,0 +1,50 @@
+package oci
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dpb587/metalink-repository-resource/internal/oci"
+	"github.com/dpb587/metalink/repository/source"
+	"github.com/pkg/errors"
+)
+
+type Factory struct {
+	httpClient *http.Client
+}
+
+var _ source.Factory = &Factory{}
+
+func NewFactory(httpClient *http.Client) Factory {
+	return Factory{
+		httpClient: httpClient,
+	}
+}
+
+func (f Factory) Schemes() []string {
+	return []string{
+		"oci",
+	}
+}
+
+// Create supports URIs like oci://registry.example.com/path/to/repository.
+func (f Factory) Create(uri string, options map[string]interface{}) (source.Source, error) {
+	parsedURI, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing source URI")
+	}
+
+	repository := strings.Trim(parsedURI.Path, "/")
+	if parsedURI.Host == "" || repository == "" {
+		return nil, fmt.Errorf("expected oci://REGISTRY/REPOSITORY: %s", uri)
+	}
+
+	clientOptions, err := oci.ParseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSource(uri, oci.NewClient(f.httpClient, parsedURI.Host, clientOptions), repository), nil
+}
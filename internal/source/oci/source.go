@@ -0,0 +1,181 @@
+package oci
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/internal/oci"
+	"github.com/dpb587/metalink/repository"
+	"github.com/dpb587/metalink/repository/filter"
+	"github.com/dpb587/metalink/repository/source"
+	"github.com/pkg/errors"
+)
+
+const (
+	ArtifactType      = "application/vnd.dpb587.metalink.v1"
+	MediaTypeMetalink = "application/metalink4+xml"
+)
+
+var invalidTagCharacters = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// Source stores each metalink as an OCI artifact tagged by its name.
+type Source struct {
+	uri        string
+	client     *oci.Client
+	repository string
+
+	metalinks []repository.RepositoryMetalink
+}
+
+var _ source.Source = &Source{}
+
+func NewSource(uri string, client *oci.Client, repository string) *Source {
+	return &Source{
+		uri:        uri,
+		client:     client,
+		repository: repository,
+	}
+}
+
+func (s *Source) Load() error {
+	s.metalinks = []repository.RepositoryMetalink{}
+
+	tags, err := s.client.ListTags(s.repository)
+	if err != nil {
+		return errors.Wrap(err, "listing tags")
+	}
+
+	for _, tag := range tags {
+		manifest, err := s.client.GetManifest(s.repository, tag)
+		if err != nil {
+			return errors.Wrapf(err, "getting manifest: %s", tag)
+		}
+
+		layer, found := findMetalinkLayer(manifest)
+		if !found {
+			// unrelated artifacts may share the repository
+			continue
+		}
+
+		reader, _, err := s.client.GetBlob(s.repository, layer.Digest)
+		if err != nil {
+			return errors.Wrapf(err, "getting metalink: %s", tag)
+		}
+
+		metalinkBytes, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return errors.Wrapf(err, "reading metalink: %s", tag)
+		} else if oci.Digest(metalinkBytes) != layer.Digest {
+			return errors.Errorf("reading metalink: %s: digest mismatch", tag)
+		}
+
+		path := layer.Annotations[oci.AnnotationTitle]
+		if path == "" {
+			path = tag
+		}
+
+		repometa4 := repository.RepositoryMetalink{
+			Reference: repository.RepositoryMetalinkReference{
+				Repository: s.uri,
+				Path:       path,
+			},
+		}
+
+		err = metalink.Unmarshal(metalinkBytes, &repometa4.Metalink)
+		if err != nil {
+			return errors.Wrapf(err, "unmarshaling: %s", tag)
+		}
+
+		s.metalinks = append(s.metalinks, repometa4)
+	}
+
+	return nil
+}
+
+func (s Source) URI() string {
+	return s.uri
+}
+
+func (s Source) Filter(f filter.Filter) ([]repository.RepositoryMetalink, error) {
+	return source.FilterInMemory(s.metalinks, f)
+}
+
+func (s Source) Put(name string, data io.Reader) error {
+	content, err := ioutil.ReadAll(data)
+	if err != nil {
+		return errors.Wrap(err, "reading metalink")
+	}
+
+	configDigest := oci.Digest(oci.EmptyJSON)
+
+	err = s.client.PushBlob(s.repository, configDigest, int64(len(oci.EmptyJSON)), bytes.NewReader(oci.EmptyJSON))
+	if err != nil {
+		return errors.Wrap(err, "pushing config")
+	}
+
+	metalinkDigest := oci.Digest(content)
+
+	err = s.client.PushBlob(s.repository, metalinkDigest, int64(len(content)), bytes.NewReader(content))
+	if err != nil {
+		return errors.Wrap(err, "pushing metalink")
+	}
+
+	manifest := oci.Manifest{
+		SchemaVersion: 2,
+		MediaType:     oci.MediaTypeImageManifest,
+		ArtifactType:  ArtifactType,
+		Config: oci.Descriptor{
+			MediaType: oci.MediaTypeEmptyJSON,
+			Digest:    configDigest,
+			Size:      int64(len(oci.EmptyJSON)),
+		},
+		Layers: []oci.Descriptor{
+			{
+				MediaType: MediaTypeMetalink,
+				Digest:    metalinkDigest,
+				Size:      int64(len(content)),
+				Annotations: map[string]string{
+					oci.AnnotationTitle: name,
+				},
+			},
+		},
+	}
+
+	_, err = s.client.PutManifest(s.repository, TagName(name), manifest)
+	if err != nil {
+		return errors.Wrap(err, "pushing manifest")
+	}
+
+	return nil
+}
+
+// TagName converts a metalink name (e.g. v1.2.3+build.meta4) into a valid tag
+// (e.g. v1.2.3_build).
+func TagName(name string) string {
+	tag := invalidTagCharacters.ReplaceAllString(strings.TrimSuffix(name, ".meta4"), "_")
+
+	if strings.HasPrefix(tag, ".") || strings.HasPrefix(tag, "-") {
+		tag = "_" + tag
+	}
+
+	if len(tag) > 128 {
+		tag = tag[0:128]
+	}
+
+	return tag
+}
+
+func findMetalinkLayer(manifest oci.Manifest) (oci.Descriptor, bool) {
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == MediaTypeMetalink {
+			return layer, true
+		}
+	}
+
+	return oci.Descriptor{}, false
+}
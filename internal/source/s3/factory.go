@@ -0,0 +1,71 @@
+package s3
+
+import (
+	"net/url"
+	"os"
+
+	"github.com/dpb587/metalink-repository-resource/internal/s3"
+	"github.com/dpb587/metalink/repository/source"
+	"github.com/pkg/errors"
+)
+
+type Factory struct{}
+
+var _ source.Factory = &Factory{}
+
+func NewFactory() Factory {
+	return Factory{}
+}
+
+func (f Factory) Schemes() []string {
+	return []string{
+		"s3",
+	}
+}
+
+// Create supports URIs like s3://s3.amazonaws.com/bucket/prefix where
+// credentials may be configured by options or the user info of the URI.
+// Otherwise (and unless the credential chain is used), they default to the
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_ROLE_ARN
+// environment variables.
+func (f Factory) Create(uri string, options map[string]interface{}) (source.Source, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing source URI")
+	}
+
+	location, err := s3.ParseLocation(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	clientOptions, err := s3.ParseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// the credential chain reads the environment itself (where AWS_ROLE_ARN is
+	// the role of web identity tokens)
+	if !clientOptions.CredentialChain && clientOptions.Profile == "" {
+		if parsed.User != nil {
+			clientOptions.AccessKey = parsed.User.Username()
+			clientOptions.SecretKey, _ = parsed.User.Password()
+		} else if clientOptions.AccessKey == "" && clientOptions.SecretKey == "" && clientOptions.SessionToken == "" {
+			// explicit keys are never mixed with those of the environment
+			clientOptions.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+			clientOptions.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+			clientOptions.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+
+			if clientOptions.RoleARN == "" {
+				clientOptions.RoleARN = os.Getenv("AWS_ROLE_ARN")
+			}
+		}
+	}
+
+	client, err := s3.NewClient(location, clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSource(uri, client, location, clientOptions), nil
+}
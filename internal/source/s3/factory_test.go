@@ -0,0 +1,78 @@
+package s3
+
+import (
+	"os"
+
+	"github.com/dpb587/metalink-repository-resource/internal/s3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Factory", func() {
+	var previousEnv map[string]*string
+
+	BeforeEach(func() {
+		previousEnv = map[string]*string{}
+
+		for name, value := range map[string]string{
+			"AWS_ACCESS_KEY_ID":     "env-access-key",
+			"AWS_SECRET_ACCESS_KEY": "env-secret-key",
+			"AWS_SESSION_TOKEN":     "env-session-token",
+			"AWS_ROLE_ARN":          "arn:aws:iam::123456789012:role/env",
+		} {
+			if previous, set := os.LookupEnv(name); set {
+				previousEnv[name] = &previous
+			} else {
+				previousEnv[name] = nil
+			}
+
+			Expect(os.Setenv(name, value)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		for name, value := range previousEnv {
+			if value == nil {
+				Expect(os.Unsetenv(name)).To(Succeed())
+			} else {
+				Expect(os.Setenv(name, *value)).To(Succeed())
+			}
+		}
+	})
+
+	create := func(uri string, options map[string]interface{}) s3.Options {
+		src, err := NewFactory().Create(uri, options)
+		Expect(err).NotTo(HaveOccurred())
+
+		return src.(*Source).options
+	}
+
+	It("defaults to the keys of the environment", func() {
+		options := create("s3://s3.amazonaws.com/bucket/prefix", map[string]interface{}{})
+		Expect(options.AccessKey).To(Equal("env-access-key"))
+		Expect(options.SecretKey).To(Equal("env-secret-key"))
+		Expect(options.SessionToken).To(Equal("env-session-token"))
+		Expect(options.RoleARN).To(Equal("arn:aws:iam::123456789012:role/env"))
+	})
+
+	It("never combines explicit keys with those of the environment", func() {
+		options := create("s3://s3.amazonaws.com/bucket/prefix", map[string]interface{}{"access_key": "explicit-access-key"})
+		Expect(options.AccessKey).To(Equal("explicit-access-key"))
+		Expect(options.SecretKey).To(BeEmpty())
+		Expect(options.SessionToken).To(BeEmpty())
+		Expect(options.RoleARN).To(BeEmpty())
+	})
+
+	It("prefers the user info of the URI", func() {
+		options := create("s3://uri-access-key:uri-secret-key@s3.amazonaws.com/bucket/prefix", map[string]interface{}{})
+		Expect(options.AccessKey).To(Equal("uri-access-key"))
+		Expect(options.SecretKey).To(Equal("uri-secret-key"))
+		Expect(options.SessionToken).To(BeEmpty())
+	})
+
+	It("leaves the environment to the credential chain", func() {
+		options := create("s3://s3.amazonaws.com/bucket/prefix", map[string]interface{}{"credential_chain": true})
+		Expect(options.AccessKey).To(BeEmpty())
+		Expect(options.RoleARN).To(BeEmpty())
+	})
+})
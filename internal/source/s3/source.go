@@ -0,0 +1,106 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/internal/s3"
+	"github.com/dpb587/metalink/repository"
+	"github.com/dpb587/metalink/repository/filter"
+	"github.com/dpb587/metalink/repository/source"
+	minio "github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+type Source struct {
+	rawURI   string
+	client   *minio.Client
+	location s3.Location
+	options  s3.Options
+
+	metalinks []repository.RepositoryMetalink
+}
+
+var _ source.Source = &Source{}
+
+func NewSource(rawURI string, client *minio.Client, location s3.Location, options s3.Options) *Source {
+	return &Source{
+		rawURI:   rawURI,
+		client:   client,
+		location: location,
+		options:  options,
+	}
+}
+
+func (s *Source) Load() error {
+	uri := s.URI()
+	s.metalinks = []repository.RepositoryMetalink{}
+
+	listOptions := minio.ListObjectsOptions{
+		Prefix:    s.location.Key,
+		Recursive: true,
+	}
+
+	for object := range s.client.ListObjects(context.Background(), s.location.Bucket, listOptions) {
+		if object.Err != nil {
+			return errors.Wrap(object.Err, "Listing objects")
+		} else if !strings.HasSuffix(object.Key, ".meta4") {
+			continue
+		}
+
+		get, err := s.client.GetObject(context.Background(), s.location.Bucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			return errors.Wrap(err, "Getting object")
+		}
+
+		metalinkBytes, err := ioutil.ReadAll(get)
+		get.Close()
+		if err != nil {
+			return errors.Wrap(err, "Reading object")
+		}
+
+		repometa4 := repository.RepositoryMetalink{
+			Reference: repository.RepositoryMetalinkReference{
+				Repository: uri,
+				Path:       strings.TrimPrefix(object.Key, s.location.Key),
+			},
+		}
+
+		err = metalink.Unmarshal(metalinkBytes, &repometa4.Metalink)
+		if err != nil {
+			return errors.Wrap(err, "Unmarshaling")
+		}
+
+		s.metalinks = append(s.metalinks, repometa4)
+	}
+
+	return nil
+}
+
+func (s Source) URI() string {
+	return s.rawURI
+}
+
+func (s Source) Filter(f filter.Filter) ([]repository.RepositoryMetalink, error) {
+	return source.FilterInMemory(s.metalinks, f)
+}
+
+func (s Source) Put(name string, data io.Reader) error {
+	// metalinks are small; a known size avoids buffering a multipart upload
+	dataBytes, err := ioutil.ReadAll(data)
+	if err != nil {
+		return errors.Wrap(err, "Reading metalink")
+	}
+
+	_, err = s.client.PutObject(context.Background(), s.location.Bucket, path.Join(s.location.Key, name), bytes.NewReader(dataBytes), int64(len(dataBytes)), s.options.PutObjectOptions())
+	if err != nil {
+		return errors.Wrap(err, "Writing object")
+	}
+
+	return nil
+}
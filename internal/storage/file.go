@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"fmt"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type fileStorage struct{}
+
+var _ Storage = fileStorage{}
+
+func NewFileStorage() Storage {
+	return fileStorage{}
+}
+
+func (fileStorage) List(prefix string) ([]Object, error) {
+	prefixPath, err := filePath(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// the prefix may end with a partial file name
+	dir := prefixPath
+	if !strings.HasSuffix(prefixPath, "/") {
+		dir = filepath.Dir(prefixPath)
+	}
+
+	var objects []Object
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+
+			return err
+		} else if !info.Mode().IsRegular() || !strings.HasPrefix(path, prefixPath) {
+			return nil
+		}
+
+		objects = append(objects, Object{
+			URI:          fmt.Sprintf("file://%s", path),
+			Size:         uint64(info.Size()),
+			LastModified: info.ModTime(),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "walking directory")
+	}
+
+	return objects, nil
+}
+
+func (fileStorage) Delete(uri string) error {
+	path, err := filePath(uri)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func filePath(uri string) (string, error) {
+	parsed, err := neturl.Parse(uri)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing uri")
+	} else if parsed.Scheme != "file" {
+		return "", fmt.Errorf("unsupported uri: %s", uri)
+	}
+
+	return parsed.Path, nil
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	neturl "net/url"
+
+	"github.com/dpb587/metalink-repository-resource/internal/s3"
+	minio "github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+type s3Storage struct {
+	options s3.Options
+}
+
+var _ Storage = s3Storage{}
+
+// NewS3Storage supports the same URIs (s3://endpoint/bucket/key) and
+// credentials as the s3 URL handler.
+func NewS3Storage(options s3.Options) Storage {
+	return s3Storage{
+		options: options,
+	}
+}
+
+func (s s3Storage) List(prefix string) ([]Object, error) {
+	client, scheme, location, err := s.client(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+
+	for object := range client.ListObjects(context.Background(), location.Bucket, minio.ListObjectsOptions{Prefix: location.Key, Recursive: true}) {
+		if object.Err != nil {
+			return nil, errors.Wrap(object.Err, "listing objects")
+		}
+
+		objects = append(objects, Object{
+			URI:          fmt.Sprintf("%s://%s/%s/%s", scheme, location.Host, location.Bucket, object.Key),
+			ReaderURI:    s3.ReaderURI(location, s.options, object.Key),
+			Size:         uint64(object.Size),
+			LastModified: object.LastModified,
+		})
+	}
+
+	return objects, nil
+}
+
+func (s s3Storage) Delete(uri string) error {
+	client, _, location, err := s.client(uri)
+	if err != nil {
+		return err
+	}
+
+	err = client.RemoveObject(context.Background(), location.Bucket, location.Key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return errors.Wrap(err, "removing object")
+	}
+
+	return nil
+}
+
+func (s s3Storage) client(uri string) (*minio.Client, string, s3.Location, error) {
+	parsed, err := neturl.Parse(uri)
+	if err != nil {
+		return nil, "", s3.Location{}, errors.Wrap(err, "parsing uri")
+	}
+
+	location, err := s3.ParseLocation(parsed)
+	if err != nil {
+		return nil, "", location, err
+	}
+
+	client, err := s3.NewClient(location, s.options)
+	if err != nil {
+		return nil, "", location, err
+	}
+
+	return client, parsed.Scheme, location, nil
+}
@@ -0,0 +1,22 @@
+package storage
+
+import "time"
+
+// Object is a blob stored under a mirror destination.
+type Object struct {
+	// URI is the location in the same form as mirror destinations.
+	URI string
+
+	// ReaderURI is the location as recorded in metalinks after mirroring, if it
+	// differs from URI.
+	ReaderURI string
+
+	Size         uint64
+	LastModified time.Time
+}
+
+// Storage lists and deletes blobs of a mirror destination.
+type Storage interface {
+	List(prefix string) ([]Object, error)
+	Delete(uri string) error
+}
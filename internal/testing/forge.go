@@ -0,0 +1,286 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Forge is an in-process subset of the pull request APIs of GitHub (under
+// /api/v3 and /api/graphql), GitLab (under /api/v4), and Gitea (under /api/v1).
+type Forge struct {
+	*httptest.Server
+
+	// Token is required by every request when configured.
+	Token string
+
+	mutex        sync.Mutex
+	pullRequests []*ForgePullRequest
+}
+
+type ForgePullRequest struct {
+	Forge      string
+	Repository string
+	Head       string
+	Base       string
+	Title      string
+	Body       string
+	AutoMerge  bool
+	URL        string
+}
+
+func NewForge() *Forge {
+	f := &Forge{}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+
+	return f
+}
+
+// PullRequests returns the pull requests opened so far.
+func (f *Forge) PullRequests() []ForgePullRequest {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var pullRequests []ForgePullRequest
+
+	for _, pr := range f.pullRequests {
+		pullRequests = append(pullRequests, *pr)
+	}
+
+	return pullRequests
+}
+
+func (f *Forge) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	path := strings.Split(strings.Trim(req.URL.EscapedPath(), "/"), "/")
+
+	last := func(offset int) string {
+		if len(path) <= offset {
+			return ""
+		}
+
+		return path[len(path)-1-offset]
+	}
+
+	repository := func(trim int) string {
+		if len(path) < 4+trim {
+			return ""
+		}
+
+		repository, _ := url.PathUnescape(strings.Join(path[3:len(path)-trim], "/"))
+
+		return repository
+	}
+
+	if len(path) == 2 && path[0] == "api" && path[1] == "graphql" {
+		if f.authorized(w, req, "Authorization", "Bearer ") {
+			f.serveGitHubGraphQL(w, req)
+		}
+
+		return
+	} else if len(path) < 3 || path[0] != "api" {
+		f.writeJSON(w, http.StatusNotFound, map[string]interface{}{"message": "Not Found"})
+
+		return
+	}
+
+	switch {
+	case path[1] == "v3" && path[2] == "repos" && last(0) == "pulls" && req.Method == http.MethodGet:
+		if f.authorized(w, req, "Authorization", "Bearer ") {
+			head := req.URL.Query().Get("head")
+			if idx := strings.Index(head, ":"); idx >= 0 {
+				head = head[idx+1:]
+			}
+
+			f.list(w, "github", repository(1), head, req.URL.Query().Get("base"))
+		}
+	case path[1] == "v3" && path[2] == "repos" && last(0) == "pulls" && req.Method == http.MethodPost:
+		if f.authorized(w, req, "Authorization", "Bearer ") {
+			f.create(w, req, "github", repository(1), "head", "base", "body")
+		}
+	case path[1] == "v4" && path[2] == "projects" && last(0) == "merge_requests" && req.Method == http.MethodGet:
+		if f.authorized(w, req, "Private-Token", "") {
+			f.list(w, "gitlab", repository(1), req.URL.Query().Get("source_branch"), req.URL.Query().Get("target_branch"))
+		}
+	case path[1] == "v4" && path[2] == "projects" && last(0) == "merge_requests" && req.Method == http.MethodPost:
+		if f.authorized(w, req, "Private-Token", "") {
+			f.create(w, req, "gitlab", repository(1), "source_branch", "target_branch", "description")
+		}
+	case path[1] == "v4" && path[2] == "projects" && last(0) == "merge" && last(2) == "merge_requests" && req.Method == http.MethodPut:
+		if f.authorized(w, req, "Private-Token", "") {
+			f.merge(w, req, "gitlab", repository(3), last(1), "merge_when_pipeline_succeeds")
+		}
+	case path[1] == "v1" && path[2] == "repos" && last(0) == "pulls" && req.Method == http.MethodGet:
+		if f.authorized(w, req, "Authorization", "token ") {
+			if req.URL.Query().Get("page") != "1" {
+				// a single page is served
+				f.writeJSON(w, http.StatusOK, []interface{}{})
+			} else {
+				f.list(w, "gitea", repository(1), "", "")
+			}
+		}
+	case path[1] == "v1" && path[2] == "repos" && last(0) == "pulls" && req.Method == http.MethodPost:
+		if f.authorized(w, req, "Authorization", "token ") {
+			f.create(w, req, "gitea", repository(1), "head", "base", "body")
+		}
+	case path[1] == "v1" && path[2] == "repos" && last(0) == "merge" && last(2) == "pulls" && req.Method == http.MethodPost:
+		if f.authorized(w, req, "Authorization", "token ") {
+			f.merge(w, req, "gitea", repository(3), last(1), "merge_when_checks_succeed")
+		}
+	default:
+		f.writeJSON(w, http.StatusNotFound, map[string]interface{}{"message": "Not Found"})
+	}
+}
+
+func (f *Forge) authorized(w http.ResponseWriter, req *http.Request, header, prefix string) bool {
+	if f.Token == "" || req.Header.Get(header) == prefix+f.Token {
+		return true
+	}
+
+	f.writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"message": "Bad credentials"})
+
+	return false
+}
+
+func (f *Forge) create(w http.ResponseWriter, req *http.Request, forge, repository, headKey, baseKey, bodyKey string) {
+	var body map[string]interface{}
+
+	if json.NewDecoder(req.Body).Decode(&body) != nil {
+		f.writeJSON(w, http.StatusBadRequest, map[string]interface{}{"message": "Problems parsing JSON"})
+
+		return
+	}
+
+	for _, existing := range f.pullRequests {
+		if existing.Forge == forge && existing.Repository == repository && existing.Head == body[headKey] {
+			f.writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"message": "A pull request already exists"})
+
+			return
+		}
+	}
+
+	number := len(f.pullRequests) + 1
+
+	pr := &ForgePullRequest{
+		Forge:      forge,
+		Repository: repository,
+		Head:       fmt.Sprintf("%v", body[headKey]),
+		Base:       fmt.Sprintf("%v", body[baseKey]),
+		Title:      fmt.Sprintf("%v", body["title"]),
+		Body:       fmt.Sprintf("%v", body[bodyKey]),
+		URL:        fmt.Sprintf("%s/%s/pulls/%d", f.URL, repository, number),
+	}
+
+	f.pullRequests = append(f.pullRequests, pr)
+
+	f.writeJSON(w, http.StatusCreated, f.response(number))
+}
+
+// list responds with the pull requests of a repository, optionally limited to
+// a head and base branch. Every pull request is considered open.
+func (f *Forge) list(w http.ResponseWriter, forge, repository, head, base string) {
+	pullRequests := []interface{}{}
+
+	for idx, pr := range f.pullRequests {
+		if pr.Forge != forge || pr.Repository != repository || (head != "" && pr.Head != head) || (base != "" && pr.Base != base) {
+			continue
+		}
+
+		pullRequests = append(pullRequests, f.response(idx+1))
+	}
+
+	f.writeJSON(w, http.StatusOK, pullRequests)
+}
+
+func (f *Forge) response(number int) map[string]interface{} {
+	pr := f.pullRequests[number-1]
+
+	return map[string]interface{}{
+		"number":   number,
+		"iid":      number,
+		"node_id":  fmt.Sprintf("PR_%d", number),
+		"html_url": pr.URL,
+		"web_url":  pr.URL,
+		"head":     map[string]interface{}{"ref": pr.Head},
+		"base":     map[string]interface{}{"ref": pr.Base},
+	}
+}
+
+func (f *Forge) merge(w http.ResponseWriter, req *http.Request, forge, repository, number, autoMergeKey string) {
+	var body map[string]interface{}
+
+	if json.NewDecoder(req.Body).Decode(&body) != nil {
+		f.writeJSON(w, http.StatusBadRequest, map[string]interface{}{"message": "Problems parsing JSON"})
+
+		return
+	}
+
+	pr := f.find(forge, repository, number)
+	if pr == nil {
+		f.writeJSON(w, http.StatusNotFound, map[string]interface{}{"message": "Not Found"})
+
+		return
+	}
+
+	pr.AutoMerge = body[autoMergeKey] == true
+
+	f.writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (f *Forge) serveGitHubGraphQL(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+
+	if json.NewDecoder(req.Body).Decode(&body) != nil {
+		f.writeJSON(w, http.StatusBadRequest, map[string]interface{}{"message": "Problems parsing JSON"})
+
+		return
+	}
+
+	id, _ := body.Variables["id"].(string)
+
+	pr := f.find("github", "", strings.TrimPrefix(id, "PR_"))
+	if !strings.Contains(body.Query, "enablePullRequestAutoMerge") || pr == nil {
+		f.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"errors": []map[string]interface{}{{"message": fmt.Sprintf("Could not resolve to a node with the global id of '%s'", id)}},
+		})
+
+		return
+	}
+
+	pr.AutoMerge = true
+
+	f.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{"enablePullRequestAutoMerge": map[string]interface{}{"clientMutationId": nil}},
+	})
+}
+
+func (f *Forge) find(forge, repository, number string) *ForgePullRequest {
+	idx, err := strconv.Atoi(number)
+	if err != nil || idx < 1 || idx > len(f.pullRequests) {
+		return nil
+	}
+
+	pr := f.pullRequests[idx-1]
+	if pr.Forge != forge || (repository != "" && pr.Repository != repository) {
+		return nil
+	}
+
+	return pr
+}
+
+func (f *Forge) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(body)
+}
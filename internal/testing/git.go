@@ -0,0 +1,228 @@
+package testing
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GitRemote is a bare git repository on the local file system.
+type GitRemote struct {
+	Dir    string
+	Branch string
+
+	// Env is added to the environment of commits (e.g. GNUPGHOME).
+	Env []string
+}
+
+// NewGitRemote creates a bare repository with an initial commit on branch.
+func NewGitRemote(branch string) (*GitRemote, error) {
+	dir, err := ioutil.TempDir("", "metalink-repository-resource-git")
+	if err != nil {
+		return nil, err
+	}
+
+	g := &GitRemote{
+		Dir:    filepath.Join(dir, "remote.git"),
+		Branch: branch,
+	}
+
+	_, err = runGit(dir, nil, "init", "--bare", "--initial-branch", branch, g.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.Commit(map[string]string{"README.md": "metalinks\n"}, "initial commit")
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// URI returns the source URI for a path within the repository.
+func (g *GitRemote) URI(path string) string {
+	if path != "" {
+		path = "//" + path
+	}
+
+	return fmt.Sprintf("git+file://%s%s#%s", g.Dir, path, g.Branch)
+}
+
+// Git runs a git command against the bare repository.
+func (g *GitRemote) Git(args ...string) (string, error) {
+	return runGit(g.Dir, g.Env, args...)
+}
+
+// Commit writes files in a new commit and pushes it. The configArgs are passed
+// before the commit command (e.g. `-c`, `commit.gpgsign=true`).
+func (g *GitRemote) Commit(files map[string]string, message string, configArgs ...string) error {
+	workDir, err := ioutil.TempDir("", "metalink-repository-resource-git-work")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(workDir)
+
+	_, err = runGit(workDir, g.Env, "clone", "--quiet", g.Dir, ".")
+	if err != nil {
+		return err
+	}
+
+	_, err = runGit(workDir, g.Env, "checkout", "--quiet", "-B", g.Branch)
+	if err != nil {
+		return err
+	}
+
+	for name, content := range files {
+		err = os.MkdirAll(filepath.Dir(filepath.Join(workDir, name)), 0755)
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(filepath.Join(workDir, name), []byte(content), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = runGit(workDir, g.Env, "add", "--all")
+	if err != nil {
+		return err
+	}
+
+	_, err = runGit(workDir, g.Env, append(append([]string{}, configArgs...), "commit", "--quiet", "--message", message)...)
+	if err != nil {
+		return err
+	}
+
+	_, err = runGit(workDir, g.Env, "push", "--quiet", "origin", g.Branch)
+
+	return err
+}
+
+// ServeHTTPS serves the repository at /remote.git using git http-backend and
+// basic authentication, when username is configured. Pushes are allowed.
+func (g *GitRemote) ServeHTTPS(username, password string) (*httptest.Server, error) {
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git --exec-path: %s", err)
+	}
+
+	_, err = g.Git("config", "http.receivepack", "true")
+	if err != nil {
+		return nil, err
+	}
+
+	backend := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend"),
+		Env: []string{
+			fmt.Sprintf("GIT_PROJECT_ROOT=%s", filepath.Dir(g.Dir)),
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+	}
+
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if username != "" {
+			reqUsername, reqPassword, ok := req.BasicAuth()
+			if !ok || reqUsername != username || reqPassword != password {
+				w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+		}
+
+		backend.ServeHTTP(w, req)
+	})), nil
+}
+
+// CACertificate returns the PEM certificate of a TLS test server.
+func CACertificate(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+func (g *GitRemote) Close() error {
+	return os.RemoveAll(filepath.Dir(g.Dir))
+}
+
+func runGit(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(
+		append(os.Environ(), env...),
+		"GIT_AUTHOR_NAME=test",
+		"GIT_AUTHOR_EMAIL=test@localhost",
+		"GIT_COMMITTER_NAME=test",
+		"GIT_COMMITTER_EMAIL=test@localhost",
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("git %s: %s: %s", strings.Join(args, " "), err, output)
+	}
+
+	return string(output), nil
+}
+
+// GenerateSSHKey creates an unencrypted ed25519 key in dir and returns the
+// path of the private key and the public key.
+func GenerateSSHKey(dir string) (string, string, error) {
+	keyPath := filepath.Join(dir, "id_ed25519")
+
+	output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "test@localhost", "-f", keyPath).CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("ssh-keygen: %s: %s", err, output)
+	}
+
+	publicKey, err := ioutil.ReadFile(keyPath + ".pub")
+	if err != nil {
+		return "", "", err
+	}
+
+	return keyPath, strings.TrimSpace(string(publicKey)), nil
+}
+
+// GenerateOpenPGPKey creates an unencrypted signing key in the gnupghome
+// directory and returns the armored private and public keys.
+func GenerateOpenPGPKey(gnupghome string) (string, string, error) {
+	gpg := func(args ...string) (string, error) {
+		cmd := exec.Command("gpg", append([]string{"--batch", "--homedir", gnupghome}, args...)...)
+
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("gpg %s: %s", strings.Join(args, " "), err)
+		}
+
+		return string(output), nil
+	}
+
+	err := os.MkdirAll(gnupghome, 0700)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = gpg("--passphrase", "", "--quick-generate-key", "test <test@localhost>", "ed25519", "sign", "never")
+	if err != nil {
+		return "", "", err
+	}
+
+	privateKey, err := gpg("--armor", "--export-secret-keys", "test@localhost")
+	if err != nil {
+		return "", "", err
+	}
+
+	publicKey, err := gpg("--armor", "--export", "test@localhost")
+	if err != nil {
+		return "", "", err
+	}
+
+	return privateKey, publicKey, nil
+}
@@ -0,0 +1,271 @@
+package testing
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitHub is an in-process subset of the GitHub REST API releases endpoints,
+// served under /api/v3 as GitHub Enterprise does. Responses follow the shape of
+// recorded api.github.com responses.
+type GitHub struct {
+	*httptest.Server
+
+	// Token requires bearer authentication when configured.
+	Token string
+
+	// PageSize limits the releases per response (default 30, as GitHub).
+	PageSize int
+
+	mutex    sync.Mutex
+	releases map[string][]gitHubRelease
+	assets   map[int][]byte
+	assetIDs int
+}
+
+// GitHubRelease describes a release to be served by the fake.
+type GitHubRelease struct {
+	TagName     string
+	Draft       bool
+	Prerelease  bool
+	PublishedAt time.Time
+	Assets      map[string][]byte
+
+	// Digests includes the sha256 digest of assets, as newer API versions do.
+	Digests bool
+}
+
+type gitHubRelease struct {
+	GitHubRelease
+
+	id       int
+	assetIDs map[string]int
+}
+
+func NewGitHub() *GitHub {
+	g := &GitHub{
+		releases: map[string][]gitHubRelease{},
+		assets:   map[int][]byte{},
+	}
+
+	g.Server = httptest.NewServer(http.HandlerFunc(g.serveHTTP))
+
+	return g
+}
+
+// APIURL returns the base URL of the API for use with the api_url option.
+func (g *GitHub) APIURL() string {
+	return fmt.Sprintf("%s/api/v3", g.URL)
+}
+
+// AddRelease adds a release; releases are listed newest first.
+func (g *GitHub) AddRelease(owner, repository string, release GitHubRelease) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	key := fmt.Sprintf("%s/%s", owner, repository)
+
+	stored := gitHubRelease{
+		GitHubRelease: release,
+		id:            len(g.releases[key]) + 1,
+		assetIDs:      map[string]int{},
+	}
+
+	for name, data := range release.Assets {
+		g.assetIDs++
+		g.assets[g.assetIDs] = data
+		stored.assetIDs[name] = g.assetIDs
+	}
+
+	g.releases[key] = append([]gitHubRelease{stored}, g.releases[key]...)
+}
+
+func (g *GitHub) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.URL.Path, "/api/v3/") {
+		g.serveDownload(w, req)
+
+		return
+	}
+
+	if g.Token != "" && req.Header.Get("Authorization") != fmt.Sprintf("Bearer %s", g.Token) {
+		g.writeError(w, http.StatusUnauthorized, "Bad credentials")
+
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(req.URL.Path, "/api/v3/"), "/")
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	switch {
+	case len(path) == 4 && path[0] == "repos" && path[3] == "releases":
+		g.serveReleases(w, req, path[1], path[2])
+	case len(path) == 6 && path[0] == "repos" && path[3] == "releases" && path[4] == "assets":
+		g.serveAsset(w, req, path[1], path[2], path[5])
+	default:
+		g.writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (g *GitHub) serveReleases(w http.ResponseWriter, req *http.Request, owner, repository string) {
+	releases, found := g.releases[fmt.Sprintf("%s/%s", owner, repository)]
+	if !found {
+		g.writeError(w, http.StatusNotFound, "Not Found")
+
+		return
+	}
+
+	pageSize := g.PageSize
+	if perPage, err := strconv.Atoi(req.URL.Query().Get("per_page")); err == nil && perPage > 0 && (pageSize == 0 || perPage < pageSize) {
+		pageSize = perPage
+	} else if pageSize == 0 {
+		pageSize = 30
+	}
+
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	start := (page - 1) * pageSize
+	if start > len(releases) {
+		start = len(releases)
+	}
+
+	end := start + pageSize
+	if end < len(releases) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s/repos/%s/%s/releases?per_page=%d&page=%d>; rel="next"`, g.APIURL(), owner, repository, pageSize, page+1))
+	} else {
+		end = len(releases)
+	}
+
+	var response []map[string]interface{}
+
+	for _, release := range releases[start:end] {
+		var names []string
+
+		for name := range release.Assets {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		assets := []map[string]interface{}{}
+
+		for _, name := range names {
+			data := release.Assets[name]
+			asset := map[string]interface{}{
+				"url":                  fmt.Sprintf("%s/repos/%s/%s/releases/assets/%d", g.APIURL(), owner, repository, release.assetIDs[name]),
+				"id":                   release.assetIDs[name],
+				"name":                 name,
+				"label":                "",
+				"content_type":         "application/octet-stream",
+				"state":                "uploaded",
+				"size":                 len(data),
+				"download_count":       0,
+				"browser_download_url": fmt.Sprintf("%s/%s/%s/releases/download/%s/%s", g.URL, owner, repository, release.TagName, name),
+			}
+
+			if release.Digests {
+				asset["digest"] = fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+			}
+
+			assets = append(assets, asset)
+		}
+
+		entry := map[string]interface{}{
+			"url":          fmt.Sprintf("%s/repos/%s/%s/releases/%d", g.APIURL(), owner, repository, release.id),
+			"html_url":     fmt.Sprintf("%s/%s/%s/releases/tag/%s", g.URL, owner, repository, release.TagName),
+			"id":           release.id,
+			"tag_name":     release.TagName,
+			"name":         release.TagName,
+			"draft":        release.Draft,
+			"prerelease":   release.Prerelease,
+			"created_at":   release.PublishedAt.Format(time.RFC3339),
+			"published_at": release.PublishedAt.Format(time.RFC3339),
+			"assets":       assets,
+		}
+
+		if release.Draft {
+			entry["published_at"] = nil
+		}
+
+		response = append(response, entry)
+	}
+
+	if response == nil {
+		response = []map[string]interface{}{}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (g *GitHub) serveAsset(w http.ResponseWriter, req *http.Request, owner, repository, id string) {
+	for _, release := range g.releases[fmt.Sprintf("%s/%s", owner, repository)] {
+		for name, assetID := range release.assetIDs {
+			if strconv.Itoa(assetID) != id {
+				continue
+			}
+
+			if req.Header.Get("Accept") != "application/octet-stream" {
+				g.writeError(w, http.StatusUnsupportedMediaType, "Unsupported Media Type")
+
+				return
+			}
+
+			// like GitHub, downloads are redirected to storage
+			http.Redirect(w, req, fmt.Sprintf("%s/%s/%s/releases/download/%s/%s", g.URL, owner, repository, release.TagName, name), http.StatusFound)
+
+			return
+		}
+	}
+
+	g.writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (g *GitHub) serveDownload(w http.ResponseWriter, req *http.Request) {
+	path := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 6)
+	if len(path) != 6 || path[2] != "releases" || path[3] != "download" {
+		http.NotFound(w, req)
+
+		return
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for _, release := range g.releases[fmt.Sprintf("%s/%s", path[0], path[1])] {
+		if release.TagName != path[4] {
+			continue
+		}
+
+		if data, found := release.Assets[path[5]]; found {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(data)
+
+			return
+		}
+	}
+
+	http.NotFound(w, req)
+}
+
+func (g *GitHub) writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":           message,
+		"documentation_url": "https://docs.github.com/rest",
+	})
+}
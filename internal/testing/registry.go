@@ -0,0 +1,286 @@
+package testing
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry is an in-process subset of the OCI distribution API (as served by
+// registry:2) with optional bearer token authentication.
+type Registry struct {
+	*httptest.Server
+
+	// Username and Password require token authentication when configured.
+	Username string
+	Password string
+
+	// PageSize limits the tags per response (0 for no pagination).
+	PageSize int
+
+	mutex     sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	tags      map[string]map[string]string
+	uploads   int
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{
+		blobs:     map[string][]byte{},
+		manifests: map[string][]byte{},
+		tags:      map[string]map[string]string{},
+	}
+
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+
+	return r
+}
+
+// Host returns the host:port of the registry for use in oci:// URIs.
+func (r *Registry) Host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func (r *Registry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		r.serveToken(w, req)
+
+		return
+	} else if !strings.HasPrefix(req.URL.Path, "/v2/") {
+		http.NotFound(w, req)
+
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+
+	if !r.authorized(w, req, path) {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	switch {
+	case path == "":
+		w.WriteHeader(http.StatusOK)
+	case strings.HasSuffix(path, "/tags/list"):
+		r.serveTags(w, req, strings.TrimSuffix(path, "/tags/list"))
+	case strings.Contains(path, "/manifests/"):
+		split := splitLast(path, "/manifests/")
+		r.serveManifest(w, req, split[0], split[1])
+	case strings.Contains(path, "/blobs/uploads/"):
+		split := splitLast(path, "/blobs/uploads/")
+		r.serveUpload(w, req, split[0], split[1])
+	case strings.Contains(path, "/blobs/"):
+		split := splitLast(path, "/blobs/")
+		r.serveBlob(w, req, split[0], split[1])
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (r *Registry) authorized(w http.ResponseWriter, req *http.Request, path string) bool {
+	if r.Username == "" {
+		return true
+	}
+
+	action := "pull"
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		action = "push"
+	}
+
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if strings.HasPrefix(token, "fake-token:") {
+		scope := strings.SplitN(strings.TrimPrefix(token, "fake-token:"), ":", 3)
+		if path == "" || len(scope) == 3 && strings.HasPrefix(path, scope[1]+"/") && strings.Contains(scope[2], action) {
+			return true
+		}
+	}
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake-registry"`, r.URL))
+	r.writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+
+	return false
+}
+
+func (r *Registry) serveToken(w http.ResponseWriter, req *http.Request) {
+	username, password, ok := req.BasicAuth()
+	if !ok || username != r.Username || password != r.Password || req.URL.Query().Get("service") != "fake-registry" {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"token": fmt.Sprintf("fake-token:%s", req.URL.Query().Get("scope")),
+	})
+}
+
+func (r *Registry) serveTags(w http.ResponseWriter, req *http.Request, name string) {
+	repoTags, found := r.tags[name]
+	if !found {
+		r.writeError(w, http.StatusNotFound, "NAME_UNKNOWN", "repository name not known to registry")
+
+		return
+	}
+
+	var tags []string
+
+	for tag := range repoTags {
+		if tag > req.URL.Query().Get("last") {
+			tags = append(tags, tag)
+		}
+	}
+
+	sort.Strings(tags)
+
+	if r.PageSize > 0 && len(tags) > r.PageSize {
+		tags = tags[0:r.PageSize]
+		w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?n=%d&last=%s>; rel="next"`, name, r.PageSize, tags[len(tags)-1]))
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name": name,
+		"tags": tags,
+	})
+}
+
+func (r *Registry) serveManifest(w http.ResponseWriter, req *http.Request, name, reference string) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		digest := reference
+		if !strings.HasPrefix(reference, "sha256:") {
+			digest = r.tags[name][reference]
+		}
+
+		manifest, found := r.manifests[name+"@"+digest]
+		if !found {
+			r.writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Length", strconv.Itoa(len(manifest)))
+
+		if req.Method == http.MethodGet {
+			w.Write(manifest)
+		}
+	case http.MethodPut:
+		manifest, _ := ioutil.ReadAll(req.Body)
+
+		var parsed struct {
+			Config registryDescriptor   `json:"config"`
+			Layers []registryDescriptor `json:"layers"`
+		}
+
+		err := json.Unmarshal(manifest, &parsed)
+		if err != nil {
+			r.writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+
+			return
+		}
+
+		for _, descriptor := range append(parsed.Layers, parsed.Config) {
+			if _, found := r.blobs[name+"@"+descriptor.Digest]; !found {
+				r.writeError(w, http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN", descriptor.Digest)
+
+				return
+			}
+		}
+
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+		r.manifests[name+"@"+digest] = manifest
+
+		if !strings.HasPrefix(reference, "sha256:") {
+			if r.tags[name] == nil {
+				r.tags[name] = map[string]string{}
+			}
+
+			r.tags[name][reference] = digest
+		}
+
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+type registryDescriptor struct {
+	Digest string `json:"digest"`
+}
+
+func (r *Registry) serveBlob(w http.ResponseWriter, req *http.Request, name, digest string) {
+	blob, found := r.blobs[name+"@"+digest]
+	if !found {
+		r.writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob unknown to registry")
+
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+	w.Header().Set("Docker-Content-Digest", digest)
+
+	if req.Method == http.MethodGet {
+		w.Write(blob)
+	}
+}
+
+func (r *Registry) serveUpload(w http.ResponseWriter, req *http.Request, name, id string) {
+	switch {
+	case req.Method == http.MethodPost && id == "":
+		r.uploads++
+
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%d", name, r.uploads))
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodPut && id != "":
+		blob, _ := ioutil.ReadAll(req.Body)
+		digest := req.URL.Query().Get("digest")
+
+		if digest != fmt.Sprintf("sha256:%x", sha256.Sum256(blob)) {
+			r.writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "provided digest did not match uploaded content")
+
+			return
+		}
+
+		r.blobs[name+"@"+digest] = blob
+
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *Registry) writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{
+			{
+				"code":    code,
+				"message": message,
+			},
+		},
+	})
+}
+
+// splitLast splits at the last separator since repository names may contain
+// path components like "blobs".
+func splitLast(path, separator string) []string {
+	idx := strings.LastIndex(path, separator)
+
+	return []string{path[0:idx], path[idx+len(separator):]}
+}
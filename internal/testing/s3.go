@@ -0,0 +1,231 @@
+package testing
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3 is an in-process subset of the S3 API (as served by MinIO) over TLS with
+// path-style buckets.
+type S3 struct {
+	*httptest.Server
+
+	// AccessKey is required as the signing credential when configured.
+	AccessKey string
+
+	// SessionToken is required by every request when configured.
+	SessionToken string
+
+	mutex   sync.Mutex
+	buckets map[string]map[string]*S3Object
+}
+
+type S3Object struct {
+	Data         []byte
+	Header       http.Header
+	LastModified time.Time
+}
+
+func NewS3(buckets ...string) *S3 {
+	s := &S3{
+		buckets: map[string]map[string]*S3Object{},
+	}
+
+	for _, bucket := range buckets {
+		s.buckets[bucket] = map[string]*S3Object{}
+	}
+
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+
+	// handshakes of untrusted clients are expected by tests
+	s.Server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	s.Server.StartTLS()
+
+	return s
+}
+
+// Object returns a copy of an object, including the headers of its upload.
+func (s *S3) Object(bucket, key string) (S3Object, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	object, found := s.buckets[bucket][key]
+	if !found {
+		return S3Object{}, false
+	}
+
+	return *object, true
+}
+
+func (s *S3) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if !s.authorized(req) {
+		s.writeError(w, http.StatusForbidden, "AccessDenied", "Access Denied.")
+
+		return
+	}
+
+	split := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	objects, found := s.buckets[split[0]]
+	if !found {
+		s.writeError(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+
+		return
+	}
+
+	if len(split) == 1 || split[1] == "" {
+		s.serveBucket(w, req, split[0], objects)
+
+		return
+	}
+
+	key := split[1]
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		object, found := objects[key]
+		if !found {
+			s.writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+
+			return
+		}
+
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(object.Data)))
+		http.ServeContent(w, req, key, object.LastModified, bytes.NewReader(object.Data))
+	case http.MethodPut:
+		if _, found := req.URL.Query()["uploadId"]; found {
+			s.writeError(w, http.StatusNotImplemented, "NotImplemented", "Multipart uploads are not supported.")
+
+			return
+		}
+
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+
+			return
+		}
+
+		objects[key] = &S3Object{
+			Data:         data,
+			Header:       req.Header.Clone(),
+			LastModified: time.Now().UTC(),
+		}
+
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(data)))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(objects, key)
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *S3) authorized(req *http.Request) bool {
+	if s.SessionToken != "" && req.Header.Get("X-Amz-Security-Token") != s.SessionToken && req.URL.Query().Get("X-Amz-Security-Token") != s.SessionToken {
+		return false
+	}
+
+	if expires := req.URL.Query().Get("X-Amz-Expires"); expires != "" {
+		signed, _ := time.Parse("20060102T150405Z", req.URL.Query().Get("X-Amz-Date"))
+		seconds, _ := strconv.Atoi(expires)
+
+		if time.Now().After(signed.Add(time.Duration(seconds) * time.Second)) {
+			return false
+		}
+	}
+
+	if s.AccessKey == "" {
+		return true
+	}
+
+	return strings.Contains(req.Header.Get("Authorization"), fmt.Sprintf("Credential=%s/", s.AccessKey)) ||
+		strings.HasPrefix(req.URL.Query().Get("X-Amz-Credential"), fmt.Sprintf("%s/", s.AccessKey))
+}
+
+type s3ListBucketResult struct {
+	XMLName     xml.Name     `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name        string       `xml:"Name"`
+	Prefix      string       `xml:"Prefix"`
+	KeyCount    int          `xml:"KeyCount"`
+	MaxKeys     int          `xml:"MaxKeys"`
+	IsTruncated bool         `xml:"IsTruncated"`
+	Contents    []s3Contents `xml:"Contents"`
+}
+
+type s3Contents struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+func (s *S3) serveBucket(w http.ResponseWriter, req *http.Request, bucket string, objects map[string]*S3Object) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	query := req.URL.Query()
+
+	if _, found := query["location"]; found {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></LocationConstraint>`)
+
+		return
+	}
+
+	result := s3ListBucketResult{
+		Name:    bucket,
+		Prefix:  query.Get("prefix"),
+		MaxKeys: 1000,
+	}
+
+	for key, object := range objects {
+		if !strings.HasPrefix(key, result.Prefix) {
+			continue
+		}
+
+		result.Contents = append(result.Contents, s3Contents{
+			Key:          key,
+			LastModified: object.LastModified.Format("2006-01-02T15:04:05.000Z"),
+			ETag:         fmt.Sprintf(`"%x"`, md5.Sum(object.Data)),
+			Size:         len(object.Data),
+			StorageClass: "STANDARD",
+		})
+	}
+
+	sort.Slice(result.Contents, func(i, j int) bool {
+		return result.Contents[i].Key < result.Contents[j].Key
+	})
+
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (s *S3) writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, message)
+}
@@ -0,0 +1,111 @@
+package testing
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// STS is an in-process subset of the AWS STS API which issues the configured
+// credentials for AssumeRole and AssumeRoleWithWebIdentity requests.
+type STS struct {
+	*httptest.Server
+
+	// AccessKey, SecretKey, and SessionToken are issued to callers.
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	mutex    sync.Mutex
+	requests []STSRequest
+}
+
+type STSRequest struct {
+	Action           string
+	RoleARN          string
+	RoleSessionName  string
+	ExternalID       string
+	WebIdentityToken string
+
+	// Credential is the access key of signed requests.
+	Credential    string
+	SecurityToken string
+}
+
+func NewSTS() *STS {
+	s := &STS{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// Requests returns the requests received so far.
+func (s *STS) Requests() []STSRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]STSRequest(nil), s.requests...)
+}
+
+func (s *STS) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	request := STSRequest{
+		Action:           req.Form.Get("Action"),
+		RoleARN:          req.Form.Get("RoleArn"),
+		RoleSessionName:  req.Form.Get("RoleSessionName"),
+		ExternalID:       req.Form.Get("ExternalId"),
+		WebIdentityToken: req.Form.Get("WebIdentityToken"),
+		SecurityToken:    req.Header.Get("X-Amz-Security-Token"),
+	}
+
+	if split := strings.SplitN(req.Header.Get("Authorization"), "Credential=", 2); len(split) == 2 {
+		request.Credential = strings.SplitN(split[1], "/", 2)[0]
+	}
+
+	s.mutex.Lock()
+	s.requests = append(s.requests, request)
+	s.mutex.Unlock()
+
+	switch request.Action {
+	case "AssumeRole":
+		if request.Credential == "" {
+			http.Error(w, "missing signature", http.StatusForbidden)
+
+			return
+		}
+	case "AssumeRoleWithWebIdentity":
+		if request.WebIdentityToken == "" {
+			http.Error(w, "missing token", http.StatusForbidden)
+
+			return
+		}
+	default:
+		http.Error(w, "unsupported action", http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+
+	fmt.Fprintf(
+		w,
+		`<%sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><%sResult><Credentials><AccessKeyId>%s</AccessKeyId><SecretAccessKey>%s</SecretAccessKey><SessionToken>%s</SessionToken><Expiration>%s</Expiration></Credentials></%sResult></%sResponse>`,
+		request.Action,
+		request.Action,
+		s.AccessKey,
+		s.SecretKey,
+		s.SessionToken,
+		time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		request.Action,
+		request.Action,
+	)
+}
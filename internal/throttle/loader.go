@@ -0,0 +1,47 @@
+package throttle
+
+import (
+	neturl "net/url"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink/file"
+	"github.com/dpb587/metalink/file/url"
+)
+
+type loader struct {
+	loader  url.Loader
+	options Options
+}
+
+var _ url.Loader = &loader{}
+
+// NewLoader applies the options to all non-local references of loader.
+func NewLoader(l url.Loader, options Options) url.Loader {
+	if options.IsZero() {
+		return l
+	}
+
+	return &loader{
+		loader:  l,
+		options: options,
+	}
+}
+
+func (l *loader) SupportsURL(source metalink.URL) bool {
+	return l.loader.SupportsURL(source)
+}
+
+func (l *loader) LoadURL(source metalink.URL) (file.Reference, error) {
+	ref, err := l.loader.LoadURL(source)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := neturl.Parse(source.URL)
+	if err == nil && (parsed.Scheme == "" || parsed.Scheme == "file") {
+		// local files are not subject to network limits
+		return ref, nil
+	}
+
+	return NewReference(ref, l.options), nil
+}
@@ -0,0 +1,124 @@
+package throttle
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// monitor enforces the overall and stall timeouts of a transfer. Once expired,
+// any registered abort functions are called to unblock pending reads.
+type monitor struct {
+	options Options
+
+	mutex    sync.Mutex
+	started  time.Time
+	progress time.Time
+	err      error
+	aborts   []func()
+
+	expired chan struct{}
+	stopped chan struct{}
+}
+
+func newMonitor(options Options) *monitor {
+	now := time.Now()
+
+	m := &monitor{
+		options:  options,
+		started:  now,
+		progress: now,
+		expired:  make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	if options.Timeout > 0 || options.StallTimeout > 0 {
+		go m.watch()
+	}
+
+	return m
+}
+
+func (m *monitor) watch() {
+	interval := time.Second
+
+	for _, d := range []time.Duration{m.options.Timeout, m.options.StallTimeout} {
+		if d > 0 && d/4 < interval {
+			interval = d / 4
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopped:
+			return
+		case now := <-ticker.C:
+			if m.check(now) {
+				return
+			}
+		}
+	}
+}
+
+func (m *monitor) check(now time.Time) bool {
+	m.mutex.Lock()
+
+	if m.options.Timeout > 0 && now.Sub(m.started) > m.options.Timeout {
+		m.err = fmt.Errorf("Transfer timed out after %s", m.options.Timeout)
+	} else if m.options.StallTimeout > 0 && now.Sub(m.progress) > m.options.StallTimeout {
+		m.err = fmt.Errorf("Transfer stalled for %s", m.options.StallTimeout)
+	}
+
+	if m.err == nil {
+		m.mutex.Unlock()
+
+		return false
+	}
+
+	aborts := m.aborts
+	m.mutex.Unlock()
+
+	close(m.expired)
+
+	for _, abort := range aborts {
+		abort()
+	}
+
+	return true
+}
+
+func (m *monitor) OnAbort(abort func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.aborts = append(m.aborts, abort)
+}
+
+func (m *monitor) Progress() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.progress = time.Now()
+}
+
+func (m *monitor) Err() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.err
+}
+
+func (m *monitor) Expired() <-chan struct{} {
+	return m.expired
+}
+
+func (m *monitor) Stop() {
+	select {
+	case <-m.stopped:
+	default:
+		close(m.stopped)
+	}
+}
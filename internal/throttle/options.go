@@ -0,0 +1,15 @@
+package throttle
+
+import (
+	"time"
+)
+
+type Options struct {
+	BytesPerSecond uint64
+	Timeout        time.Duration
+	StallTimeout   time.Duration
+}
+
+func (o Options) IsZero() bool {
+	return o.BytesPerSecond == 0 && o.Timeout == 0 && o.StallTimeout == 0
+}
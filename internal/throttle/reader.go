@@ -0,0 +1,92 @@
+package throttle
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+type reader struct {
+	reader  io.ReadCloser
+	options Options
+	monitor *monitor
+	owned   bool
+
+	started time.Time
+	total   uint64
+
+	closeOnce sync.Once
+}
+
+var _ io.ReadCloser = &reader{}
+
+// newReader limits the reads of r by the monitor, which is stopped on close if
+// owned.
+func newReader(r io.ReadCloser, options Options, m *monitor, owned bool) *reader {
+	wrapped := &reader{
+		reader:  r,
+		options: options,
+		monitor: m,
+		owned:   owned,
+		started: time.Now(),
+	}
+
+	m.OnAbort(func() { wrapped.closeReader() })
+
+	return wrapped
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if err := r.monitor.Err(); err != nil {
+		return 0, err
+	}
+
+	if r.options.BytesPerSecond > 0 && uint64(len(p)) > r.options.BytesPerSecond {
+		// avoid bursting more than a second of data at once
+		p = p[:r.options.BytesPerSecond]
+	}
+
+	n, err := r.reader.Read(p)
+
+	if n > 0 {
+		r.monitor.Progress()
+		r.total += uint64(n)
+
+		if r.options.BytesPerSecond > 0 {
+			expected := time.Duration(float64(r.total) / float64(r.options.BytesPerSecond) * float64(time.Second))
+
+			if wait := expected - time.Since(r.started); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-r.monitor.Expired():
+				}
+			}
+		}
+	}
+
+	if err != nil && err != io.EOF {
+		if monitorErr := r.monitor.Err(); monitorErr != nil {
+			return n, monitorErr
+		}
+	}
+
+	return n, err
+}
+
+func (r *reader) Close() error {
+	if r.owned {
+		r.monitor.Stop()
+	}
+
+	return r.closeReader()
+}
+
+func (r *reader) closeReader() error {
+	var err error
+
+	r.closeOnce.Do(func() {
+		err = r.reader.Close()
+	})
+
+	return err
+}
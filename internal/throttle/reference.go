@@ -0,0 +1,150 @@
+package throttle
+
+import (
+	"io"
+
+	"github.com/cheggaaa/pb"
+	"github.com/dpb587/metalink-repository-resource/internal/resumable"
+	"github.com/dpb587/metalink/file"
+)
+
+type reference struct {
+	reference file.Reference
+	options   Options
+	monitor   *monitor
+}
+
+var _ file.Reference = reference{}
+var _ resumable.RangeReader = reference{}
+
+// NewReference limits the reads of a reference (and of references it writes
+// from) to the configured rate and timeouts.
+func NewReference(ref file.Reference, options Options) file.Reference {
+	if _, ok := ref.(reference); ok || options.IsZero() {
+		return ref
+	}
+
+	return reference{
+		reference: ref,
+		options:   options,
+	}
+}
+
+func (r reference) Name() (string, error) {
+	return r.reference.Name()
+}
+
+func (r reference) Size() (uint64, error) {
+	return r.reference.Size()
+}
+
+func (r reference) ReaderURI() string {
+	return r.reference.ReaderURI()
+}
+
+// Unwrap returns the limited reference for optional capabilities (e.g.
+// presigning).
+func (r reference) Unwrap() file.Reference {
+	return r.reference
+}
+
+func (r reference) Reader() (io.ReadCloser, error) {
+	rc, _, err := r.open(func() (io.ReadCloser, bool, error) {
+		rc, err := r.reference.Reader()
+
+		return rc, false, err
+	})
+
+	return rc, err
+}
+
+func (r reference) ReaderFrom(offset uint64) (io.ReadCloser, bool, error) {
+	rangeReader, ok := r.reference.(resumable.RangeReader)
+	if !ok {
+		rc, err := r.Reader()
+
+		return rc, false, err
+	}
+
+	return r.open(func() (io.ReadCloser, bool, error) {
+		return rangeReader.ReaderFrom(offset)
+	})
+}
+
+// open calls a reader function of the wrapped reference within the timeouts,
+// which therefore also cover connecting and waiting for a response.
+func (r reference) open(open func() (io.ReadCloser, bool, error)) (io.ReadCloser, bool, error) {
+	m := r.monitor
+	owned := m == nil
+
+	if owned {
+		m = newMonitor(r.options)
+	}
+
+	type opened struct {
+		rc      io.ReadCloser
+		resumed bool
+		err     error
+	}
+
+	result := make(chan opened, 1)
+
+	go func() {
+		rc, resumed, err := open()
+		result <- opened{rc: rc, resumed: resumed, err: err}
+	}()
+
+	select {
+	case o := <-result:
+		if o.err != nil {
+			if owned {
+				m.Stop()
+			}
+
+			return nil, false, o.err
+		}
+
+		return newReader(o.rc, r.options, m, owned), o.resumed, nil
+	case <-m.Expired():
+		go func() {
+			// the reader may still be opened, but is never used
+			if o := <-result; o.err == nil {
+				o.rc.Close()
+			}
+		}()
+
+		return nil, false, m.Err()
+	}
+}
+
+func (r reference) WriteFrom(from file.Reference, progress *pb.ProgressBar) error {
+	m := newMonitor(r.options)
+	defer m.Stop()
+
+	if fromReference, ok := from.(reference); ok {
+		from = fromReference.reference
+	}
+
+	from = reference{
+		reference: from,
+		options:   r.options,
+		monitor:   m,
+	}
+
+	result := make(chan error, 1)
+
+	go func() {
+		result <- r.reference.WriteFrom(from, progress)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-m.Expired():
+		// the monitor closed the readers; wait for the writer to give up so that
+		// a retry never writes concurrently with it
+		<-result
+
+		return m.Err()
+	}
+}
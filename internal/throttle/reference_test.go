@@ -0,0 +1,133 @@
+package throttle_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/cheggaaa/pb"
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/internal/resumable"
+	"github.com/dpb587/metalink-repository-resource/internal/throttle"
+	"github.com/dpb587/metalink/file"
+	"github.com/dpb587/metalink/file/filefakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reference", func() {
+	var from, to *filefakes.FakeReference
+	var writerReturned chan struct{}
+
+	BeforeEach(func() {
+		from = &filefakes.FakeReference{}
+		to = &filefakes.FakeReference{}
+		writerReturned = make(chan struct{})
+
+		to.WriteFromStub = func(ref file.Reference, _ *pb.ProgressBar) error {
+			defer close(writerReturned)
+
+			reader, err := ref.Reader()
+			if err != nil {
+				return err
+			}
+
+			defer reader.Close()
+
+			_, err = io.Copy(ioutil.Discard, reader)
+			if err != nil {
+				// e.g. aborting an upload
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			return err
+		}
+	})
+
+	Describe("WriteFrom", func() {
+		It("writes from the source", func() {
+			from.ReaderReturns(ioutil.NopCloser(strings.NewReader("data")), nil)
+
+			err := throttle.NewReference(to, throttle.Options{StallTimeout: time.Second}).WriteFrom(from, nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("waits for the writer to give up once stalled", func() {
+			// reads block until the pipe is closed
+			pipeReader, pipeWriter := io.Pipe()
+			defer pipeWriter.Close()
+
+			from.ReaderReturns(pipeReader, nil)
+
+			err := throttle.NewReference(to, throttle.Options{StallTimeout: 100 * time.Millisecond}).WriteFrom(from, nil)
+			Expect(err).To(MatchError("Transfer stalled for 100ms"))
+			Expect(writerReturned).To(BeClosed())
+		})
+
+		It("waits for the writer to give up once timed out", func() {
+			slowReader, slowWriter := io.Pipe()
+			defer slowWriter.Close()
+
+			go func() {
+				// keeps making progress, never finishing
+				for {
+					_, err := slowWriter.Write([]byte("x"))
+					if err != nil {
+						return
+					}
+
+					time.Sleep(10 * time.Millisecond)
+				}
+			}()
+
+			from.ReaderReturns(slowReader, nil)
+
+			err := throttle.NewReference(to, throttle.Options{Timeout: 200 * time.Millisecond, StallTimeout: time.Second}).WriteFrom(from, nil)
+			Expect(err).To(MatchError("Transfer timed out after 200ms"))
+			Expect(writerReturned).To(BeClosed())
+		})
+	})
+
+	Context("with a server which never responds", func() {
+		var server *httptest.Server
+		var release chan struct{}
+		var ref file.Reference
+
+		BeforeEach(func() {
+			release = make(chan struct{})
+
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-release
+			}))
+
+			var err error
+
+			ref, err = resumable.NewHTTPLoader(http.DefaultClient).LoadURL(metalink.URL{URL: server.URL + "/file"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			close(release)
+			server.Close()
+		})
+
+		It("times out waiting for the response", func() {
+			_, err := throttle.NewReference(ref, throttle.Options{Timeout: 200 * time.Millisecond}).Reader()
+			Expect(err).To(MatchError("Transfer timed out after 200ms"))
+		})
+
+		It("times out waiting for ranges of the response", func() {
+			_, _, err := throttle.NewReference(ref, throttle.Options{StallTimeout: 200 * time.Millisecond}).(resumable.RangeReader).ReaderFrom(10)
+			Expect(err).To(MatchError("Transfer stalled for 200ms"))
+		})
+
+		It("times out writing from the reference", func() {
+			err := throttle.NewReference(to, throttle.Options{Timeout: 200 * time.Millisecond}).WriteFrom(ref, nil)
+			Expect(err).To(MatchError("Transfer timed out after 200ms"))
+			Expect(writerReturned).To(BeClosed())
+		})
+	})
+})
@@ -0,0 +1,72 @@
+package oci
+
+import (
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strings"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/internal/oci"
+	"github.com/dpb587/metalink/file"
+	"github.com/dpb587/metalink/file/url"
+	"github.com/pkg/errors"
+)
+
+var digestRegex = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+type loader struct {
+	httpClient *http.Client
+	options    oci.Options
+}
+
+var _ url.Loader = &loader{}
+
+func NewLoader(httpClient *http.Client, options oci.Options) url.Loader {
+	return &loader{
+		httpClient: httpClient,
+		options:    options,
+	}
+}
+
+func (l loader) SupportsURL(source metalink.URL) bool {
+	parsed, err := neturl.Parse(source.URL)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Scheme == "oci"
+}
+
+// LoadURL supports blobs by digest (oci://registry/repository@sha256:...) and
+// files of a tagged artifact (oci://registry/repository:tag/name).
+func (l loader) LoadURL(source metalink.URL) (file.Reference, error) {
+	parsed, err := neturl.Parse(source.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing URI")
+	}
+
+	path := strings.TrimPrefix(parsed.Path, "/")
+	client := oci.NewClient(l.httpClient, parsed.Host, l.options)
+
+	if split := strings.SplitN(path, "@", 2); len(split) == 2 {
+		if split[0] == "" || !digestRegex.MatchString(split[1]) {
+			return nil, fmt.Errorf("invalid oci blob reference: %s", source.URL)
+		}
+
+		return NewReference(source.URL, client, split[0], split[1], "", ""), nil
+	}
+
+	tagIdx := strings.LastIndex(path, ":")
+	if tagIdx <= 0 {
+		return nil, fmt.Errorf("expected oci://REGISTRY/REPOSITORY@DIGEST or oci://REGISTRY/REPOSITORY:TAG/NAME: %s", source.URL)
+	}
+
+	split := strings.SplitN(path[tagIdx+1:], "/", 2)
+	if len(split) != 2 || split[0] == "" || split[1] == "" {
+		return nil, fmt.Errorf("expected oci://REGISTRY/REPOSITORY:TAG/NAME: %s", source.URL)
+	}
+
+	return NewReference(source.URL, client, path[0:tagIdx], "", split[0], split[1]), nil
+}
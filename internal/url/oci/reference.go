@@ -0,0 +1,216 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/cheggaaa/pb"
+	"github.com/dpb587/metalink-repository-resource/internal/oci"
+	"github.com/dpb587/metalink/file"
+	"github.com/pkg/errors"
+)
+
+const (
+	ArtifactType      = "application/vnd.dpb587.metalink.files.v1"
+	MediaTypeFileBlob = "application/octet-stream"
+)
+
+// Reference is either a blob (by digest) or a file of a tagged artifact (by
+// tag and name, using the title annotation of its layer).
+type Reference struct {
+	uri        string
+	client     *oci.Client
+	repository string
+	digest     string
+	tag        string
+	name       string
+}
+
+var _ file.Reference = Reference{}
+
+func NewReference(uri string, client *oci.Client, repository, digest, tag, name string) Reference {
+	return Reference{
+		uri:        uri,
+		client:     client,
+		repository: repository,
+		digest:     digest,
+		tag:        tag,
+		name:       name,
+	}
+}
+
+func (o Reference) Name() (string, error) {
+	if o.name != "" {
+		return path.Base(o.name), nil
+	}
+
+	return o.digest, nil
+}
+
+func (o Reference) Size() (uint64, error) {
+	if o.digest != "" {
+		size, err := o.client.StatBlob(o.repository, o.digest)
+		if err != nil {
+			return 0, errors.Wrap(err, "Checking blob")
+		}
+
+		return uint64(size), nil
+	}
+
+	layer, err := o.layer()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(layer.Size), nil
+}
+
+func (o Reference) Reader() (io.ReadCloser, error) {
+	digest := o.digest
+
+	if digest == "" {
+		layer, err := o.layer()
+		if err != nil {
+			return nil, err
+		}
+
+		digest = layer.Digest
+	}
+
+	reader, _, err := o.client.GetBlob(o.repository, digest)
+	if err != nil {
+		return nil, errors.Wrap(err, "Getting blob")
+	}
+
+	return reader, nil
+}
+
+func (o Reference) ReaderURI() string {
+	return o.uri
+}
+
+func (o Reference) WriteFrom(from file.Reference, progress *pb.ProgressBar) error {
+	size, err := from.Size()
+	if err != nil {
+		return errors.Wrap(err, "Checking size")
+	}
+
+	reader, err := from.Reader()
+	if err != nil {
+		return errors.Wrap(err, "Opening from")
+	}
+
+	defer reader.Close()
+
+	proxyReader := progress.NewProxyReader(reader)
+
+	if o.digest != "" {
+		// the registry verifies the content matches the digest
+		err = o.client.PushBlob(o.repository, o.digest, int64(size), proxyReader)
+		if err != nil {
+			return errors.Wrap(err, "Uploading blob")
+		}
+
+		return nil
+	}
+
+	// the digest must be known before uploading so content is spooled first
+	tmpfile, err := ioutil.TempFile("", "metalink-oci-blob")
+	if err != nil {
+		return errors.Wrap(err, "Creating temp file")
+	}
+
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	hash := sha256.New()
+
+	written, err := io.Copy(io.MultiWriter(tmpfile, hash), proxyReader)
+	if err != nil {
+		return errors.Wrap(err, "Reading from")
+	}
+
+	_, err = tmpfile.Seek(0, io.SeekStart)
+	if err != nil {
+		return errors.Wrap(err, "Rewinding temp file")
+	}
+
+	layer := oci.Descriptor{
+		MediaType: MediaTypeFileBlob,
+		Digest:    fmt.Sprintf("sha256:%x", hash.Sum(nil)),
+		Size:      written,
+		Annotations: map[string]string{
+			oci.AnnotationTitle: o.name,
+		},
+	}
+
+	err = o.client.PushBlob(o.repository, layer.Digest, layer.Size, tmpfile)
+	if err != nil {
+		return errors.Wrap(err, "Uploading blob")
+	}
+
+	return o.tagLayer(layer)
+}
+
+// tagLayer adds (or replaces) the layer of the file in the tagged artifact.
+func (o Reference) tagLayer(layer oci.Descriptor) error {
+	manifest, err := o.client.GetManifest(o.repository, o.tag)
+	if err == oci.ErrNotFound {
+		configDigest := oci.Digest(oci.EmptyJSON)
+
+		err = o.client.PushBlob(o.repository, configDigest, int64(len(oci.EmptyJSON)), bytes.NewReader(oci.EmptyJSON))
+		if err != nil {
+			return errors.Wrap(err, "Uploading config")
+		}
+
+		manifest = oci.Manifest{
+			SchemaVersion: 2,
+			MediaType:     oci.MediaTypeImageManifest,
+			ArtifactType:  ArtifactType,
+			Config: oci.Descriptor{
+				MediaType: oci.MediaTypeEmptyJSON,
+				Digest:    configDigest,
+				Size:      int64(len(oci.EmptyJSON)),
+			},
+		}
+	} else if err != nil {
+		return errors.Wrap(err, "Getting manifest")
+	}
+
+	var layers []oci.Descriptor
+
+	for _, existing := range manifest.Layers {
+		if existing.Annotations[oci.AnnotationTitle] != o.name {
+			layers = append(layers, existing)
+		}
+	}
+
+	manifest.Layers = append(layers, layer)
+
+	_, err = o.client.PutManifest(o.repository, o.tag, manifest)
+	if err != nil {
+		return errors.Wrap(err, "Tagging manifest")
+	}
+
+	return nil
+}
+
+func (o Reference) layer() (oci.Descriptor, error) {
+	manifest, err := o.client.GetManifest(o.repository, o.tag)
+	if err != nil {
+		return oci.Descriptor{}, errors.Wrap(err, "Getting manifest")
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.Annotations[oci.AnnotationTitle] == o.name {
+			return layer, nil
+		}
+	}
+
+	return oci.Descriptor{}, fmt.Errorf("File not found in manifest: %s", o.name)
+}
@@ -0,0 +1,61 @@
+package s3
+
+import (
+	neturl "net/url"
+	"strings"
+
+	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/internal/presign"
+	"github.com/dpb587/metalink-repository-resource/internal/s3"
+	"github.com/dpb587/metalink/file"
+	"github.com/dpb587/metalink/file/url"
+	"github.com/pkg/errors"
+)
+
+type loader struct {
+	options s3.Options
+}
+
+var _ url.Loader = &loader{}
+
+func NewLoader(options s3.Options) url.Loader {
+	return &loader{
+		options: options,
+	}
+}
+
+func (l loader) SupportsURL(source metalink.URL) bool {
+	parsed, err := neturl.Parse(source.URL)
+	if err != nil {
+		return false
+	}
+
+	if presign.IsPresigned(source.URL) {
+		// downloadable without credentials
+		return false
+	} else if parsed.Scheme == "s3" || s3.IsEndpoint(parsed.Hostname()) {
+		return true
+	}
+
+	// reader URIs of a custom endpoint
+	return l.options.Endpoint != "" && strings.HasPrefix(source.URL, strings.TrimSuffix(l.options.Endpoint, "/")+"/")
+}
+
+func (l loader) LoadURL(source metalink.URL) (file.Reference, error) {
+	parsed, err := neturl.Parse(source.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing URI")
+	}
+
+	location, err := s3.ParseLocation(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s3.NewClient(location, l.options)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReference(client, location, l.options), nil
+}
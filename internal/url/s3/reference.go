@@ -0,0 +1,98 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/cheggaaa/pb"
+	"github.com/dpb587/metalink-repository-resource/internal/presign"
+	"github.com/dpb587/metalink-repository-resource/internal/s3"
+	"github.com/dpb587/metalink/file"
+	minio "github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+type Reference struct {
+	client   *minio.Client
+	location s3.Location
+	options  s3.Options
+}
+
+var _ file.Reference = Reference{}
+var _ presign.Presigner = Reference{}
+
+func NewReference(client *minio.Client, location s3.Location, options s3.Options) Reference {
+	return Reference{
+		client:   client,
+		location: location,
+		options:  options,
+	}
+}
+
+func (o Reference) Name() (string, error) {
+	return filepath.Base(o.location.Key), nil
+}
+
+func (o Reference) Size() (uint64, error) {
+	info, err := o.client.StatObject(context.Background(), o.location.Bucket, o.location.Key, minio.StatObjectOptions{})
+	if err != nil {
+		return 0, errors.Wrap(err, "Getting object stat")
+	}
+
+	return uint64(info.Size), nil
+}
+
+func (o Reference) Reader() (io.ReadCloser, error) {
+	reader, err := o.client.GetObject(context.Background(), o.location.Bucket, o.location.Key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Opening for reading")
+	}
+
+	return reader, nil
+}
+
+func (o Reference) ReaderURI() string {
+	return s3.ReaderURI(o.location, o.options, o.location.Key)
+}
+
+// PresignedURL returns a path-style URL (which the loader recognizes when
+// refreshing) for downloading the object without credentials.
+func (o Reference) PresignedURL(expiry time.Duration) (string, error) {
+	options := o.options
+	options.PathStyle = true
+
+	client, err := s3.NewClient(o.location, options)
+	if err != nil {
+		return "", err
+	}
+
+	presigned, err := client.PresignedGetObject(context.Background(), o.location.Bucket, o.location.Key, expiry, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "Presigning")
+	}
+
+	return presigned.String(), nil
+}
+
+func (o Reference) WriteFrom(from file.Reference, progress *pb.ProgressBar) error {
+	size, err := from.Size()
+	if err != nil {
+		return errors.Wrap(err, "Checking size")
+	}
+
+	reader, err := from.Reader()
+	if err != nil {
+		return errors.Wrap(err, "Opening from")
+	}
+
+	defer reader.Close()
+
+	_, err = o.client.PutObject(context.Background(), o.location.Bucket, o.location.Key, progress.NewProxyReader(reader), int64(size), o.options.PutObjectOptions())
+	if err != nil {
+		return errors.Wrap(err, "Uploading")
+	}
+
+	return nil
+}
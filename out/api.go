@@ -16,6 +16,11 @@ type Params struct {
 	Rename         string                 `json:"rename,omitempty"`
 	RenameFromFile string                 `json:"rename_from_file,omitempty"`
 	Options        map[string]interface{} `json:"options,omitempty"`
+
+	Transfer api.TransferParams `json:"transfer,omitempty"`
+
+	FromSource *api.Source `json:"from_source,omitempty"`
+	FromVerify bool        `json:"from_verify,omitempty"`
 }
 
 type Response struct {
@@ -6,20 +6,18 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
-	"text/template"
 	"time"
 
-	"github.com/cheggaaa/pb"
 	"github.com/dpb587/metalink"
 	"github.com/dpb587/metalink-repository-resource/api"
 	"github.com/dpb587/metalink-repository-resource/factory"
-	metalinktemplate "github.com/dpb587/metalink/template"
-	"github.com/dpb587/metalink/verification"
-	"github.com/dpb587/metalink/verification/hash"
+	"github.com/dpb587/metalink-repository-resource/internal/fetch"
+	"github.com/dpb587/metalink-repository-resource/internal/publish"
+	"github.com/dpb587/metalink/repository"
 	"github.com/pkg/errors"
 )
 
@@ -36,12 +34,37 @@ func main() {
 		api.Fatal("out: bad stdin: parse error", err)
 	}
 
+	err = api.ResolveSource(&request.Source)
+	if err != nil {
+		api.Fatal("out: bad stdin: source", err)
+	}
+
+	if request.Params.FromSource != nil {
+		err = api.ResolveSource(request.Params.FromSource)
+		if err != nil {
+			api.Fatal("out: bad stdin: from_source", err)
+		}
+	}
+
 	api.MigrateSource(&request.Source)
 
 	var metalinkPath string
 	var localCache = map[string]string{}
 
-	if len(request.Params.Files) > 0 {
+	if request.Params.FromSource != nil {
+		var downloadDir string
+
+		metalinkPath, localCache, downloadDir, err = promoteMetalink(request)
+		if err != nil {
+			api.Fatal("out: promote metalink", err)
+		}
+
+		defer os.Remove(metalinkPath)
+
+		if downloadDir != "" {
+			defer os.RemoveAll(downloadDir)
+		}
+	} else if len(request.Params.Files) > 0 {
 		metalinkPath, localCache, err = createMetalink(request)
 		if err != nil {
 			api.Fatal("out: create metalink", err)
@@ -82,7 +105,12 @@ func main() {
 	var metalinkFile io.Reader
 
 	if len(request.Source.MirrorFiles) > 0 {
-		meta4, err = mirrorMetalink(request, meta4, localCache)
+		urlLoader, err := factory.GetURLLoader(request.Source.URLHandlers, request.Source.Transfer.Merge(request.Params.Transfer))
+		if err != nil {
+			api.Fatal("out: loading url handlers", err)
+		}
+
+		meta4, err = publish.MirrorMetalink(urlLoader, request.Source.MirrorFiles, meta4, localCache)
 		if err != nil {
 			api.Fatal("out: mirroring", err)
 		}
@@ -117,25 +145,14 @@ func main() {
 		}
 		metalinkName = string(metalinkNameBytes)
 	} else {
-		metalinkName = "v{{ .Version }}.meta4"
-	}
-
-	metalinkNameTmpl, err := template.New("metalink").Parse(metalinkName)
-	if err != nil {
-		api.Fatal("out: parsing metalink name", err)
+		metalinkName = publish.DefaultMetalinkName
 	}
 
-	metalinkNameBytes := &bytes.Buffer{}
-
-	err = metalinkNameTmpl.Execute(metalinkNameBytes, map[string]string{
-		"Version": meta4.Files[0].Version,
-	})
+	metalinkName, err = publish.RenderMetalinkName(metalinkName, meta4.Files[0].Version)
 	if err != nil {
 		api.Fatal("out: executing metalink name template", err)
 	}
 
-	metalinkName = metalinkNameBytes.String()
-
 	options := request.Source.Options
 
 	for k, v := range request.Params.Options {
@@ -152,182 +169,151 @@ func main() {
 		api.Fatal("out: storing metalink", err)
 	}
 
-	err = json.NewEncoder(os.Stdout).Encode(Response{Version: api.Version{Version: meta4.Files[0].Version}})
+	response := Response{Version: api.Version{Version: meta4.Files[0].Version}}
+
+	if request.Params.FromSource != nil && meta4.Origin != nil {
+		response.Metadata = append(response.Metadata, api.Metadata{Name: "origin", Value: meta4.Origin.URL})
+	}
+
+	if pullRequestURL := publish.PullRequestURL(repository); pullRequestURL != "" {
+		response.Metadata = append(response.Metadata, api.Metadata{Name: "pull_request", Value: pullRequestURL})
+	}
+
+	err = json.NewEncoder(os.Stdout).Encode(response)
 	if err != nil {
 		api.Fatal("out: bad stdout: json", err)
 	}
 }
 
-func createMetalink(request Request) (string, map[string]string, error) {
-	now := time.Now()
-	meta4 := metalink.Metalink{
-		Generator: "metalink-repository-resource/0.0.0",
-		Published: &now,
-	}
-	localCache := map[string]string{}
-
+func readVersion(request Request) (string, error) {
 	versionBytes, err := ioutil.ReadFile(request.Params.Version)
 	if err != nil {
-		return "", nil, errors.Wrap(err, "reading version")
+		return "", errors.Wrap(err, "reading version")
 	}
 
-	version := strings.TrimSpace(string(versionBytes))
-
-	urlLoader := factory.GetURLLoader(request.Source.URLHandlers)
-
-	for _, paramFile := range request.Params.Files {
-		filePaths, err := filepath.Glob(paramFile)
-		if err != nil {
-			return "", nil, errors.Wrap(err, "globbing path")
-		}
-
-		for _, filePath := range filePaths {
-			filepathAbs, err := filepath.Abs(filePath)
-			if err != nil {
-				return "", nil, errors.Wrap(err, "finding absolute path")
-			}
-
-			file := metalink.File{
-				Name:    path.Base(filePath),
-				Version: version,
-				Hashes:  []metalink.Hash{},
-			}
-
-			localCache[file.Name] = fmt.Sprintf("file://%s", filepathAbs)
-
-			local, err := urlLoader.LoadURL(metalink.URL{URL: localCache[file.Name]})
-			if err != nil {
-				return "", nil, errors.Wrap(err, "loading local file")
-			}
-
-			file.Size, err = local.Size()
-			if err != nil {
-				return "", nil, errors.Wrap(err, "getting size")
-			}
-
-			hashmap := []verification.Signer{
-				hash.SHA512SignerVerifier,
-				hash.SHA256SignerVerifier,
-				hash.SHA1SignerVerifier,
-				hash.MD5SignerVerifier,
-			}
-
-			for _, hasher := range hashmap {
-				verification, err := hasher.Sign(local)
-				if err != nil {
-					return "", nil, errors.Wrap(err, "building hash")
-				}
-
-				err = verification.Apply(&file)
-				if err != nil {
-					return "", nil, errors.Wrap(err, "adding hash")
-				}
-			}
+	return strings.TrimSpace(string(versionBytes)), nil
+}
 
-			meta4.Files = append(meta4.Files, file)
-		}
+func createMetalink(request Request) (string, map[string]string, error) {
+	version, err := readVersion(request)
+	if err != nil {
+		return "", nil, err
 	}
 
-	meta4Bytes, err := metalink.MarshalXML(meta4)
+	urlLoader, err := factory.GetURLLoader(request.Source.URLHandlers, request.Source.Transfer.Merge(request.Params.Transfer))
 	if err != nil {
-		return "", nil, errors.Wrap(err, "marshaling metalink")
+		return "", nil, errors.Wrap(err, "loading url handlers")
 	}
 
-	tmpfile, err := ioutil.TempFile("", "metalink-repository")
+	meta4, localCache, err := publish.CreateMetalink(urlLoader, version, request.Params.Files)
 	if err != nil {
-		return "", nil, errors.Wrap(err, "creating temp file")
+		return "", nil, err
 	}
 
-	_, err = tmpfile.Write(meta4Bytes)
+	metalinkPath, err := writeMetalink(meta4)
 	if err != nil {
-		os.Remove(tmpfile.Name())
-
-		return "", nil, errors.Wrap(err, "writing metalink")
+		return "", nil, err
 	}
 
-	return tmpfile.Name(), localCache, nil
+	return metalinkPath, localCache, nil
 }
 
-func mirrorMetalink(request Request, meta4 metalink.Metalink, localCache map[string]string) (metalink.Metalink, error) {
-	urlLoader := factory.GetURLLoader(request.Source.URLHandlers)
+// promoteMetalink copies a metalink from another repository, recording where it
+// came from. When verifying, files are downloaded so that mirroring uploads the
+// verified copies.
+func promoteMetalink(request Request) (string, map[string]string, string, error) {
+	fromSource := *request.Params.FromSource
+	api.MigrateSource(&fromSource)
 
-	for fileIdx, file := range meta4.Files {
-		// TODO support multiple URLs
-		localURI, isLocal := localCache[file.Name]
-		if !isLocal {
-			if len(file.URLs) < 1 {
-				return meta4, errors.New("file is missing url")
-			}
+	version, err := readVersion(request)
+	if err != nil {
+		return "", nil, "", err
+	}
 
-			localURI = file.URLs[0].URL
-		}
+	fromMeta4, err := fetch.FindMetalink(fromSource, version)
+	if err != nil {
+		return "", nil, "", errors.Wrap(err, "finding metalink")
+	}
+
+	meta4 := fromMeta4.Metalink
+	localCache := map[string]string{}
+
+	var downloadDir string
 
-		local, err := urlLoader.LoadURL(metalink.URL{URL: localURI})
+	if request.Params.FromVerify {
+		downloadDir, err = ioutil.TempDir("", "metalink-repository-promote")
 		if err != nil {
-			return meta4, errors.Wrap(err, "loading local file")
+			return "", nil, "", errors.Wrap(err, "creating temp dir")
 		}
 
-		for _, uploadParams := range request.Source.MirrorFiles {
-			remoteURLTmpl, err := metalinktemplate.New(uploadParams.Destination)
-			if err != nil {
-				return meta4, errors.Wrap(err, "parsing upload destination")
-			}
+		_, err = fetch.Fetch(fromSource, meta4, downloadDir, fetch.Options{Transfer: request.Params.Transfer})
+		if err != nil {
+			os.RemoveAll(downloadDir)
 
-			remoteURL, err := remoteURLTmpl.ExecuteString(file)
-			if err != nil {
-				return meta4, errors.Wrap(err, "generating upload destination")
-			}
+			return "", nil, "", errors.Wrap(err, "verifying files")
+		}
 
-			var uri string
-			var uploadError error
+		for _, file := range meta4.Files {
+			localPath := filepath.Join(downloadDir, file.Name)
 
-			for retry := 1; retry <= 3; retry++ {
-				uploadError = nil
+			// files may have been excluded by the include/exclude settings
+			if _, err := os.Stat(localPath); err == nil {
+				localCache[file.Name] = localPath
+			}
+		}
+	}
 
-				if retry > 1 {
-					fmt.Fprintf(os.Stderr, "\nretrying (attempt #%d)...\n", retry)
-				}
+	now := time.Now()
+	meta4.Updated = &now
+	meta4.Origin = &metalink.Origin{
+		URL: provenanceURL(fromMeta4.Reference),
+	}
 
-				fmt.Fprintf(os.Stderr, "uploading to %s\n", remoteURL)
+	metalinkPath, err := writeMetalink(meta4)
+	if err != nil {
+		if downloadDir != "" {
+			os.RemoveAll(downloadDir)
+		}
 
-				remote, err := urlLoader.LoadURL(metalink.URL{URL: remoteURL})
-				if err != nil {
-					return meta4, errors.Wrap(err, "loading upload destination")
-				}
+		return "", nil, "", err
+	}
 
-				uri = remote.ReaderURI()
+	return metalinkPath, localCache, downloadDir, nil
+}
 
-				progress := pb.New64(int64(file.Size)).Set(pb.Bytes, true).SetRefreshRate(time.Second).SetWidth(80)
-				progress.Start()
+// provenanceURL references the metalink in its original repository without
+// any credentials of the repository URI.
+func provenanceURL(ref repository.RepositoryMetalinkReference) string {
+	repo := ref.Repository
 
-				err = remote.WriteFrom(local, progress)
-				progress.Finish()
+	parsed, err := url.Parse(repo)
+	if err == nil && parsed.User != nil {
+		parsed.User = nil
+		repo = parsed.String()
+	}
 
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "uploading failed: %v\n", err)
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(repo, "/"), ref.Path)
+}
 
-					uploadError = err
+func writeMetalink(meta4 metalink.Metalink) (string, error) {
+	meta4Bytes, err := metalink.MarshalXML(meta4)
+	if err != nil {
+		return "", errors.Wrap(err, "marshaling metalink")
+	}
 
-					continue
-				}
+	tmpfile, err := ioutil.TempFile("", "metalink-repository")
+	if err != nil {
+		return "", errors.Wrap(err, "creating temp file")
+	}
 
-				break
-			}
+	defer tmpfile.Close()
 
-			if uploadError != nil {
-				return meta4, errors.Wrap(uploadError, "uploading")
-			}
+	_, err = tmpfile.Write(meta4Bytes)
+	if err != nil {
+		os.Remove(tmpfile.Name())
 
-			meta4.Files[fileIdx].URLs = append(
-				meta4.Files[fileIdx].URLs,
-				metalink.URL{
-					Location: uploadParams.Location,
-					Priority: uploadParams.Priority,
-					URL:      uri,
-				},
-			)
-		}
+		return "", errors.Wrap(err, "writing metalink")
 	}
 
-	return meta4, nil
+	return tmpfile.Name(), nil
 }
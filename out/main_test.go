@@ -2,18 +2,26 @@ package main_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/dpb587/metalink"
+	"github.com/dpb587/metalink-repository-resource/internal/oci"
+	pkgtesting "github.com/dpb587/metalink-repository-resource/internal/testing"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/gexec"
 )
 
@@ -37,6 +45,19 @@ var _ = Describe("Main", func() {
 		return result
 	}
 
+	runCLIFailure := func(stdin string) *gexec.Session {
+		command := exec.Command(cli, os.TempDir())
+		command.Stdin = bytes.NewBufferString(stdin)
+
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		session.Wait(time.Minute)
+		Expect(session.ExitCode()).NotTo(Equal(0))
+
+		return session
+	}
+
 	var versionfile, metalinkfile, mirrorDir string
 
 	BeforeEach(func() {
@@ -187,4 +208,993 @@ var _ = Describe("Main", func() {
 			})
 		})
 	})
+
+	Describe("promoting metalinks", func() {
+		var candidatesDir, storageDir string
+
+		BeforeEach(func() {
+			var err error
+
+			candidatesDir, err = ioutil.TempDir("", "metalink-repository-resource-candidates")
+			Expect(err).NotTo(HaveOccurred())
+
+			storageDir, err = ioutil.TempDir("", "metalink-repository-resource-storage")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ioutil.WriteFile(path.Join(storageDir, "candidate.txt"), []byte("a candidate"), 0644)).To(Succeed())
+
+			Expect(ioutil.WriteFile(path.Join(candidatesDir, "v2.1.0.meta4"), []byte(fmt.Sprintf(`{"files":[{"name":"candidate.txt","version":"2.1.0","size":11,"hashes":[{"type":"sha-512","hash":"%x"}],"urls":[{"url":"file://%s/candidate.txt"}]}]}`, sha512.Sum512([]byte("a candidate")), storageDir)), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(candidatesDir, "v2.2.0.meta4"), []byte(`{"files":[{"name":"other.txt","version":"2.2.0"}]}`), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(candidatesDir)).NotTo(HaveOccurred())
+			Expect(os.RemoveAll(storageDir)).NotTo(HaveOccurred())
+		})
+
+		It("copies the metalink with its origin", func() {
+			result := runCLI(fmt.Sprintf(`{
+		"source": {
+			"uri": "file://%s/component"
+		},
+		"params": {
+			"version": "%s",
+			"from_source": {
+				"uri": "file://%s"
+			}
+		}
+	}`, repositorydir, versionfile, candidatesDir))
+			Expect(result["version"].(map[string]interface{})["version"]).To(Equal("2.1.0"))
+			Expect(result["metadata"]).To(ConsistOf(map[string]interface{}{
+				"name":  "origin",
+				"value": fmt.Sprintf("file://%s/v2.1.0.meta4", candidatesDir),
+			}))
+
+			meta4Bytes, err := ioutil.ReadFile(path.Join(repositorydir, "component/v2.1.0.meta4"))
+			Expect(err).NotTo(HaveOccurred())
+
+			var meta4 metalink.Metalink
+
+			Expect(metalink.Unmarshal(meta4Bytes, &meta4)).NotTo(HaveOccurred())
+			Expect(meta4.Origin).NotTo(BeNil())
+			Expect(meta4.Origin.URL).To(Equal(fmt.Sprintf("file://%s/v2.1.0.meta4", candidatesDir)))
+			Expect(meta4.Updated).NotTo(BeNil())
+			Expect(meta4.Files).To(HaveLen(1))
+			Expect(meta4.Files[0].URLs).To(HaveLen(1))
+			Expect(meta4.Files[0].URLs[0].URL).To(Equal(fmt.Sprintf("file://%s/candidate.txt", storageDir)))
+		})
+
+		It("verifies and mirrors files", func() {
+			runCLI(fmt.Sprintf(`{
+		"source": {
+			"uri": "file://%s/component",
+			"mirror_files": [
+				{
+					"destination": "file://%s/{{.Name}}"
+				}
+			]
+		},
+		"params": {
+			"version": "%s",
+			"from_source": {
+				"uri": "file://%s"
+			},
+			"from_verify": true
+		}
+	}`, repositorydir, mirrorDir, versionfile, candidatesDir))
+
+			mirroredBytes, err := ioutil.ReadFile(path.Join(mirrorDir, "candidate.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mirroredBytes).To(Equal([]byte("a candidate")))
+
+			meta4Bytes, err := ioutil.ReadFile(path.Join(repositorydir, "component/v2.1.0.meta4"))
+			Expect(err).NotTo(HaveOccurred())
+
+			var meta4 metalink.Metalink
+
+			Expect(metalink.Unmarshal(meta4Bytes, &meta4)).NotTo(HaveOccurred())
+			Expect(meta4.Files[0].URLs).To(HaveLen(2))
+			Expect(meta4.Files[0].URLs[1].URL).To(Equal(fmt.Sprintf("file://%s/candidate.txt", mirrorDir)))
+		})
+
+		It("fails when files cannot be verified", func() {
+			Expect(ioutil.WriteFile(path.Join(storageDir, "candidate.txt"), []byte("a tampered!"), 0644)).To(Succeed())
+
+			session := runCLIFailure(fmt.Sprintf(`{
+		"source": {
+			"uri": "file://%s/component"
+		},
+		"params": {
+			"version": "%s",
+			"from_source": {
+				"uri": "file://%s"
+			},
+			"from_verify": true
+		}
+	}`, repositorydir, versionfile, candidatesDir))
+			Expect(session.Err).To(gbytes.Say("out: promote metalink: verifying files"))
+
+			Expect(path.Join(repositorydir, "component/v2.1.0.meta4")).NotTo(BeAnExistingFile())
+		})
+
+		It("fails when the version does not exist", func() {
+			Expect(ioutil.WriteFile(versionfile, []byte("9.9.9"), 0644)).To(Succeed())
+
+			runCLIFailure(fmt.Sprintf(`{
+		"source": {
+			"uri": "file://%s/component"
+		},
+		"params": {
+			"version": "%s",
+			"from_source": {
+				"uri": "file://%s"
+			}
+		}
+	}`, repositorydir, versionfile, candidatesDir))
+		})
+	})
+
+	Describe("mirroring to oci registries", func() {
+		var registry *pkgtesting.Registry
+		var importFile string
+
+		BeforeEach(func() {
+			registry = pkgtesting.NewRegistry()
+			registry.Username = "fake-user"
+			registry.Password = "fake-password"
+
+			importFile = path.Join(mirrorDir, "blob.txt")
+			Expect(ioutil.WriteFile(importFile, []byte("an oci blob"), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			registry.Close()
+		})
+
+		It("pushes blobs by digest and tag", func() {
+			runCLI(fmt.Sprintf(`{
+		"source": {
+			"uri": "file://%s/component",
+			"url_handlers": [
+				{
+					"type": "oci",
+					"options": {
+						"username": "fake-user",
+						"password": "fake-password",
+						"plain_http": true
+					}
+				}
+			],
+			"mirror_files": [
+				{
+					"destination": "oci://%s/team/blobs@sha256:{{.SHA256}}"
+				},
+				{
+					"destination": "oci://%s/team/blobs:v{{.Version}}/{{.Name}}"
+				}
+			]
+		},
+		"params": {
+			"version": "%s",
+			"files": [
+				"%s"
+			]
+		}
+	}`, repositorydir, registry.Host(), registry.Host(), versionfile, importFile))
+
+			digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("an oci blob")))
+
+			meta4Bytes, err := ioutil.ReadFile(path.Join(repositorydir, "component/v2.1.0.meta4"))
+			Expect(err).NotTo(HaveOccurred())
+
+			var meta4 metalink.Metalink
+
+			Expect(metalink.Unmarshal(meta4Bytes, &meta4)).NotTo(HaveOccurred())
+			Expect(meta4.Files[0].URLs).To(HaveLen(2))
+			Expect(meta4.Files[0].URLs[0].URL).To(Equal(fmt.Sprintf("oci://%s/team/blobs@%s", registry.Host(), digest)))
+			Expect(meta4.Files[0].URLs[1].URL).To(Equal(fmt.Sprintf("oci://%s/team/blobs:v2.1.0/blob.txt", registry.Host())))
+
+			client := oci.NewClient(http.DefaultClient, registry.Host(), oci.Options{Username: "fake-user", Password: "fake-password", PlainHTTP: true})
+
+			reader, _, err := client.GetBlob("team/blobs", digest)
+			Expect(err).NotTo(HaveOccurred())
+
+			blobBytes, err := ioutil.ReadAll(reader)
+			reader.Close()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(blobBytes)).To(Equal("an oci blob"))
+
+			manifest, err := client.GetManifest("team/blobs", "v2.1.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.Layers).To(HaveLen(1))
+			Expect(manifest.Layers[0].Digest).To(Equal(digest))
+			Expect(manifest.Layers[0].Annotations).To(HaveKeyWithValue("org.opencontainers.image.title", "blob.txt"))
+		})
+
+		It("accepts options of the handler as strings", func() {
+			runCLI(fmt.Sprintf(`{
+		"source": {
+			"uri": "file://%s/component",
+			"url_handlers": [
+				{
+					"type": "oci",
+					"options": {
+						"username": "fake-user",
+						"password": "fake-password",
+						"plain_http": "true"
+					}
+				}
+			],
+			"mirror_files": [
+				{
+					"destination": "oci://%s/team/blobs@sha256:{{.SHA256}}"
+				}
+			]
+		},
+		"params": {
+			"version": "%s",
+			"files": [
+				"%s"
+			]
+		}
+	}`, repositorydir, registry.Host(), versionfile, importFile))
+
+			meta4Bytes, err := ioutil.ReadFile(path.Join(repositorydir, "component/v2.1.0.meta4"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(meta4Bytes)).To(ContainSubstring(fmt.Sprintf("oci://%s/team/blobs@sha256:", registry.Host())))
+		})
+
+		It("fails with invalid options of the handler", func() {
+			session := runCLIFailure(fmt.Sprintf(`{
+		"source": {
+			"uri": "file://%s/component",
+			"url_handlers": [
+				{
+					"type": "oci",
+					"options": {
+						"plain_http": "maybe"
+					}
+				}
+			],
+			"mirror_files": [
+				{
+					"destination": "oci://%s/team/blobs@sha256:{{.SHA256}}"
+				}
+			]
+		},
+		"params": {
+			"version": "%s",
+			"files": [
+				"%s"
+			]
+		}
+	}`, repositorydir, registry.Host(), versionfile, importFile))
+
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Err).To(gbytes.Say("parsing oci handler options: option plain_http: expected boolean"))
+		})
+	})
+
+	Describe("s3 repositories", func() {
+		var s3 *pkgtesting.S3
+		var importFile, inDir string
+		var connection, mirrorFile map[string]interface{}
+
+		BeforeEach(func() {
+			s3 = pkgtesting.NewS3("metalinks", "blobs")
+			s3.AccessKey = "fake-access-key"
+			s3.SessionToken = "fake-session-token"
+
+			connection = map[string]interface{}{
+				"endpoint":      s3.URL,
+				"region":        "us-east-1",
+				"path_style":    true,
+				"ca_cert":       pkgtesting.CACertificate(s3.Server),
+				"access_key":    "fake-access-key",
+				"secret_key":    "fake-secret-key",
+				"session_token": "fake-session-token",
+			}
+
+			mirrorFile = map[string]interface{}{
+				"destination": "s3://s3.example.internal/blobs/{{.Version}}/{{.Name}}",
+			}
+
+			importFile = path.Join(mirrorDir, "blob.txt")
+			Expect(ioutil.WriteFile(importFile, []byte("an s3 blob"), 0644)).To(Succeed())
+
+			var err error
+
+			inDir, err = ioutil.TempDir("", "metalink-repository-resource-in")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			s3.Close()
+			Expect(os.RemoveAll(inDir)).To(Succeed())
+		})
+
+		withOptions := func(options map[string]interface{}) map[string]interface{} {
+			merged := map[string]interface{}{}
+
+			for k, v := range connection {
+				merged[k] = v
+			}
+
+			for k, v := range options {
+				merged[k] = v
+			}
+
+			return merged
+		}
+
+		sourceJSON := func(sourceOptions, handlerOptions map[string]interface{}) string {
+			source, err := json.Marshal(map[string]interface{}{
+				"uri":     "s3://s3.example.internal/metalinks/component/",
+				"options": withOptions(sourceOptions),
+				"url_handlers": []interface{}{
+					map[string]interface{}{
+						"type":    "s3",
+						"options": withOptions(handlerOptions),
+					},
+				},
+				"mirror_files": []interface{}{mirrorFile},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			return string(source)
+		}
+
+		startResource := func(cli string, args []string, env []string, stdin string) (*gexec.Session, *bytes.Buffer) {
+			command := exec.Command(cli, args...)
+			command.Env = append(os.Environ(), env...)
+			command.Stdin = bytes.NewBufferString(stdin)
+
+			stdout := &bytes.Buffer{}
+
+			session, err := gexec.Start(command, stdout, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			session.Wait(time.Minute)
+
+			return session, stdout
+		}
+
+		runResource := func(cli string, args []string, env []string, stdin string) []byte {
+			session, stdout := startResource(cli, args, env, stdin)
+			Expect(session.ExitCode()).To(Equal(0))
+
+			return stdout.Bytes()
+		}
+
+		It("publishes, checks and gets versions through a custom endpoint", func() {
+			source := sourceJSON(
+				map[string]interface{}{
+					"sse":        "aws:kms",
+					"kms_key_id": "fake-key-id",
+					"tags":       map[string]interface{}{"team": "releng"},
+				},
+				map[string]interface{}{
+					"sse":           "AES256",
+					"storage_class": "STANDARD_IA",
+					"acl":           "bucket-owner-full-control",
+					"cache_control": "max-age=31536000",
+				},
+			)
+
+			result := runCLI(fmt.Sprintf(`{"source": %s, "params": {"version": "%s", "files": ["%s"]}}`, source, versionfile, importFile))
+			Expect(result["version"]).To(Equal(map[string]interface{}{"version": "2.1.0"}))
+
+			meta4, found := s3.Object("metalinks", "component/v2.1.0.meta4")
+			Expect(found).To(BeTrue())
+			Expect(meta4.Header.Get("X-Amz-Server-Side-Encryption")).To(Equal("aws:kms"))
+			Expect(meta4.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")).To(Equal("fake-key-id"))
+			Expect(meta4.Header.Get("X-Amz-Tagging")).To(Equal("team=releng"))
+			Expect(string(meta4.Data)).To(ContainSubstring(fmt.Sprintf("<url>%s/blobs/2.1.0/blob.txt</url>", s3.URL)))
+
+			blob, found := s3.Object("blobs", "2.1.0/blob.txt")
+			Expect(found).To(BeTrue())
+			Expect(string(blob.Data)).To(Equal("an s3 blob"))
+			Expect(blob.Header.Get("X-Amz-Server-Side-Encryption")).To(Equal("AES256"))
+			Expect(blob.Header.Get("X-Amz-Storage-Class")).To(Equal("STANDARD_IA"))
+			Expect(blob.Header.Get("X-Amz-Acl")).To(Equal("bucket-owner-full-control"))
+			Expect(blob.Header.Get("Cache-Control")).To(Equal("max-age=31536000"))
+
+			check := runResource(checkCLI, nil, nil, fmt.Sprintf(`{"source": %s}`, source))
+			Expect(string(check)).To(MatchJSON(`[{"version": "2.1.0"}]`))
+
+			runResource(inCLI, []string{inDir}, nil, fmt.Sprintf(`{"source": %s, "version": {"version": "2.1.0"}}`, source))
+
+			inBytes, err := ioutil.ReadFile(path.Join(inDir, "blob.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(inBytes)).To(Equal("an s3 blob"))
+		})
+
+		Describe("credential chains", func() {
+			var sts *pkgtesting.STS
+			var publishJSON string
+
+			BeforeEach(func() {
+				sts = pkgtesting.NewSTS()
+				sts.AccessKey = "assumed-access-key"
+				sts.SecretKey = "assumed-secret-key"
+				sts.SessionToken = "assumed-session-token"
+
+				delete(connection, "access_key")
+				delete(connection, "secret_key")
+				delete(connection, "session_token")
+				connection["credential_chain"] = true
+				connection["sts_endpoint"] = sts.URL
+
+				s3.AccessKey = "assumed-access-key"
+				s3.SessionToken = "assumed-session-token"
+			})
+
+			JustBeforeEach(func() {
+				publishJSON = fmt.Sprintf(`{"source": %s, "params": {"version": "%s", "files": ["%s"]}}`, sourceJSON(nil, nil), versionfile, importFile)
+			})
+
+			AfterEach(func() {
+				sts.Close()
+			})
+
+			Context("with role_arn", func() {
+				BeforeEach(func() {
+					connection["role_arn"] = "arn:aws:iam::123456789012:role/releng"
+					connection["external_id"] = "fake-external-id"
+					connection["session_name"] = "fake-session-name"
+				})
+
+				It("assumes the role with credentials of the environment", func() {
+					runResource(cli, []string{os.TempDir()}, []string{
+						"AWS_ACCESS_KEY_ID=env-access-key",
+						"AWS_SECRET_ACCESS_KEY=env-secret-key",
+						"AWS_SESSION_TOKEN=env-session-token",
+						"AWS_SHARED_CREDENTIALS_FILE=/nonexistent",
+					}, publishJSON)
+
+					_, found := s3.Object("metalinks", "component/v2.1.0.meta4")
+					Expect(found).To(BeTrue())
+
+					requests := sts.Requests()
+					Expect(requests).NotTo(BeEmpty())
+
+					for _, request := range requests {
+						Expect(request).To(Equal(pkgtesting.STSRequest{
+							Action:          "AssumeRole",
+							RoleARN:         "arn:aws:iam::123456789012:role/releng",
+							RoleSessionName: "fake-session-name",
+							ExternalID:      "fake-external-id",
+							Credential:      "env-access-key",
+							SecurityToken:   "env-session-token",
+						}))
+					}
+				})
+			})
+
+			It("uses web identity tokens", func() {
+				tokenFile := path.Join(mirrorDir, "token")
+				Expect(ioutil.WriteFile(tokenFile, []byte("fake-web-identity-token"), 0600)).To(Succeed())
+
+				runResource(cli, []string{os.TempDir()}, []string{
+					"AWS_ACCESS_KEY_ID=",
+					"AWS_SECRET_ACCESS_KEY=",
+					"AWS_SHARED_CREDENTIALS_FILE=/nonexistent",
+					fmt.Sprintf("AWS_WEB_IDENTITY_TOKEN_FILE=%s", tokenFile),
+					"AWS_ROLE_ARN=arn:aws:iam::123456789012:role/irsa",
+				}, publishJSON)
+
+				_, found := s3.Object("metalinks", "component/v2.1.0.meta4")
+				Expect(found).To(BeTrue())
+
+				requests := sts.Requests()
+				Expect(requests).NotTo(BeEmpty())
+				Expect(requests[0].Action).To(Equal("AssumeRoleWithWebIdentity"))
+				Expect(requests[0].RoleARN).To(Equal("arn:aws:iam::123456789012:role/irsa"))
+				Expect(requests[0].WebIdentityToken).To(Equal("fake-web-identity-token"))
+			})
+
+			Context("with profile", func() {
+				BeforeEach(func() {
+					delete(connection, "credential_chain")
+					connection["profile"] = "releng"
+
+					s3.AccessKey = "profile-access-key"
+					s3.SessionToken = ""
+				})
+
+				It("uses credentials of the shared profile", func() {
+					credentialsFile := path.Join(mirrorDir, "credentials")
+					Expect(ioutil.WriteFile(credentialsFile, []byte("[default]\naws_access_key_id = default-access-key\naws_secret_access_key = default-secret-key\n\n[releng]\naws_access_key_id = profile-access-key\naws_secret_access_key = profile-secret-key\n"), 0600)).To(Succeed())
+
+					runResource(cli, []string{os.TempDir()}, []string{
+						"AWS_ACCESS_KEY_ID=env-access-key",
+						"AWS_SECRET_ACCESS_KEY=env-secret-key",
+						fmt.Sprintf("AWS_SHARED_CREDENTIALS_FILE=%s", credentialsFile),
+					}, publishJSON)
+
+					_, found := s3.Object("metalinks", "component/v2.1.0.meta4")
+					Expect(found).To(BeTrue())
+					Expect(sts.Requests()).To(BeEmpty())
+				})
+			})
+		})
+
+		Describe("presigned URLs", func() {
+			var publishJSON string
+			var trustEnv []string
+
+			BeforeEach(func() {
+				caFile := path.Join(mirrorDir, "ca.pem")
+				Expect(ioutil.WriteFile(caFile, []byte(pkgtesting.CACertificate(s3.Server)), 0644)).To(Succeed())
+
+				// presigned URLs are downloaded without the handler
+				trustEnv = []string{fmt.Sprintf("SSL_CERT_FILE=%s", caFile)}
+			})
+
+			JustBeforeEach(func() {
+				publishJSON = fmt.Sprintf(`{"source": %s, "params": {"version": "%s", "files": ["%s"]}}`, sourceJSON(nil, nil), versionfile, importFile)
+			})
+
+			publishedURLs := func() []string {
+				meta4Object, found := s3.Object("metalinks", "component/v2.1.0.meta4")
+				Expect(found).To(BeTrue())
+
+				var meta4 metalink.Metalink
+
+				Expect(metalink.Unmarshal(meta4Object.Data, &meta4)).To(Succeed())
+				Expect(meta4.Files).To(HaveLen(1))
+
+				var urls []string
+
+				for _, url := range meta4.Files[0].URLs {
+					urls = append(urls, url.URL)
+				}
+
+				return urls
+			}
+
+			Context("alongside destinations", func() {
+				BeforeEach(func() {
+					mirrorFile["presign"] = map[string]interface{}{"expiry": "1h"}
+				})
+
+				It("embeds both URLs", func() {
+					runCLI(publishJSON)
+
+					urls := publishedURLs()
+					Expect(urls).To(HaveLen(2))
+					Expect(urls[0]).To(Equal(fmt.Sprintf("%s/blobs/2.1.0/blob.txt", s3.URL)))
+					Expect(urls[1]).To(HavePrefix(fmt.Sprintf("%s/blobs/2.1.0/blob.txt?", s3.URL)))
+					Expect(urls[1]).To(ContainSubstring("X-Amz-Expires=3600"))
+				})
+			})
+
+			Context("replacing destinations", func() {
+				BeforeEach(func() {
+					mirrorFile["presign"] = map[string]interface{}{"expiry": "1h", "replace": true}
+				})
+
+				It("gets files without credentials", func() {
+					runCLI(publishJSON)
+
+					urls := publishedURLs()
+					Expect(urls).To(HaveLen(1))
+					Expect(urls[0]).To(ContainSubstring("X-Amz-Signature="))
+
+					connectionJSON, err := json.Marshal(connection)
+					Expect(err).NotTo(HaveOccurred())
+
+					inJSON := fmt.Sprintf(`{"source": {"uri": "s3://s3.example.internal/metalinks/component/", "options": %s}, "version": {"version": "2.1.0"}}`, connectionJSON)
+					runResource(inCLI, []string{inDir}, trustEnv, inJSON)
+
+					inBytes, err := ioutil.ReadFile(path.Join(inDir, "blob.txt"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(inBytes)).To(Equal("an s3 blob"))
+				})
+
+				It("does not upload again", func() {
+					runCLI(publishJSON)
+
+					metalinkfile := path.Join(mirrorDir, "published.meta4")
+					meta4Object, _ := s3.Object("metalinks", "component/v2.1.0.meta4")
+					Expect(ioutil.WriteFile(metalinkfile, meta4Object.Data, 0644)).To(Succeed())
+
+					session, _ := startResource(cli, []string{os.TempDir()}, nil, fmt.Sprintf(`{"source": %s, "params": {"metalink": "%s", "rename": "v2.1.1.meta4"}}`, sourceJSON(nil, nil), metalinkfile))
+					Expect(session.ExitCode()).To(Equal(0))
+					Expect(session.Err).NotTo(gbytes.Say("uploading to"))
+				})
+			})
+
+			Context("expired", func() {
+				BeforeEach(func() {
+					mirrorFile["presign"] = map[string]interface{}{"expiry": "1s", "replace": true}
+				})
+
+				JustBeforeEach(func() {
+					runCLI(publishJSON)
+
+					// expire the presigned URL
+					time.Sleep(1500 * time.Millisecond)
+				})
+
+				It("fails to get files", func() {
+					session, _ := startResource(inCLI, []string{inDir}, trustEnv, fmt.Sprintf(`{"source": %s, "version": {"version": "2.1.0"}}`, sourceJSON(nil, nil)))
+					Expect(session.ExitCode()).NotTo(Equal(0))
+				})
+
+				It("refreshes URLs through the handler", func() {
+					runResource(inCLI, []string{inDir}, trustEnv, fmt.Sprintf(`{"source": %s, "version": {"version": "2.1.0"}, "params": {"refresh_presigned_urls": true}}`, sourceJSON(nil, nil)))
+
+					inBytes, err := ioutil.ReadFile(path.Join(inDir, "blob.txt"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(inBytes)).To(Equal("an s3 blob"))
+
+					meta4Bytes, err := ioutil.ReadFile(path.Join(inDir, ".resource", "metalink.meta4"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(meta4Bytes)).To(ContainSubstring("X-Amz-Expires=1&amp;"))
+					Expect(string(meta4Bytes)).NotTo(ContainSubstring(publishedURLs()[0]))
+				})
+			})
+		})
+
+		It("skips certificate verification", func() {
+			delete(connection, "ca_cert")
+			connection["insecure_skip_verify"] = true
+
+			runCLI(fmt.Sprintf(`{"source": %s, "params": {"version": "%s", "files": ["%s"]}}`, sourceJSON(nil, nil), versionfile, importFile))
+
+			_, found := s3.Object("metalinks", "component/v2.1.0.meta4")
+			Expect(found).To(BeTrue())
+		})
+
+		It("fails when the endpoint certificate is not trusted", func() {
+			delete(connection, "ca_cert")
+
+			runCLIFailure(fmt.Sprintf(`{"source": %s, "params": {"version": "%s", "files": ["%s"]}}`, sourceJSON(nil, nil), versionfile, importFile))
+
+			_, found := s3.Object("metalinks", "component/v2.1.0.meta4")
+			Expect(found).To(BeFalse())
+		})
+
+		It("resolves options from files and the environment", func() {
+			secretKeyFile := path.Join(mirrorDir, "secret-key")
+			Expect(ioutil.WriteFile(secretKeyFile, []byte("fake-secret-key\n"), 0600)).To(Succeed())
+
+			delete(connection, "access_key")
+			delete(connection, "secret_key")
+			connection["access_key_env"] = "FAKE_S3_ACCESS_KEY"
+			connection["secret_key_file"] = secretKeyFile
+			connection["endpoint"] = "((env:FAKE_S3_ENDPOINT))"
+
+			env := []string{
+				"FAKE_S3_ACCESS_KEY=fake-access-key",
+				fmt.Sprintf("FAKE_S3_ENDPOINT=%s", s3.URL),
+			}
+
+			source := sourceJSON(nil, nil)
+
+			runResource(cli, []string{os.TempDir()}, env, fmt.Sprintf(`{"source": %s, "params": {"version": "%s", "files": ["%s"]}}`, source, versionfile, importFile))
+
+			meta4, found := s3.Object("metalinks", "component/v2.1.0.meta4")
+			Expect(found).To(BeTrue())
+			Expect(string(meta4.Data)).To(ContainSubstring(fmt.Sprintf("<url>%s/blobs/2.1.0/blob.txt</url>", s3.URL)))
+
+			check := runResource(checkCLI, nil, env, fmt.Sprintf(`{"source": %s}`, source))
+			Expect(string(check)).To(MatchJSON(`[{"version": "2.1.0"}]`))
+		})
+
+		It("fails when referenced environment variables are not set", func() {
+			connection["endpoint"] = "((env:FAKE_S3_UNSET_ENDPOINT))"
+
+			session := runCLIFailure(fmt.Sprintf(`{"source": %s, "params": {"version": "%s", "files": ["%s"]}}`, sourceJSON(nil, nil), versionfile, importFile))
+			Expect(session.Err).To(gbytes.Say("environment variable is not set: FAKE_S3_UNSET_ENDPOINT"))
+		})
+
+		It("fails when options are set along with their file variant", func() {
+			connection["secret_key_file"] = path.Join(mirrorDir, "secret-key")
+			Expect(ioutil.WriteFile(connection["secret_key_file"].(string), []byte("fake-secret-key"), 0600)).To(Succeed())
+
+			session := runCLIFailure(fmt.Sprintf(`{"source": %s, "params": {"version": "%s", "files": ["%s"]}}`, sourceJSON(nil, nil), versionfile, importFile))
+			Expect(session.Err).To(gbytes.Say("secret_key_file: cannot be combined with secret_key"))
+		})
+	})
+
+	Describe("git repositories", func() {
+		var remote *pkgtesting.GitRemote
+		var keyDir string
+
+		BeforeEach(func() {
+			var err error
+
+			remote, err = pkgtesting.NewGitRemote("master")
+			Expect(err).NotTo(HaveOccurred())
+
+			keyDir, err = ioutil.TempDir("", "metalink-repository-resource-keys")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(remote.Close()).To(Succeed())
+			Expect(os.RemoveAll(keyDir)).To(Succeed())
+		})
+
+		runGitOut := func(options map[string]interface{}) map[string]interface{} {
+			request, err := json.Marshal(map[string]interface{}{
+				"source": map[string]interface{}{
+					"uri":     remote.URI("metalinks"),
+					"options": options,
+				},
+				"params": map[string]interface{}{
+					"metalink": metalinkfile,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			return runCLI(string(request))
+		}
+
+		It("pushes annotated tags with the commit", func() {
+			result := runGitOut(map[string]interface{}{
+				"tag":         "v{{.Version}}",
+				"tag_message": "release {{.Version}}",
+			})
+			Expect(result["version"].(map[string]interface{})["version"]).To(Equal("2.1.0"))
+
+			tagType, err := remote.Git("cat-file", "-t", "v2.1.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tagType).To(Equal("tag\n"))
+
+			tagged, err := remote.Git("rev-parse", "v2.1.0^{commit}")
+			Expect(err).NotTo(HaveOccurred())
+
+			head, err := remote.Git("rev-parse", "master")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tagged).To(Equal(head))
+
+			tag, err := remote.Git("cat-file", "-p", "v2.1.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tag).To(ContainSubstring("release 2.1.0"))
+			Expect(tag).NotTo(ContainSubstring("SIGNATURE"))
+		})
+
+		It("signs commits and tags with ssh keys", func() {
+			privateKeyPath, publicKey, err := pkgtesting.GenerateSSHKey(keyDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			privateKey, err := ioutil.ReadFile(privateKeyPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			runGitOut(map[string]interface{}{
+				"signing_key": string(privateKey),
+				"tag":         "v{{.Version}}",
+			})
+
+			allowedSigners := filepath.Join(keyDir, "allowed_signers")
+			Expect(ioutil.WriteFile(allowedSigners, []byte("* "+publicKey+"\n"), 0644)).To(Succeed())
+
+			_, err = remote.Git("-c", "gpg.ssh.allowedSignersFile="+allowedSigners, "verify-commit", "master")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = remote.Git("-c", "gpg.ssh.allowedSignersFile="+allowedSigners, "verify-tag", "v2.1.0")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("signs commits with openpgp keys", func() {
+			privateKey, _, err := pkgtesting.GenerateOpenPGPKey(filepath.Join(keyDir, "gnupg"))
+			Expect(err).NotTo(HaveOccurred())
+
+			runGitOut(map[string]interface{}{
+				"signing_key": privateKey,
+			})
+
+			commit, err := remote.Git("cat-file", "-p", "master")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commit).To(ContainSubstring("-----BEGIN PGP SIGNATURE-----"))
+
+			remote.Env = []string{"GNUPGHOME=" + filepath.Join(keyDir, "gnupg")}
+
+			_, err = remote.Git("verify-commit", "master")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("fails when the tag already exists", func() {
+			_, err := remote.Git("tag", "v2.1.0", "master")
+			Expect(err).NotTo(HaveOccurred())
+
+			head, err := remote.Git("rev-parse", "master")
+			Expect(err).NotTo(HaveOccurred())
+
+			request, err := json.Marshal(map[string]interface{}{
+				"source": map[string]interface{}{
+					"uri": remote.URI("metalinks"),
+					"options": map[string]interface{}{
+						"tag":    "v{{.Version}}",
+						"rebase": "0",
+					},
+				},
+				"params": map[string]interface{}{
+					"metalink": metalinkfile,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			session := runCLIFailure(string(request))
+			Expect(session.Err).To(gbytes.Say("Tag v2.1.0 already exists"))
+
+			By("not pushing the commit without its tag", func() {
+				newHead, err := remote.Git("rev-parse", "master")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(newHead).To(Equal(head))
+			})
+		})
+	})
+
+	Describe("git pull requests", func() {
+		var remote *pkgtesting.GitRemote
+		var forge *pkgtesting.Forge
+
+		BeforeEach(func() {
+			var err error
+
+			remote, err = pkgtesting.NewGitRemote("master")
+			Expect(err).NotTo(HaveOccurred())
+
+			forge = pkgtesting.NewForge()
+			forge.Token = "fake-token"
+		})
+
+		AfterEach(func() {
+			Expect(remote.Close()).To(Succeed())
+			forge.Close()
+		})
+
+		runPullRequestOut := func(pullRequest map[string]interface{}, tag string) *gexec.Session {
+			options := map[string]interface{}{
+				"pull_request": pullRequest,
+			}
+
+			if tag != "" {
+				options["tag"] = tag
+			}
+
+			request, err := json.Marshal(map[string]interface{}{
+				"source": map[string]interface{}{
+					"uri":     remote.URI("metalinks"),
+					"options": options,
+				},
+				"params": map[string]interface{}{
+					"metalink": metalinkfile,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			command := exec.Command(cli, os.TempDir())
+			command.Stdin = bytes.NewBuffer(request)
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			return session.Wait(time.Minute)
+		}
+
+		for _, forgeAPI := range []struct {
+			forge  string
+			prefix string
+		}{
+			{forge: "github", prefix: "/api/v3"},
+			{forge: "gitlab", prefix: "/api/v4"},
+			{forge: "gitea", prefix: "/api/v1"},
+		} {
+			forgeAPI := forgeAPI
+
+			It(fmt.Sprintf("opens %s pull requests for a generated branch", forgeAPI.forge), func() {
+				head, err := remote.Git("rev-parse", "master")
+				Expect(err).NotTo(HaveOccurred())
+
+				session := runPullRequestOut(map[string]interface{}{
+					"forge":      forgeAPI.forge,
+					"api_url":    forge.URL + forgeAPI.prefix,
+					"token":      "fake-token",
+					"repository": "releases/metalinks",
+					"branch":     "metalink-v{{.Version}}",
+					"title":      "Publish {{.Version}}",
+					"auto_merge": true,
+				}, "")
+				Expect(session.ExitCode()).To(Equal(0))
+
+				var result map[string]interface{}
+				Expect(json.Unmarshal(session.Out.Contents(), &result)).To(Succeed())
+				Expect(result["metadata"]).To(ContainElement(map[string]interface{}{
+					"name":  "pull_request",
+					"value": fmt.Sprintf("%s/releases/metalinks/pulls/1", forge.URL),
+				}))
+
+				Expect(forge.PullRequests()).To(Equal([]pkgtesting.ForgePullRequest{
+					{
+						Forge:      forgeAPI.forge,
+						Repository: "releases/metalinks",
+						Head:       "metalink-v2.1.0",
+						Base:       "master",
+						Title:      "Publish 2.1.0",
+						Body:       "",
+						AutoMerge:  true,
+						URL:        fmt.Sprintf("%s/releases/metalinks/pulls/1", forge.URL),
+					},
+				}))
+
+				By("pushing the branch without changing the base branch", func() {
+					content, err := remote.Git("show", "metalink-v2.1.0:metalinks/v2.1.0.meta4")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(content).To(ContainSubstring("2.1.0"))
+
+					newHead, err := remote.Git("rev-parse", "master")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(newHead).To(Equal(head))
+				})
+			})
+
+			It(fmt.Sprintf("reuses open %s pull requests when retried", forgeAPI.forge), func() {
+				pullRequest := map[string]interface{}{
+					"forge":      forgeAPI.forge,
+					"api_url":    forge.URL + forgeAPI.prefix,
+					"token":      "fake-token",
+					"repository": "releases/metalinks",
+					"branch":     "metalink-v{{.Version}}",
+				}
+
+				Expect(runPullRequestOut(pullRequest, "").ExitCode()).To(Equal(0))
+
+				session := runPullRequestOut(pullRequest, "")
+				Expect(session.ExitCode()).To(Equal(0))
+
+				var result map[string]interface{}
+				Expect(json.Unmarshal(session.Out.Contents(), &result)).To(Succeed())
+				Expect(result["metadata"]).To(ContainElement(map[string]interface{}{
+					"name":  "pull_request",
+					"value": fmt.Sprintf("%s/releases/metalinks/pulls/1", forge.URL),
+				}))
+
+				Expect(forge.PullRequests()).To(HaveLen(1))
+			})
+		}
+
+		It("does not enable auto-merge by default", func() {
+			session := runPullRequestOut(map[string]interface{}{
+				"forge":   "github",
+				"api_url": forge.URL + "/api/v3",
+				"token":   "fake-token",
+				"branch":  "metalink-v{{.Version}}",
+			}, "")
+			Expect(session.ExitCode()).To(Equal(0))
+
+			pullRequests := forge.PullRequests()
+			Expect(pullRequests).To(HaveLen(1))
+			Expect(pullRequests[0].AutoMerge).To(BeFalse())
+			Expect(pullRequests[0].Title).To(Equal("update metalink"))
+			Expect(pullRequests[0].Repository).To(Equal(strings.TrimSuffix(strings.TrimPrefix(remote.Dir, "/"), ".git")))
+		})
+
+		It("fails when the pull request cannot be opened", func() {
+			session := runPullRequestOut(map[string]interface{}{
+				"forge":   "gitea",
+				"api_url": forge.URL + "/api/v1",
+				"token":   "wrong-token",
+				"branch":  "metalink-v{{.Version}}",
+			}, "")
+			Expect(session.ExitCode()).NotTo(Equal(0))
+			Expect(session.Err).To(gbytes.Say("Bad credentials"))
+		})
+
+		It("refuses to tag pull requests", func() {
+			session := runPullRequestOut(map[string]interface{}{
+				"forge":   "gitea",
+				"api_url": forge.URL + "/api/v1",
+				"token":   "fake-token",
+				"branch":  "metalink-v{{.Version}}",
+			}, "v{{.Version}}")
+			Expect(session.ExitCode()).NotTo(Equal(0))
+			Expect(session.Err).To(gbytes.Say("tag option cannot be combined with pull_request"))
+			Expect(forge.PullRequests()).To(BeEmpty())
+		})
+	})
 })
@@ -17,7 +17,7 @@ func Test(t *testing.T) {
 	RunSpecs(t, "github.com/dpb587/metalink-repository-resource/out")
 }
 
-var cli string
+var cli, checkCLI, inCLI string
 var repositorydir string
 
 var _ = BeforeSuite(func() {
@@ -25,6 +25,13 @@ var _ = BeforeSuite(func() {
 
 	cli, err = gexec.Build("github.com/dpb587/metalink-repository-resource/out")
 	Expect(err).ShouldNot(HaveOccurred())
+
+	// for verifying published versions in full cycles
+	checkCLI, err = gexec.Build("github.com/dpb587/metalink-repository-resource/check")
+	Expect(err).ShouldNot(HaveOccurred())
+
+	inCLI, err = gexec.Build("github.com/dpb587/metalink-repository-resource/in")
+	Expect(err).ShouldNot(HaveOccurred())
 })
 
 var _ = AfterSuite(func() {